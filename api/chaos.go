@@ -0,0 +1,51 @@
+package api
+
+import (
+	babel "BabelBridge/backend"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnableChaos adds the admin endpoints controlling the faults chaos injects into the engines:
+//
+//	GET    /admin/chaos   the current configuration
+//	PUT    /admin/chaos   replace it with the babel.ChaosConfig in the body
+//	DELETE /admin/chaos   stop injecting faults
+//
+// The endpoints are meant for development and only answer connections from the same machine, which includes every
+// client of a reverse proxy running there, see loopbackOnly.
+func (s *Server) EnableChaos(chaos *babel.Chaos) {
+	admin := s.Engine.Group("/admin/chaos", loopbackOnly)
+	admin.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, chaos.Config())
+	})
+	admin.PUT("", func(c *gin.Context) {
+		var config babel.ChaosConfig
+		if err := c.ShouldBindJSON(&config); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if err := chaos.SetConfig(config); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, chaos.Config())
+	})
+	admin.DELETE("", func(c *gin.Context) {
+		_ = chaos.SetConfig(babel.ChaosConfig{})
+		c.JSON(http.StatusOK, chaos.Config())
+	})
+}
+
+// loopbackOnly rejects requests whose connection does not come from this machine. It ignores forwarding headers,
+// which clients can forge, but a reverse proxy on the same host connects from loopback for every client it
+// forwards, so behind one the endpoints it guards are open to anyone who can reach the proxy. It is no substitute
+// for keeping them disabled outside development.
+func loopbackOnly(c *gin.Context) {
+	if ip := c.RemoteIP(); ip != "127.0.0.1" && ip != "::1" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	c.Next()
+}
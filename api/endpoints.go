@@ -1,12 +1,48 @@
 package api
 
 import (
+	babel "BabelBridge/backend"
+	"BabelBridge/service"
+	"errors"
 	"net/http"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/text/language"
 )
 
+// capabilities reports what the configured engine supports, so the frontend can hide what it cannot do
+func (s *Server) capabilities(c *gin.Context) {
+	capabilities := s.svc.Capabilities()
+	c.JSON(http.StatusOK, CapabilitiesResponse{Capabilities: capabilities, ChunkSize: capabilities.ChunkSize()})
+}
+
+// listModels lists the models the engines reported along with the models requests may pick
+func (s *Server) listModels(c *gin.Context) {
+	discovered, err := s.svc.Models(c.Request.Context())
+	limits := s.generationLimits()
+	response := ModelsResponse{Models: []ModelEntry{}}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	listed := make(map[string]bool, len(discovered))
+	for _, model := range discovered {
+		listed[model.Name] = true
+		response.Models = append(response.Models, ModelEntry{
+			Name:         model.Name,
+			Available:    true,
+			Selectable:   slices.Contains(limits.Models, model.Name),
+			Capabilities: model.Capabilities,
+		})
+	}
+	for _, name := range limits.Models {
+		if !listed[name] {
+			response.Models = append(response.Models, ModelEntry{Name: name, Selectable: true, Capabilities: s.svc.Capabilities()})
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // startTranslation starts a new translation context
 func (s *Server) startTranslation(c *gin.Context) {
 	var req StartRequest
@@ -19,19 +55,37 @@ func (s *Server) startTranslation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language tag"})
 		return
 	}
+	if s.rejectStreaming(c, req.Stream) {
+		return
+	}
+	ctx, ok := s.generationContext(c, req.Options)
+	if !ok {
+		return
+	}
 	// identify source language
-	identified, err := s.svc.Identify(c, req.Source)
+	identified, err := s.svc.Identify(c.Request.Context(), req.Source)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctxID, result, err := s.svc.NewTranslation(c, req.Source, tag)
+	sess, _ := c.Cookie(s.CookieName)
+	if req.Stream {
+		events := newEventStream(c)
+		ctxID, result, err := s.svc.NewTranslationStream(ctx, req.Source, identified, tag, events.delta)
+		if err != nil {
+			events.fail(err)
+			return
+		}
+		s.contexts.Put(sess, ctxID)
+		events.done(StartResponse{ContextID: ctxID, Result: result, SourceLang: identified.String()})
+		return
+	}
+	ctxID, result, err := s.svc.NewTranslation(ctx, req.Source, identified, tag)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	// Track context for the session
-	sess, _ := c.Cookie(s.CookieName)
 	s.contexts.Put(sess, ctxID)
 	c.JSON(http.StatusOK, StartResponse{ContextID: ctxID, Result: result, SourceLang: identified.String()})
 }
@@ -44,6 +98,10 @@ func (s *Server) improveTranslation(c *gin.Context) {
 		return
 	}
 	sess, _ := c.Cookie(s.CookieName)
+	if s.comparisons.Exists(sess, req.ContextID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a comparison cannot be improved, promote one of its candidates first"})
+		return
+	}
 	if !s.contexts.Exists(sess, req.ContextID) {
 		// Check if it existed but expired
 		if s.contexts.wasExpired(sess, req.ContextID) {
@@ -53,7 +111,25 @@ func (s *Server) improveTranslation(c *gin.Context) {
 		}
 		return
 	}
-	res, err := s.svc.Improve(c, req.ContextID, req.Feedback)
+	if s.rejectStreaming(c, req.Stream) {
+		return
+	}
+	if req.Stream {
+		events := newEventStream(c)
+		res, err := s.svc.ImproveStream(c.Request.Context(), req.ContextID, req.Feedback, events.delta)
+		if err != nil {
+			events.fail(err)
+			return
+		}
+		s.contexts.Touch(sess, req.ContextID)
+		events.done(ImproveResponse{Result: res})
+		return
+	}
+	res, err := s.svc.Improve(c.Request.Context(), req.ContextID, req.Feedback)
+	if errors.Is(err, babel.ErrImproveUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -74,7 +150,24 @@ func (s *Server) previewTranslation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language tag"})
 		return
 	}
-	res, err := s.svc.Preview(c, req.Source, tag)
+	if s.rejectStreaming(c, req.Stream) {
+		return
+	}
+	ctx, ok := s.generationContext(c, req.Options)
+	if !ok {
+		return
+	}
+	if req.Stream {
+		events := newEventStream(c)
+		res, err := s.svc.PreviewStream(ctx, req.Source, tag, events.delta)
+		if err != nil {
+			events.fail(err)
+			return
+		}
+		events.done(PreviewResponse{Result: res})
+		return
+	}
+	res, err := s.svc.Preview(ctx, req.Source, tag)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -89,10 +182,81 @@ func (s *Server) identifyLanguage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
-	tag, err := s.svc.Identify(c, req.Source)
+	tag, err := s.svc.Identify(c.Request.Context(), req.Source)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, IdentifyResponse{Lang: tag.String()})
 }
+
+// compareTranslation runs the translation on every configured candidate backend side by side
+func (s *Server) compareTranslation(c *gin.Context) {
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	tag, err := language.Parse(req.Lang)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid language tag"})
+		return
+	}
+	comparisonID, candidates, err := s.svc.Compare(c.Request.Context(), req.Source, tag)
+	if errors.Is(err, service.ErrCompareUnavailable) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// the comparison belongs to the session like a context, so only it can promote a candidate
+	sess, _ := c.Cookie(s.CookieName)
+	s.comparisons.Put(sess, comparisonID)
+
+	res := CompareResponse{ComparisonID: comparisonID, Candidates: make([]CompareCandidate, 0, len(candidates))}
+	for _, candidate := range candidates {
+		res.Candidates = append(res.Candidates, CompareCandidate{
+			Name:             candidate.Name,
+			Result:           candidate.Result,
+			Error:            candidate.Error,
+			LatencyMs:        candidate.Latency.Milliseconds(),
+			PromptTokens:     candidate.Usage.PromptTokens,
+			CompletionTokens: candidate.Usage.CompletionTokens,
+		})
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// promoteCandidate turns the winning candidate of a comparison into a regular improvable context
+func (s *Server) promoteCandidate(c *gin.Context) {
+	var req PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	sess, _ := c.Cookie(s.CookieName)
+	if !s.comparisons.Exists(sess, req.ComparisonID) {
+		if s.comparisons.wasExpired(sess, req.ComparisonID) {
+			c.JSON(http.StatusGone, gin.H{"error": "comparison expired"})
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "comparison not found"})
+		}
+		return
+	}
+	ctxID, result, err := s.svc.Promote(c.Request.Context(), req.ComparisonID, req.Candidate)
+	switch {
+	case errors.Is(err, service.ErrComparisonNotFound):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrCandidateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.contexts.Put(sess, ctxID)
+	c.JSON(http.StatusOK, PromoteResponse{ContextID: ctxID, Result: result})
+}
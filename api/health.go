@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveness answers as long as the process serves requests.
+func (s *Server) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readiness answers 200 when the frontend is built and the engine answers its probe within ProbeTimeout, 503
+// otherwise and as soon as shutdown began. The body details every dependency.
+func (s *Server) readiness(c *gin.Context) {
+	if !s.Ready() {
+		c.JSON(http.StatusServiceUnavailable, ReadinessResponse{Status: "shutting down"})
+		return
+	}
+	s.mu.RLock()
+	timeout := s.config.ProbeTimeout
+	s.mu.RUnlock()
+
+	checks := map[string]CheckResult{"frontend": checkFrontend()}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	health := s.svc.Health(ctx)
+	engine := CheckResult{Status: "ok", Latency: health.Latency.String(), CheckedAt: health.Checked, Members: health.Failover}
+	if health.Err != nil {
+		engine.Status, engine.Error = "failing", health.Err.Error()
+	}
+	checks["engine"] = engine
+
+	response, code := ReadinessResponse{Status: "ready", Checks: checks}, http.StatusOK
+	for _, check := range checks {
+		if check.Status != "ok" {
+			response.Status, code = "not ready", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, response)
+}
+
+// checkFrontend checks that the frontend build the server falls back to is present.
+func checkFrontend() CheckResult {
+	index := filepath.Join(distDir, "index.html")
+	if _, err := os.Stat(index); err != nil {
+		return CheckResult{Status: "failing", Error: index + " not found, run 'npm run build' in the frontend directory"}
+	}
+	return CheckResult{Status: "ok"}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// OnShutdown registers hook to run once the server stopped serving, such as stopping background workers and
+// flushing stores. Hooks run in reverse order of registration and get DrainTimeout to finish.
+func (s *Server) OnShutdown(hook func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Ready reports whether the server takes new work. It turns false as soon as shutdown begins.
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// Run listens on addr and serves until ctx is done, see Serve.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Join(err, s.runHooks())
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is done, then shuts down gracefully: readiness turns false, after
+// ShutdownDelay no new connections are accepted, running requests get DrainTimeout to finish and are cancelled
+// after that, then the shutdown hooks run. It returns nil after a clean shutdown.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{Handler: s.Engine}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		s.ready.Store(false)
+		return errors.Join(err, s.runHooks())
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	s.mu.RLock()
+	delay, drain := s.config.ShutdownDelay, s.config.DrainTimeout
+	s.mu.RUnlock()
+	slog.Info("Shutting down", "delay", delay, "drainTimeout", drain)
+	time.Sleep(delay)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	err := server.Shutdown(drainCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Drain timeout reached, cancelling the requests still running")
+		err = server.Close()
+	}
+	<-served // http.ErrServerClosed once shut down
+	return errors.Join(err, s.runHooks())
+}
+
+// runHooks runs the shutdown hooks in reverse order of registration, reporting all failures.
+func (s *Server) runHooks() error {
+	s.mu.RLock()
+	hooks, drain := s.hooks, s.config.DrainTimeout
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			slog.Error("shutdown hook failed", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,9 +1,40 @@
 package api
 
+import (
+	babel "BabelBridge/backend"
+	"time"
+)
+
+// capabilities response model
+type CapabilitiesResponse struct {
+	babel.Capabilities
+	// ChunkSize is the number of characters translated per request, longer input is split
+	ChunkSize int `json:"chunkSize"`
+}
+
+// models response model
+type ModelsResponse struct {
+	Models []ModelEntry `json:"models"`
+	// Error says why the engines could not list their models, the allowed models are listed regardless
+	Error string `json:"error,omitempty"`
+}
+type ModelEntry struct {
+	Name string `json:"name"`
+	// Available means an engine reported the model, allowed models of engines that cannot list theirs are not
+	Available bool `json:"available"`
+	// Selectable means requests may pick the model in their options
+	Selectable   bool               `json:"selectable"`
+	Capabilities babel.Capabilities `json:"capabilities"`
+}
+
 // startTranslation request and response models
 type StartRequest struct {
 	Source string `json:"source" binding:"required"`
 	Lang   string `json:"lang" binding:"required"`
+	// Stream answers with server-sent events, a delta event per piece of text and a done event with the response
+	Stream bool `json:"stream"`
+	// Options override the engine's generation options, improvements of the context keep using them
+	Options *babel.GenerationOptions `json:"options"`
 }
 type StartResponse struct {
 	ContextID  string `json:"contextId"`
@@ -15,6 +46,7 @@ type StartResponse struct {
 type ImproveRequest struct {
 	ContextID string `json:"contextId" binding:"required"`
 	Feedback  string `json:"feedback" binding:"required"`
+	Stream    bool   `json:"stream"`
 }
 type ImproveResponse struct {
 	Result string `json:"result"`
@@ -22,8 +54,10 @@ type ImproveResponse struct {
 
 // previewTranslation request and response models
 type PreviewRequest struct {
-	Source string `json:"source" binding:"required"`
-	Lang   string `json:"lang" binding:"required"`
+	Source  string                   `json:"source" binding:"required"`
+	Lang    string                   `json:"lang" binding:"required"`
+	Stream  bool                     `json:"stream"`
+	Options *babel.GenerationOptions `json:"options"`
 }
 type PreviewResponse struct {
 	Result string `json:"result"`
@@ -36,3 +70,49 @@ type IdentifyRequest struct {
 type IdentifyResponse struct {
 	Lang string `json:"lang"`
 }
+
+// compareTranslation request and response models
+type CompareRequest struct {
+	Source string `json:"source" binding:"required"`
+	Lang   string `json:"lang" binding:"required"`
+}
+type CompareCandidate struct {
+	Name             string `json:"name"`
+	Result           string `json:"result,omitempty"`
+	Error            string `json:"error,omitempty"`
+	LatencyMs        int64  `json:"latencyMs"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
+}
+type CompareResponse struct {
+	ComparisonID string             `json:"comparisonId"`
+	Candidates   []CompareCandidate `json:"candidates"`
+}
+
+// promoteCandidate request and response models
+type PromoteRequest struct {
+	ComparisonID string `json:"comparisonId" binding:"required"`
+	Candidate    string `json:"candidate" binding:"required"`
+}
+type PromoteResponse struct {
+	ContextID string `json:"contextId"`
+	Result    string `json:"result"`
+}
+
+// readiness response model
+type ReadinessResponse struct {
+	// Status is ready, not ready or shutting down
+	Status string `json:"status"`
+	// Checks holds the state of every dependency by name, none while shutting down
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+type CheckResult struct {
+	// Status is ok or failing
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Latency and CheckedAt describe the probe of the engine, which is cached for a few seconds
+	Latency   string    `json:"latency,omitempty"`
+	CheckedAt time.Time `json:"checkedAt,omitzero"`
+	// Members is the health of each engine of a failover chain, including how many requests it served
+	Members []babel.FailoverStatus `json:"members,omitempty"`
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	babel "BabelBridge/backend"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetGenerationLimits bounds the generation options clients may send with a translation. By default requests
+// cannot pick a model and get the limits of a zero babel.GenerationLimits.
+func (s *Server) SetGenerationLimits(limits babel.GenerationLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+}
+
+// generationLimits returns the current limits, which SetGenerationLimits may replace while serving.
+func (s *Server) generationLimits() babel.GenerationLimits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limits
+}
+
+// generationContext checks the generation options of a request against the limits and what the engine supports,
+// answering with 400 when they do not fit. The returned context carries the options for the translation.
+func (s *Server) generationContext(c *gin.Context, options *babel.GenerationOptions) (context.Context, bool) {
+	if options == nil {
+		return c.Request.Context(), true
+	}
+	if err := s.generationLimits().Check(*options, s.svc.Capabilities()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return babel.WithGenerationOptions(c.Request.Context(), *options), true
+}
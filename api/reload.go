@@ -0,0 +1,22 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnableReload adds POST /admin/reload, which calls reload to apply the configuration again without a restart.
+// A failed reload answers 422 with the problems and leaves everything as it was. reload gets the context of the
+// request. Like the chaos endpoints it only answers connections from the same machine, which includes every client
+// of a reverse proxy running there, see loopbackOnly, so only enable it where that proxy is trusted or absent.
+func (s *Server) EnableReload(reload func(context.Context) error) {
+	s.Engine.POST("/admin/reload", loopbackOnly, func(c *gin.Context) {
+		if err := reload(c.Request.Context()); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "reloaded"})
+	})
+}
@@ -1,10 +1,13 @@
 package api
 
 import (
+	babel "BabelBridge/backend"
 	"BabelBridge/service"
+	"context"
 	"log/slog"
 	"net/http"
-	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -18,14 +21,82 @@ import (
 // Session duration for contexts and access
 const sessionTTL = 7 * 24 * time.Hour
 
+// comparisonTTL is how long a comparison waits for one of its candidates to be promoted
+const comparisonTTL = 15 * time.Minute
+
+// distDir holds the frontend build served next to the API
+const distDir = "frontend/dist"
+
+// Config holds the settings of the HTTP server.
+type Config struct {
+	// SecretKey signs the session cookies, a random one is generated when empty
+	SecretKey string
+	// SessionTTL is how long a session lives without being used
+	SessionTTL time.Duration
+	// ContextTTL is how long a translation context lives without being used
+	ContextTTL time.Duration
+	// ComparisonTTL is how long a side-by-side comparison can be promoted
+	ComparisonTTL time.Duration
+	RateLimit     RateLimit
+	// ShutdownDelay is how long the server keeps serving once readiness turned false, so that load balancers stop
+	// sending requests before connections are refused
+	ShutdownDelay time.Duration
+	// DrainTimeout bounds how long running requests, such as LLM calls mid-translation, may take to finish on
+	// shutdown. Requests still running then are cancelled.
+	DrainTimeout time.Duration
+	// ProbeTimeout bounds the probe of the engine /readyz runs
+	ProbeTimeout time.Duration
+}
+
+// RateLimit bounds the requests per client IP.
+type RateLimit struct {
+	Enabled bool
+	// Session limits /session, API the /api endpoints
+	Session Rate
+	API     Rate
+}
+
+// Rate allows Limit requests per Period.
+type Rate struct {
+	Limit  int64
+	Period time.Duration
+}
+
+// DefaultConfig returns the settings of NewServer: one week TTLs, 15 minutes to promote a comparison, once enabled 5 sessions and 30 API requests per
+// minute, 30 seconds to drain on shutdown and 5 seconds for the engine to answer readiness probes.
+func DefaultConfig() Config {
+	return Config{
+		SessionTTL:    sessionTTL,
+		ContextTTL:    sessionTTL,
+		ComparisonTTL: comparisonTTL,
+		DrainTimeout:  30 * time.Second,
+		ProbeTimeout:  5 * time.Second,
+		RateLimit: RateLimit{
+			Session: Rate{Limit: 5, Period: time.Minute},
+			API:     Rate{Limit: 30, Period: time.Minute},
+		},
+	}
+}
+
 type Server struct {
 	Engine         *gin.Engine
 	svc            service.TranslationService
 	sessions       *sessionStore
 	contexts       *contextStore
+	comparisons    *contextStore
 	CookieName     string
 	cookieSecure   bool
 	cookieSameSite http.SameSite
+
+	// mu guards the settings Reconfigure and SetGenerationLimits replace while serving
+	mu       sync.RWMutex
+	config   Config
+	limits   babel.GenerationLimits
+	limiters *rateLimiters
+	hooks    []func(context.Context) error
+
+	// ready turns false once shutdown begins
+	ready atomic.Bool
 }
 
 // NewServer builds a new server with default 1-week TTLs.
@@ -35,9 +106,16 @@ func NewServer(svc service.TranslationService, secretKey string) *Server {
 
 // NewServerWithTTLs builds a new server with custom TTLs.
 func NewServerWithTTLs(svc service.TranslationService, sessTTL, ctxTTL time.Duration, secretKey string) *Server {
+	config := DefaultConfig()
+	config.SessionTTL, config.ContextTTL, config.SecretKey = sessTTL, ctxTTL, secretKey
+	return NewServerWithConfig(svc, config)
+}
+
+// NewServerWithConfig builds a new server with the given settings.
+func NewServerWithConfig(svc service.TranslationService, config Config) *Server {
 	r := gin.Default()
 
-	// load the secret key from the environment. if it doesn't exist, generate a random one.
+	secretKey := config.SecretKey
 	if len(secretKey) == 0 {
 		slog.Warn("SECRET_KEY not set, generating random one")
 		secretKey = service.RandomToken()
@@ -49,12 +127,16 @@ func NewServerWithTTLs(svc service.TranslationService, sessTTL, ctxTTL time.Dura
 	s := &Server{
 		Engine:         r,
 		svc:            svc,
-		sessions:       newSessionStore(sessTTL),
-		contexts:       newContextStore(ctxTTL),
+		config:         config,
+		limiters:       newRateLimiters(config.RateLimit),
+		sessions:       newSessionStore(config.SessionTTL),
+		contexts:       newContextStore(config.ContextTTL),
+		comparisons:    newContextStore(config.ComparisonTTL),
 		CookieName:     "session_token",
 		cookieSecure:   false,
 		cookieSameSite: http.SameSiteLaxMode,
 	}
+	s.ready.Store(true)
 
 	api := r.Group("/api")
 	sessionHandler := []gin.HandlerFunc{func(c *gin.Context) {
@@ -65,36 +147,26 @@ func NewServerWithTTLs(svc service.TranslationService, sessTTL, ctxTTL time.Dura
 		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte("OK"))
 	}}
 
-	// rate limiting setup
-	if os.Getenv("RATE_LIMITING_ENABLED") == "true" {
-		slog.Info("Rate limiting enabled")
-		memStore := memory.NewStore()
-		limiterSession := limiterpkg.New(memStore, limiterpkg.Rate{Period: time.Minute, Limit: 5})
-		limiterAPI := limiterpkg.New(memStore, limiterpkg.Rate{Period: time.Minute, Limit: 30})
-		limiterSessionMW := ginlimiter.NewMiddleware(limiterSession)
-		limiterAPIMW := ginlimiter.NewMiddleware(limiterAPI)
-		sessionHandler = append([]gin.HandlerFunc{limiterSessionMW}, sessionHandler...)
-		api.Use(limiterAPIMW)
-	} else {
-		slog.Warn("Rate limiting disabled")
-	}
+	// rate limiting, the limiters are looked up on every request so that Reconfigure can change them
+	sessionHandler = append([]gin.HandlerFunc{s.rateLimit(func(l *rateLimiters) gin.HandlerFunc { return l.session })}, sessionHandler...)
+	api.Use(s.rateLimit(func(l *rateLimiters) gin.HandlerFunc { return l.api }))
 
 	// serve static assets for the frontend
-	r.Static("/assets", "frontend/dist/assets")
+	r.Static("/assets", distDir+"/assets")
 
 	// serve PWA files and other static assets from dist root
-	r.StaticFile("/favicon.svg", "frontend/dist/favicon.svg")
-	r.StaticFile("/manifest.json", "frontend/dist/manifest.json")
-	r.StaticFile("/og-image.svg", "frontend/dist/og-image.svg")
+	r.StaticFile("/favicon.svg", distDir+"/favicon.svg")
+	r.StaticFile("/manifest.json", distDir+"/manifest.json")
+	r.StaticFile("/og-image.svg", distDir+"/og-image.svg")
 
 	// Root: first try to serve static files, then ensure session and serve SPA
 	r.GET("/", func(c *gin.Context) {
 		// Try to serve static files from dist root first
 		path := c.Request.URL.Path
 		if path != "/" {
-			if f, err := http.Dir("frontend/dist").Open(path); err == nil {
+			if f, err := http.Dir(distDir).Open(path); err == nil {
 				_ = f.Close()
-				c.File("frontend/dist" + path)
+				c.File(distDir + path)
 				return
 			}
 		}
@@ -111,9 +183,9 @@ func NewServerWithTTLs(svc service.TranslationService, sessTTL, ctxTTL time.Dura
 	r.NoRoute(func(c *gin.Context) {
 		path := c.Request.URL.Path
 		// Try to serve from dist directory
-		if f, err := http.Dir("frontend/dist").Open(path); err == nil {
+		if f, err := http.Dir(distDir).Open(path); err == nil {
 			_ = f.Close()
-			c.File("frontend/dist" + path)
+			c.File(distDir + path)
 			return
 		}
 		// Fall back to SPA index for client-side routing
@@ -124,20 +196,78 @@ func NewServerWithTTLs(svc service.TranslationService, sessTTL, ctxTTL time.Dura
 		serveSPAIndex(c)
 	})
 
+	r.GET("/healthz", s.liveness)
+	r.GET("/readyz", s.readiness)
+
 	// manual session creation endpoint when front-end is running on a separate service
 	r.GET("/session", sessionHandler...)
 
 	api.Use(s.sessionMiddleware())
 	{
+		api.GET("/capabilities", s.capabilities)
+		api.GET("/models", s.listModels)
 		api.POST("/translate/start", s.startTranslation)
 		api.POST("/translate/improve", s.improveTranslation)
 		api.POST("/translate/preview", s.previewTranslation)
 		api.POST("/translate/identify", s.identifyLanguage)
+		api.POST("/translate/compare", s.compareTranslation)
+		api.POST("/translate/promote", s.promoteCandidate)
 	}
 
 	return s
 }
 
+// rateLimiters are the middlewares enforcing a RateLimit.
+type rateLimiters struct {
+	session, api gin.HandlerFunc
+}
+
+// newRateLimiters builds the limiters of config, nil when rate limiting is disabled.
+func newRateLimiters(config RateLimit) *rateLimiters {
+	if !config.Enabled {
+		slog.Warn("Rate limiting disabled")
+		return nil
+	}
+	slog.Info("Rate limiting enabled", "session", config.Session, "api", config.API)
+	memStore := memory.NewStore()
+	return &rateLimiters{
+		session: ginlimiter.NewMiddleware(limiterpkg.New(memStore, limiterpkg.Rate{Period: config.Session.Period, Limit: config.Session.Limit})),
+		api:     ginlimiter.NewMiddleware(limiterpkg.New(memStore, limiterpkg.Rate{Period: config.API.Period, Limit: config.API.Limit})),
+	}
+}
+
+// rateLimit applies the limiter pick chooses among the current ones, if rate limiting is enabled.
+func (s *Server) rateLimit(pick func(*rateLimiters) gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mu.RLock()
+		limiters := s.limiters
+		s.mu.RUnlock()
+		if limiters != nil {
+			pick(limiters)(c)
+		}
+	}
+}
+
+// Reconfigure applies new settings to the running server. TTLs apply to existing sessions and contexts too, rate
+// limits restart counting when they change. The secret key is kept until restart, changing it would log every
+// client out.
+func (s *Server) Reconfigure(config Config) {
+	s.sessions.SetTTL(config.SessionTTL)
+	s.contexts.SetTTL(config.ContextTTL)
+	s.comparisons.SetTTL(config.ComparisonTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config.SecretKey != s.config.SecretKey {
+		slog.Warn("Changing the secret key needs a restart, keeping the current one")
+		config.SecretKey = s.config.SecretKey
+	}
+	if config.RateLimit != s.config.RateLimit {
+		s.limiters = newRateLimiters(config.RateLimit)
+	}
+	s.config = config
+}
+
 // issueSessionHandler ensures a session token cookie is present.
 func (s *Server) issueSessionHandler(c *gin.Context) {
 	token, err := c.Cookie(s.CookieName)
@@ -152,9 +282,9 @@ func (s *Server) issueSessionHandler(c *gin.Context) {
 
 // serveSPAIndex serves the SPA index.html or a 500 error if not built.
 func serveSPAIndex(c *gin.Context) {
-	if f, err := http.Dir("frontend/dist").Open("index.html"); err == nil {
+	if f, err := http.Dir(distDir).Open("index.html"); err == nil {
 		_ = f.Close()
-		c.File("frontend/dist/index.html")
+		c.File(distDir + "/index.html")
 		return
 	}
 	c.String(http.StatusInternalServerError, "Error: Frontend build not found. Please run 'npm run build' in the frontend directory.")
@@ -1,10 +1,16 @@
 package api_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +21,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
 )
 
 const testSecret = "test-secret"
@@ -203,37 +210,309 @@ func TestImproveTranslationUsesExistingContext(t *testing.T) {
 	require.Equal(t, "Hola. Me encanta la pizza.", improvePayload.Result)
 }
 
-// runWithRateLimitingModes runs the provided test function twice: once with
-// RATE_LIMITING_ENABLED=true and once with it unset. The subtest name includes
-// the env state (e.g. "RATE_LIMITING=true" or "RATE_LIMITING=unset").
-func runWithRateLimitingModes(t *testing.T, fn func(t *testing.T, enabled bool)) {
-	modes := []struct {
-		name string
-		env  string
-	}{
-		{name: "enabled", env: "true"},
-		{name: "disabled", env: ""},
+func TestCompareAndPromote(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := babel.NewBabel(babel.NewMockAISystem())
+	backend.SetCompareCandidates(
+		babel.Candidate{Name: "current", Backend: babel.NewMockAISystem()},
+		babel.Candidate{Name: "candidate", Backend: babel.NewMockAISystem()},
+	)
+	server := api.NewServerWithTTLs(service.NewBabelService(backend, time.Minute), time.Minute, time.Minute, testSecret)
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	compare := cs.doRequest(t, http.MethodPost, "/api/translate/compare", `{"source":"Hello","lang":"es"}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusOK, compare.Code)
+
+	var comparePayload struct {
+		ComparisonID string `json:"comparisonId"`
+		Candidates   []struct {
+			Name   string `json:"name"`
+			Result string `json:"result"`
+		} `json:"candidates"`
+	}
+	require.NoError(t, json.NewDecoder(compare.Body).Decode(&comparePayload))
+	require.NotEmpty(t, comparePayload.ComparisonID)
+	require.Len(t, comparePayload.Candidates, 2)
+	require.Equal(t, "candidate", comparePayload.Candidates[1].Name)
+	require.Equal(t, "Hola. Me gusta la pizza.", comparePayload.Candidates[1].Result)
+
+	promote := cs.doRequest(t, http.MethodPost, "/api/translate/promote",
+		`{"comparisonId":"`+comparePayload.ComparisonID+`","candidate":"candidate"}`,
+		requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, promote.Code)
+
+	var promotePayload startResp
+	require.NoError(t, json.NewDecoder(promote.Body).Decode(&promotePayload))
+	require.NotEmpty(t, promotePayload.ContextID)
+
+	improve := cs.doRequest(t, http.MethodPost, "/api/translate/improve",
+		`{"contextId":"`+promotePayload.ContextID+`","feedback":"more formal"}`,
+		requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, improve.Code)
+
+	var improvePayload improveResp
+	require.NoError(t, json.NewDecoder(improve.Body).Decode(&improvePayload))
+	require.Equal(t, "Hola. Me encanta la pizza.", improvePayload.Result)
+}
+
+func TestImproveRejectsComparison(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := babel.NewBabel(babel.NewMockAISystem())
+	backend.SetCompareCandidates(
+		babel.Candidate{Name: "current", Backend: babel.NewMockAISystem()},
+		babel.Candidate{Name: "candidate", Backend: babel.NewMockAISystem()},
+	)
+	server := api.NewServerWithTTLs(service.NewBabelService(backend, time.Minute), time.Minute, time.Minute, testSecret)
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	compare := cs.doRequest(t, http.MethodPost, "/api/translate/compare", `{"source":"Hello","lang":"es"}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusOK, compare.Code)
+	var comparePayload struct {
+		ComparisonID string `json:"comparisonId"`
+	}
+	require.NoError(t, json.NewDecoder(compare.Body).Decode(&comparePayload))
+
+	improve := cs.doRequest(t, http.MethodPost, "/api/translate/improve",
+		`{"contextId":"`+comparePayload.ComparisonID+`","feedback":"more formal"}`,
+		requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusBadRequest, improve.Code)
+}
+
+func TestCompareNotConfigured(t *testing.T) {
+	cs := newClientSession(t)
+
+	w := cs.doRequest(t, http.MethodPost, "/api/translate/compare", `{"source":"Hello","lang":"es"}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+// echoTranslator is a machine translation engine returning its input unchanged.
+type echoTranslator struct{}
+
+func (echoTranslator) Translate(_ context.Context, text string, _, _ language.Tag) (string, error) {
+	return text, nil
+}
+
+func (echoTranslator) Detect(context.Context, string) (language.Tag, error) {
+	return language.English, nil
+}
+
+func TestImproveNotSupportedByEngine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := babel.NewMachineTranslationBackend(echoTranslator{}, nil)
+	server := api.NewServerWithTTLs(service.NewBabelService(backend, time.Minute), time.Minute, time.Minute, testSecret)
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	start := cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello","lang":"es"}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusOK, start.Code)
+
+	var startPayload startResp
+	require.NoError(t, json.NewDecoder(start.Body).Decode(&startPayload))
+
+	improve := cs.doRequest(t, http.MethodPost, "/api/translate/improve",
+		`{"contextId":"`+startPayload.ContextID+`","feedback":"more formal"}`,
+		requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusNotImplemented, improve.Code)
+	require.Contains(t, improve.Body.String(), babel.ErrImproveUnsupported.Error())
+}
+
+func TestCapabilities(t *testing.T) {
+	cs := newClientSession(t)
+
+	w := cs.doRequest(t, http.MethodGet, "/api/capabilities", "", requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"streaming":false,"structuredOutput":false,"images":false,"samplingParameters":[],"chunkSize":4000}`, w.Body.String())
+}
+
+func TestStreamingRejectedWhenUnsupported(t *testing.T) {
+	cs := newClientSession(t)
+
+	for _, path := range []string{"/api/translate/start", "/api/translate/preview"} {
+		w := cs.doRequest(t, http.MethodPost, path, `{"source":"Hello","lang":"es","stream":true}`, requestOptions{
+			IncludeSessionToken: true,
+		})
+		require.Equal(t, http.StatusBadRequest, w.Code, path)
+		require.Contains(t, w.Body.String(), babel.ErrStreamingUnsupported.Error(), path)
+	}
+}
+
+// streamingMock streams the canned answers of the mock word by word.
+type streamingMock struct {
+	*babel.MockAISystem
+}
+
+func (streamingMock) Capabilities() babel.Capabilities {
+	return babel.Capabilities{Streaming: true, SamplingParameters: []string{}}
+}
+
+func (m streamingMock) ChatStream(ctx context.Context, messages []babel.Message, onDelta func(string) error) (string, error) {
+	result, err := m.Chat(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	for _, word := range strings.SplitAfter(result, " ") {
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+	return result, nil
+}
+
+func TestStreamingTranslation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	backend := babel.NewBabel(streamingMock{babel.NewMockAISystem()})
+	server := api.NewServerWithTTLs(service.NewBabelService(backend, time.Minute), time.Minute, time.Minute, testSecret)
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	w := cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello","lang":"es","stream":true}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+
+	body := w.Body.String()
+	require.Contains(t, body, "event:delta\ndata:{\"text\":\"Hola. \"}\n\n")
+	require.Contains(t, body, "event:delta\ndata:{\"text\":\"pizza.\"}\n\n")
+	_, done, found := strings.Cut(body, "event:done\ndata:")
+	require.True(t, found)
+
+	var startPayload startResp
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(done)), &startPayload))
+	require.Equal(t, "Hola. Me gusta la pizza.", startPayload.Result)
+
+	// the streamed context is kept like any other
+	improve := cs.doRequest(t, http.MethodPost, "/api/translate/improve",
+		`{"contextId":"`+startPayload.ContextID+`","feedback":"more formal","stream":true}`,
+		requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, improve.Code)
+	require.Contains(t, improve.Body.String(), "event:done\ndata:{\"result\":\"Hola. Me encanta la pizza.\"}")
+}
+
+func TestGenerationOptionsRejectedWhenUnsupported(t *testing.T) {
+	cs := newClientSession(t)
+
+	// the mock engine takes no sampling parameters
+	w := cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello","lang":"es","options":{"temperature":0.2}}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "temperature is not supported by this engine")
+}
+
+func TestGenerationOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	type completionRequest struct {
+		Model       string   `json:"model"`
+		Temperature *float64 `json:"temperature"`
 	}
+	var requests []completionRequest
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req completionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"es"},"finish_reason":"stop"}]}`))
+	}))
+	defer provider.Close()
+	openAI, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: provider.URL, Model: "small"})
+	require.NoError(t, err)
+
+	server := api.NewServerWithTTLs(service.NewBabelService(babel.NewBabel(openAI), time.Minute), time.Minute, time.Minute, testSecret)
+	server.SetGenerationLimits(babel.GenerationLimits{Models: []string{"large"}})
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	w := cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello","lang":"es","options":{"model":"large","temperature":0.1}}`, requestOptions{
+		IncludeSessionToken: true,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	// identification keeps the configured model, the translation uses the requested options
+	require.Len(t, requests, 2)
+	require.Equal(t, "small", requests[0].Model)
+	require.Nil(t, requests[0].Temperature)
+	require.Equal(t, "large", requests[1].Model)
+	require.Equal(t, 0.1, *requests[1].Temperature)
+
+	for body, problem := range map[string]string{
+		`{"source":"Hello","lang":"es","options":{"model":"huge"}}`:  `model \"huge\" is not allowed`,
+		`{"source":"Hello","lang":"es","options":{"temperature":3}}`: "temperature must be between 0 and 2",
+		`{"source":"Hello","lang":"es","options":{"stop":["a",""]}}`: "stop sequences must not be empty",
+		`{"source":"Hello","lang":"es","options":{"maxTokens":0}}`:   "max_tokens must be at least 1",
+	} {
+		w := cs.doRequest(t, http.MethodPost, "/api/translate/preview", body, requestOptions{IncludeSessionToken: true})
+		require.Equal(t, http.StatusBadRequest, w.Code, body)
+		require.Contains(t, w.Body.String(), problem, body)
+	}
+	require.Len(t, requests, 2)
+}
 
-	for _, mode := range modes {
-		mode := mode
-		var display string
-		if mode.env == "" {
-			display = "unset"
-		} else {
-			display = mode.env
+func TestListModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"small","object":"model"},{"id":"large","object":"model"}]}`))
+	}))
+	defer provider.Close()
+	openAI, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: provider.URL, Model: "small", ContextLength: 8192})
+	require.NoError(t, err)
+
+	server := api.NewServerWithTTLs(service.NewBabelService(babel.NewBabel(openAI), time.Minute), time.Minute, time.Minute, testSecret)
+	server.SetGenerationLimits(babel.GenerationLimits{Models: []string{"large", "unlisted"}})
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	w := cs.doRequest(t, http.MethodGet, "/api/models", "", requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code)
+	sampling := `["temperature","top_p","max_tokens","seed","stop"]`
+	require.JSONEq(t, `{"models":[
+		{"name":"large","available":true,"selectable":true,"capabilities":{"streaming":false,"structuredOutput":true,"images":false,"samplingParameters":`+sampling+`}},
+		{"name":"small","available":true,"selectable":false,"capabilities":{"streaming":false,"structuredOutput":true,"images":false,"contextLength":8192,"samplingParameters":`+sampling+`}},
+		{"name":"unlisted","available":false,"selectable":true,"capabilities":{"streaming":false,"structuredOutput":true,"images":false,"contextLength":8192,"samplingParameters":`+sampling+`}}
+	]}`, w.Body.String())
+}
+
+func TestListModelsWithoutDiscovery(t *testing.T) {
+	cs := newClientSession(t)
+
+	// the mock engine cannot list models
+	w := cs.doRequest(t, http.MethodGet, "/api/models", "", requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"models":[]}`, w.Body.String())
+}
+
+// runWithRateLimitingModes runs the provided test function twice: once with
+// rate limiting enabled and once with it disabled. The subtest name includes
+// the mode (e.g. "RATE_LIMITING=true" or "RATE_LIMITING=false").
+func runWithRateLimitingModes(t *testing.T, fn func(t *testing.T, enabled bool)) {
+	for _, enabled := range []bool{true, false} {
+		name := "disabled"
+		if enabled {
+			name = "enabled"
 		}
-		subName := fmt.Sprintf("%s (RATE_LIMITING=%s)", mode.name, display)
-		// capture mode for closure
-		enabled := mode.env == "true"
-		t.Run(subName, func(t *testing.T) {
-			// Set environment for this subtest. Use t.Setenv so it's cleaned up automatically.
-			t.Setenv("RATE_LIMITING_ENABLED", mode.env)
+		t.Run(fmt.Sprintf("%s (RATE_LIMITING=%t)", name, enabled), func(t *testing.T) {
 			fn(t, enabled)
 		})
 	}
 }
 
+// newRateLimitedSession is newClientSession on a server with rate limiting enabled or not.
+func newRateLimitedSession(t *testing.T, enabled bool) *clientSession {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	config := api.DefaultConfig()
+	config.SessionTTL, config.ContextTTL, config.SecretKey = time.Minute, time.Minute, testSecret
+	config.RateLimit.Enabled = enabled
+	server := api.NewServerWithConfig(service.NewBabelService(babel.NewBabel(babel.NewMockAISystem()), time.Minute), config)
+	return &clientSession{server: server, cookies: issueSession(t, server)}
+}
+
 // doSessionRequest performs a GET /session request against the provided server
 // and returns the HTTP status code without asserting. Caller should not assume
 // the code is 200.
@@ -249,7 +528,7 @@ func doSessionRequest(s *api.Server) int {
 func TestRateLimiting(t *testing.T) {
 	runWithRateLimitingModes(t, func(t *testing.T, enabled bool) {
 		t.Run("Protection", func(t *testing.T) {
-			cs := newClientSession(t)
+			cs := newRateLimitedSession(t, enabled)
 
 			// Check session endpoint under load
 			const sessionAttempts = 20
@@ -310,7 +589,7 @@ func TestRateLimiting(t *testing.T) {
 		})
 
 		t.Run("Recovery", func(t *testing.T) {
-			cs := newClientSession(t)
+			cs := newRateLimitedSession(t, enabled)
 
 			// Make rapid requests to potentially trigger rate limiting
 			hit429 := 0
@@ -353,7 +632,7 @@ func TestRateLimiting(t *testing.T) {
 		})
 
 		t.Run("DifferentEndpoints", func(t *testing.T) {
-			cs := newClientSession(t)
+			cs := newRateLimitedSession(t, enabled)
 
 			endpoints := []struct {
 				path string
@@ -388,3 +667,240 @@ func TestRateLimiting(t *testing.T) {
 		})
 	})
 }
+
+func TestChaosAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	chaos, err := babel.NewChaos(babel.ChaosConfig{})
+	require.NoError(t, err)
+	svc := service.NewBabelService(babel.NewBabel(chaos.Wrap("mock", babel.NewMockAISystem())), time.Minute)
+	server := api.NewServerWithTTLs(svc, time.Minute, time.Minute, testSecret)
+	server.EnableChaos(chaos)
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	admin := func(method, body, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/chaos", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.Engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := admin(http.MethodPut, `{"errorRate": 1, "errorStatus": 429, "latency": {"min": "1ms"}}`, "127.0.0.1:4711")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.JSONEq(t, `{"latency": {"min": "1ms"}, "timeoutRate": 0, "errorRate": 1, "errorStatus": 429, "emptyRate": 0, "garbageRate": 0}`, w.Body.String())
+
+	w = cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello. I like pizza.","lang":"es"}`, requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Contains(t, w.Body.String(), "injected by chaos")
+
+	require.Equal(t, http.StatusBadRequest, admin(http.MethodPut, `{"errorRate": 2}`, "[::1]:4711").Code)
+	require.Equal(t, http.StatusForbidden, admin(http.MethodDelete, "", "192.0.2.1:4711").Code, "only local requests may change the faults")
+
+	require.Equal(t, http.StatusOK, admin(http.MethodDelete, "", "[::1]:4711").Code)
+	w = cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello. I like pizza.","lang":"es"}`, requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewBabelService(babel.NewBabel(babel.NewMockAISystem()), time.Minute)
+	config := api.DefaultConfig()
+	config.SessionTTL, config.ContextTTL, config.SecretKey = time.Minute, time.Minute, testSecret
+	server := api.NewServerWithConfig(svc, config)
+	fail := false
+	server.EnableReload(func(context.Context) error {
+		if fail {
+			return fmt.Errorf("translation.engine (ENGINE): unknown engine %q", "nope")
+		}
+		svc.SetBackend(babel.NewBabel(babel.NewMockAISystem()))
+		reloaded := config
+		reloaded.RateLimit.Enabled = true
+		reloaded.RateLimit.Session = api.Rate{Limit: 2, Period: time.Minute}
+		server.Reconfigure(reloaded)
+		server.SetGenerationLimits(babel.GenerationLimits{Models: []string{"aya-expanse:32b"}})
+		return nil
+	})
+	cs := &clientSession{server: server, cookies: issueSession(t, server)}
+
+	w := cs.doRequest(t, http.MethodPost, "/api/translate/start", `{"source":"Hello. I like pizza.","lang":"es"}`, requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var start startResp
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &start))
+
+	reload := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.Engine.ServeHTTP(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusForbidden, reload("192.0.2.1:4711").Code, "only local requests may reload")
+	w = reload("127.0.0.1:4711")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// the new settings apply at once
+	require.Equal(t, http.StatusOK, doSessionRequest(server))
+	require.Equal(t, http.StatusOK, doSessionRequest(server))
+	require.Equal(t, http.StatusTooManyRequests, doSessionRequest(server))
+	w = cs.doRequest(t, http.MethodGet, "/api/models", "", requestOptions{IncludeSessionToken: true})
+	require.Contains(t, w.Body.String(), "aya-expanse:32b")
+
+	// contexts and sessions from before the reload keep working
+	w = cs.doRequest(t, http.MethodPost, "/api/translate/improve", fmt.Sprintf(`{"contextId":%q,"feedback":"more formal"}`, start.ContextID), requestOptions{IncludeSessionToken: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	fail = true
+	w = reload("[::1]:4711")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	require.Contains(t, w.Body.String(), "unknown engine")
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	slow := babel.NewMockAISystemWithDelay(300 * time.Millisecond)
+	config := api.DefaultConfig()
+	config.SecretKey, config.DrainTimeout = testSecret, 5*time.Second
+	server := api.NewServerWithConfig(service.NewBabelService(babel.NewBabel(slow), time.Minute), config)
+	var hooks []string
+	server.OnShutdown(func(context.Context) error { hooks = append(hooks, "flush stores"); return nil })
+	server.OnShutdown(func(context.Context) error { hooks = append(hooks, "stop workers"); return nil })
+	withFrontend(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+	base := "http://" + listener.Addr().String()
+	res, err := client.Get(base + "/readyz")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	_ = res.Body.Close()
+	res, err = client.Get(base + "/session")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	// a translation in flight when the shutdown begins still completes
+	finished := make(chan int, 1)
+	go func() {
+		res, err := client.Post(base+"/api/translate/start", "application/json", strings.NewReader(`{"source":"Hello. I like pizza.","lang":"es"}`))
+		if err != nil {
+			finished <- 0
+			return
+		}
+		_ = res.Body.Close()
+		finished <- res.StatusCode
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	require.Eventually(t, func() bool { return !server.Ready() }, time.Second, 5*time.Millisecond)
+	require.Equal(t, http.StatusOK, <-finished)
+	require.NoError(t, <-served)
+	require.Equal(t, []string{"stop workers", "flush stores"}, hooks, "hooks run in reverse order once serving stopped")
+
+	_, err = client.Get(base + "/readyz")
+	require.Error(t, err, "no connections are accepted after shutdown")
+}
+
+func TestShutdownCancelsRequestsAfterDrainTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	slow := babel.NewMockAISystemWithDelay(5 * time.Second)
+	config := api.DefaultConfig()
+	config.SecretKey, config.DrainTimeout = testSecret, 100*time.Millisecond
+	server := api.NewServerWithConfig(service.NewBabelService(babel.NewBabel(slow), time.Minute), config)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+	base := "http://" + listener.Addr().String()
+	res, err := client.Get(base + "/session")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	go func() {
+		res, err := client.Post(base+"/api/translate/start", "application/json", strings.NewReader(`{"source":"Hello.","lang":"es"}`))
+		if err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	cancel()
+	<-served
+	require.Less(t, time.Since(start), 2*time.Second, "the drain timeout bounds the shutdown")
+}
+
+// withFrontend runs the test in a directory holding a frontend build.
+func withFrontend(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "frontend", "dist"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "frontend", "dist", "index.html"), []byte("<html></html>"), 0o644))
+	t.Chdir(dir)
+}
+
+// unhealthyAISystem fails its health probes.
+type unhealthyAISystem struct {
+	*babel.MockAISystem
+}
+
+func (unhealthyAISystem) HealthCheck(context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	probe := func(server *api.Server, path string) (int, api.ReadinessResponse) {
+		w := httptest.NewRecorder()
+		server.Engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var response api.ReadinessResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		withFrontend(t)
+		server := newTestServer(t)
+		code, response := probe(server, "/readyz")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "ready", response.Status)
+		require.Equal(t, "ok", response.Checks["frontend"].Status)
+		require.Equal(t, "ok", response.Checks["engine"].Status)
+		require.False(t, response.Checks["engine"].CheckedAt.IsZero())
+	})
+
+	t.Run("frontend not built", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		server := newTestServer(t)
+		code, response := probe(server, "/readyz")
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, "not ready", response.Status)
+		require.Contains(t, response.Checks["frontend"].Error, "npm run build")
+		require.Equal(t, "ok", response.Checks["engine"].Status)
+
+		code, response = probe(server, "/healthz")
+		require.Equal(t, http.StatusOK, code, "the process is alive regardless")
+		require.Equal(t, "ok", response.Status)
+	})
+
+	t.Run("engine failing", func(t *testing.T) {
+		withFrontend(t)
+		gin.SetMode(gin.TestMode)
+		svc := service.NewBabelService(babel.NewBabel(unhealthyAISystem{babel.NewMockAISystem()}), time.Minute)
+		server := api.NewServerWithTTLs(svc, time.Minute, time.Minute, testSecret)
+		code, response := probe(server, "/readyz")
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, "failing", response.Checks["engine"].Status)
+		require.Equal(t, "connection refused", response.Checks["engine"].Error)
+	})
+}
@@ -16,6 +16,13 @@ func newSessionStore(ttl time.Duration) *sessionStore {
 	return &sessionStore{ttl: ttl, data: make(map[string]time.Time)}
 }
 
+// SetTTL changes how long sessions live, existing ones included.
+func (s *sessionStore) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+}
+
 func (s *sessionStore) Put(token string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -63,6 +70,13 @@ func newContextStore(ttl time.Duration) *contextStore {
 	}
 }
 
+// SetTTL changes how long contexts live, existing ones included.
+func (c *contextStore) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
 func (c *contextStore) Put(session, ctxID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,69 @@
+package api
+
+import (
+	babel "BabelBridge/backend"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventStream answers a streamed request with server-sent events. The stream only starts with the first event,
+// so a request failing before any text arrived still gets a plain JSON error with a meaningful status.
+type eventStream struct {
+	c       *gin.Context
+	started bool
+}
+
+func newEventStream(c *gin.Context) *eventStream {
+	return &eventStream{c: c}
+}
+
+func (e *eventStream) send(event string, data any) {
+	if !e.started {
+		e.c.Header("Cache-Control", "no-cache")
+		e.c.Header("X-Accel-Buffering", "no")
+		e.started = true
+	}
+	e.c.SSEvent(event, data)
+	e.c.Writer.Flush()
+}
+
+// delta sends a piece of text, failing once the client went away so that generation stops.
+func (e *eventStream) delta(text string) error {
+	e.send("delta", gin.H{"text": text})
+	return e.c.Request.Context().Err()
+}
+
+func (e *eventStream) done(response any) {
+	e.send("done", response)
+}
+
+func (e *eventStream) fail(err error) {
+	if e.started {
+		e.send("error", gin.H{"error": err.Error()})
+		return
+	}
+	e.c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+}
+
+// errorStatus maps the errors a streamed request may fail with to a status code.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, babel.ErrStreamingUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, babel.ErrImproveUnsupported):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// rejectStreaming answers with 400 when the request asks for streaming the engine cannot do, and reports
+// whether it did.
+func (s *Server) rejectStreaming(c *gin.Context, stream bool) bool {
+	if stream && !s.svc.Capabilities().Streaming {
+		c.JSON(http.StatusBadRequest, gin.H{"error": babel.ErrStreamingUnsupported.Error()})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package babel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AnthropicConfig configures the Anthropic Messages API backend.
+type AnthropicConfig struct {
+	APIKey string
+	// Model defaults to claude-3-5-haiku-latest
+	Model string
+	// BaseURL defaults to https://api.anthropic.com
+	BaseURL string
+	// MaxTokens limits the length of a completion, which the Messages API requires. Defaults to 4096.
+	MaxTokens int
+	// ContextLength is the model's context window in tokens, defaults to 200000
+	ContextLength int
+	// Options are the default generation options, requests may override them. Options.MaxTokens wins over MaxTokens.
+	Options GenerationOptions
+	// Version is sent as the anthropic-version header, defaults to 2023-06-01
+	Version string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// AnthropicBackend talks to the Anthropic Messages API.
+type AnthropicBackend struct {
+	config AnthropicConfig
+}
+
+// NewAnthropicBackend creates an Anthropic backend, filling in defaults for unset configuration.
+func NewAnthropicBackend(config AnthropicConfig) *AnthropicBackend {
+	if config.Model == "" {
+		config.Model = "claude-3-5-haiku-latest"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.anthropic.com"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = 4096
+	}
+	if config.ContextLength <= 0 {
+		config.ContextLength = 200000
+	}
+	if config.Version == "" {
+		config.Version = "2023-06-01"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &AnthropicBackend{config: config}
+}
+
+func (a *AnthropicBackend) Capabilities() Capabilities {
+	// Messages only carry text parts, so images are not offered even though the API takes them
+	return Capabilities{
+		Streaming:          true,
+		ContextLength:      a.config.ContextLength,
+		SamplingParameters: []string{ParamTemperature, ParamTopP, ParamMaxTokens, ParamStop},
+	}
+}
+
+// ListModels returns the configured model, other models are only usable when allowed for requests.
+func (a *AnthropicBackend) ListModels(context.Context) ([]ModelInfo, error) {
+	return []ModelInfo{{Name: a.config.Model, Capabilities: a.Capabilities()}}, nil
+}
+
+// HealthCheck lists a single model, which checks the key without generating anything.
+func (a *AnthropicBackend) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.config.BaseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", a.config.APIKey)
+	req.Header.Set("anthropic-version", a.config.Version)
+	return doJSON(a.config.HTTPClient, req, "anthropic", anthropicErrorMessage, nil)
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        []anthropicBlock   `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func anthropicErrorMessage(body []byte) string {
+	var e anthropicError
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Error.Message
+}
+
+func toAnthropicBlocks(parts []Part) []anthropicBlock {
+	blocks := make([]anthropicBlock, 0, len(parts))
+	for _, p := range parts {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+	}
+	return blocks
+}
+
+// toAnthropicMessages maps the history to Anthropic's separate system prompt and strictly alternating user and
+// assistant turns. Every part becomes its own text block, consecutive messages of the same role are merged.
+func toAnthropicMessages(messages []Message) ([]anthropicBlock, []anthropicMessage) {
+	var system []anthropicBlock
+	var turns []anthropicMessage
+	for _, m := range messages {
+		switch m.Role {
+		case RoleSystem:
+			system = append(system, toAnthropicBlocks(m.Parts)...)
+		case RoleUser, RoleAssistant:
+			role := string(m.Role)
+			if n := len(turns); n > 0 && turns[n-1].Role == role {
+				turns[n-1].Content = append(turns[n-1].Content, toAnthropicBlocks(m.Parts)...)
+				continue
+			}
+			turns = append(turns, anthropicMessage{Role: role, Content: toAnthropicBlocks(m.Parts)})
+		}
+	}
+	return system, turns
+}
+
+func (a *AnthropicBackend) newRequest(ctx context.Context, messages []Message, stream bool) (*http.Request, error) {
+	system, turns := toAnthropicMessages(messages)
+	options := generationOptions(ctx, a.config.Options)
+	maxTokens := a.config.MaxTokens
+	if options.MaxTokens != nil {
+		maxTokens = *options.MaxTokens
+	}
+	// the Messages API has no seed
+	req, err := newJSONRequest(ctx, a.config.BaseURL+"/v1/messages", anthropicRequest{
+		Model:         options.modelOr(a.config.Model),
+		MaxTokens:     maxTokens,
+		System:        system,
+		Messages:      turns,
+		Stream:        stream,
+		Temperature:   options.Temperature,
+		TopP:          options.TopP,
+		StopSequences: options.Stop,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", a.config.APIKey)
+	req.Header.Set("anthropic-version", a.config.Version)
+	return req, nil
+}
+
+func (a *AnthropicBackend) Chat(ctx context.Context, messages []Message) (string, error) {
+	req, err := a.newRequest(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+
+	var res anthropicResponse
+	if err := doJSON(a.config.HTTPClient, req, "anthropic", anthropicErrorMessage, &res); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, block := range res.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Usage = Usage{PromptTokens: res.Usage.InputTokens, CompletionTokens: res.Usage.OutputTokens}
+	}
+	return text.String(), nil
+}
+
+// ChatStream streams the completion, calling onDelta with every piece of text as it arrives, and returns the whole
+// completion once the stream ends.
+func (a *AnthropicBackend) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	req, err := a.newRequest(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if err := checkResponse(res, "anthropic", anthropicErrorMessage); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	var usage Usage
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// event names are repeated in the payload's type field
+			continue
+		}
+
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Usage anthropicUsage `json:"usage"`
+			anthropicError
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return "", fmt.Errorf("anthropic: decoding stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" {
+				continue
+			}
+			text.WriteString(event.Delta.Text)
+			if onDelta != nil {
+				if err := onDelta(event.Delta.Text); err != nil {
+					return "", err
+				}
+			}
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "error":
+			return "", &APIError{Provider: "anthropic", StatusCode: http.StatusInternalServerError, Message: event.Error.Message}
+		case "message_stop":
+			if info := callInfoFromContext(ctx); info != nil {
+				info.Usage = usage
+			}
+			return text.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("anthropic: stream ended before message_stop")
+}
@@ -0,0 +1,255 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type anthropicBlocks []struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (b anthropicBlocks) text() string {
+	var texts []string
+	for _, block := range b {
+		texts = append(texts, block.Text)
+	}
+	return strings.Join(texts, "|")
+}
+
+func (b anthropicBlocks) parts() []babel.Part {
+	var parts []babel.Part
+	for _, block := range b {
+		parts = append(parts, babel.Part{Text: block.Text})
+	}
+	return parts
+}
+
+type anthropicRequest struct {
+	Model     string          `json:"model"`
+	MaxTokens int             `json:"max_tokens"`
+	System    anthropicBlocks `json:"system"`
+	Messages  []struct {
+		Role    string          `json:"role"`
+		Content anthropicBlocks `json:"content"`
+	} `json:"messages"`
+	Stream        bool     `json:"stream"`
+	Temperature   *float64 `json:"temperature"`
+	TopP          *float64 `json:"top_p"`
+	StopSequences []string `json:"stop_sequences"`
+}
+
+// messages maps the request back to the history it was made from.
+func (r anthropicRequest) messages() []babel.Message {
+	var messages []babel.Message
+	if len(r.System) > 0 {
+		messages = append(messages, babel.Message{Role: babel.RoleSystem, Parts: r.System.parts()})
+	}
+	for _, m := range r.Messages {
+		messages = append(messages, babel.Message{Role: babel.Role(m.Role), Parts: m.Content.parts()})
+	}
+	return messages
+}
+
+// newFakeAnthropic serves the Messages API, answering every request with reply and recording the requests it saw.
+func newFakeAnthropic(t *testing.T, reply string, requests *[]anthropicRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+
+		var req anthropicRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"type":"message","role":"assistant","content":[{"type":"text","text":%q}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":5}}`, reply)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		write := func(event, data string) { _, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data) }
+		write("message_start", `{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`)
+		write("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
+		for _, word := range strings.SplitAfter(reply, " ") {
+			write("content_block_delta", fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}`, word))
+		}
+		write("content_block_stop", `{"type":"content_block_stop","index":0}`)
+		write("message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}`)
+		write("message_stop", `{"type":"message_stop"}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnthropicMapsHistory(t *testing.T) {
+	var requests []anthropicRequest
+	server := newFakeAnthropic(t, "Hola. Me gusta la pizza.", &requests)
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", Model: "test-model", BaseURL: server.URL})
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	translationContext, result, err := babel.NewBabel(a).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, babel.Usage{PromptTokens: 12, CompletionTokens: 5}, info.Usage)
+
+	_, err = translationContext.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	first, second := requests[0], requests[1]
+	require.Equal(t, "test-model", first.Model)
+	require.Equal(t, 4096, first.MaxTokens)
+	require.Contains(t, first.System.text(), "You are a translation and rewriting engine")
+	require.Len(t, first.Messages, 1)
+	require.Equal(t, "user", first.Messages[0].Role)
+	require.Equal(t, "Hello. I like pizza.", first.Messages[0].Content.text())
+
+	// the system prompt never appears as a turn, and turns alternate
+	require.Equal(t, first.System, second.System)
+	require.Len(t, second.Messages, 3)
+	for i, role := range []string{"user", "assistant", "user"} {
+		require.Equal(t, role, second.Messages[i].Role)
+	}
+	require.Equal(t, "Hola. Me gusta la pizza.", second.Messages[1].Content.text())
+	require.True(t, strings.HasPrefix(second.Messages[2].Content.text(), "Improve: Make it more formal"))
+}
+
+func TestAnthropicMergesConsecutiveTurns(t *testing.T) {
+	var requests []anthropicRequest
+	server := newFakeAnthropic(t, "ok", &requests)
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := a.Chat(context.Background(), []babel.Message{
+		babel.SystemMessage("first rule"),
+		babel.SystemMessage("second rule"),
+		babel.UserMessage("one"),
+		babel.UserMessage("two"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "first rule|second rule", requests[0].System.text())
+	require.Len(t, requests[0].Messages, 1)
+	require.Equal(t, "one|two", requests[0].Messages[0].Content.text())
+}
+
+func TestAnthropicRoundTrip(t *testing.T) {
+	var requests []anthropicRequest
+	server := newFakeAnthropic(t, "ok", &requests)
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := a.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	require.Equal(t, withoutMetadata(conversation), requests[0].messages())
+}
+
+func TestAnthropicStreaming(t *testing.T) {
+	var requests []anthropicRequest
+	server := newFakeAnthropic(t, "Hallo. Ich mag Pizza.", &requests)
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	var deltas []string
+	ctx, info := babel.WithCallInfo(context.Background())
+	result, err := a.ChatStream(ctx, []babel.Message{
+		babel.SystemMessage("Translate to German"),
+		babel.UserMessage("Hello. I like pizza."),
+	}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, requests[0].Stream)
+	require.Equal(t, "Hallo. Ich mag Pizza.", result)
+	require.Equal(t, []string{"Hallo. ", "Ich ", "mag ", "Pizza."}, deltas)
+	require.Equal(t, babel.Usage{PromptTokens: 12, CompletionTokens: 5}, info.Usage)
+
+	var _ babel.StreamingAISystem = a
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer server.Close()
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := a.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	var apiErr *babel.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	require.Equal(t, "slow down", apiErr.Message)
+	require.Equal(t, "3", apiErr.Header.Get("Retry-After"))
+}
+
+func TestAnthropicGenerationOptions(t *testing.T) {
+	var requests []anthropicRequest
+	server := newFakeAnthropic(t, "ok", &requests)
+	a := babel.NewAnthropicBackend(babel.AnthropicConfig{
+		APIKey:  "test-key",
+		BaseURL: server.URL,
+		Options: babel.GenerationOptions{MaxTokens: integer(1024), TopP: float(0.9)},
+	})
+
+	_, err := a.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Equal(t, 1024, requests[0].MaxTokens)
+	require.Equal(t, float(0.9), requests[0].TopP)
+	require.Nil(t, requests[0].Temperature)
+
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{
+		Model:       "claude-large",
+		Temperature: float(0),
+		MaxTokens:   integer(64),
+		Stop:        []string{"END"},
+	})
+	_, err = a.Chat(ctx, []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Equal(t, "claude-large", requests[1].Model)
+	require.Equal(t, 64, requests[1].MaxTokens)
+	require.Equal(t, float(0), requests[1].Temperature)
+	require.Equal(t, []string{"END"}, requests[1].StopSequences)
+}
+
+func TestAnthropicHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v1/models", r.URL.Path, "no message is sent")
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+		if r.Header.Get("x-api-key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"claude-3-5-haiku-latest","type":"model"}],"has_more":true}`))
+	}))
+	defer server.Close()
+
+	require.NoError(t, babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key", BaseURL: server.URL}).HealthCheck(context.Background()))
+	err := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "wrong", BaseURL: server.URL}).HealthCheck(context.Background())
+	require.ErrorContains(t, err, "invalid x-api-key")
+}
+
+func TestAnthropicCapabilities(t *testing.T) {
+	capabilities := babel.NewAnthropicBackend(babel.AnthropicConfig{APIKey: "test-key"}).Capabilities()
+	require.True(t, capabilities.Streaming)
+	// images stay off until messages can carry image parts
+	require.False(t, capabilities.Images)
+}
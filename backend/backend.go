@@ -3,18 +3,44 @@ package babel
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/openai/openai-go"
 	"golang.org/x/text/language"
 	"golang.org/x/text/language/display"
 )
 
 type Backend struct {
-	backend AISystem
+	backend    AISystem
+	router     *LanguageRouter
+	operations map[Operation]AISystem
+	candidates []Candidate
 }
 
+// Operation identifies the kind of work a Backend call does, so that each can be served by a different model.
+type Operation string
+
+const (
+	OperationIdentify Operation = "identify"
+	OperationPreview  Operation = "preview"
+	OperationStart    Operation = "start"
+	OperationImprove  Operation = "improve"
+)
+
+// Operations lists every operation in the order they usually happen.
+var Operations = []Operation{OperationIdentify, OperationPreview, OperationStart, OperationImprove}
+
 type AISystem interface {
-	Chat(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion) (string, error)
+	Chat(ctx context.Context, messages []Message) (string, error)
+	// Capabilities describes what the backend supports. It must be cheap, callers do not cache it.
+	Capabilities() Capabilities
+}
+
+// StreamingAISystem is implemented by backends that can stream a completion as it is generated.
+type StreamingAISystem interface {
+	AISystem
+	// ChatStream calls onDelta with every piece of the completion as it arrives and returns the whole completion.
+	// An error returned by onDelta aborts the stream.
+	ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error)
 }
 
 func LanguageTagToString(tag language.Tag) string {
@@ -27,13 +53,210 @@ func NewBabel(backend AISystem) *Backend {
 	}
 }
 
+// SetOperationBackend serves op with system instead of the default backend, e.g. a small fast model for
+// identification and previews. Language routes take precedence for start and improve, so that a routed
+// translation keeps its model. It must be called before the Backend is used.
+func (b *Backend) SetOperationBackend(op Operation, system AISystem) {
+	if b.operations == nil {
+		b.operations = make(map[Operation]AISystem)
+	}
+	b.operations[op] = system
+}
+
+// systemFor returns the backend configured for op, or the default backend.
+func (b *Backend) systemFor(op Operation) AISystem {
+	if system, ok := b.operations[op]; ok {
+		return system
+	}
+	return b.backend
+}
+
+// Capabilities is what every backend that may serve a call supports: the default backend, the routes and the
+// operation tiers. Comparison candidates are left out, they are only used by Compare.
+func (b *Backend) Capabilities() Capabilities {
+	systems := []AISystem{b.backend}
+	if b.router != nil {
+		systems = b.router.systems()
+	}
+	for _, op := range Operations {
+		if system, ok := b.operations[op]; ok {
+			systems = append(systems, system)
+		}
+	}
+	return capabilitiesOf(systems...)
+}
+
+// HealthCheck probes the default backend, which also serves language identification.
+func (b *Backend) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, b.backend)
+}
+
+// FailoverStatus returns the health of the members when the default backend is a failover chain, nil otherwise.
+func (b *Backend) FailoverStatus() []FailoverStatus {
+	if failover, ok := b.backend.(*FailoverAISystem); ok {
+		return failover.Status()
+	}
+	return nil
+}
+
+// NewBabelWithRouter creates a Backend that picks the AISystem for each translation by language pair.
+// Language identification uses the router's fallback backend.
+func NewBabelWithRouter(router *LanguageRouter) *Backend {
+	return &Backend{
+		backend: router.fallback,
+		router:  router,
+	}
+}
+
 type TranslationContext struct {
-	history        []openai.ChatCompletionMessageParamUnion
+	history        []Message
 	backend        AISystem
 	outputLanguage language.Tag
+	route          string
+	affinity       string
+	// options are the generation options the translation was started with, improvements keep using them
+	options GenerationOptions
+}
+
+// LastResult returns the most recent translation produced in this context.
+func (t *TranslationContext) LastResult() string {
+	if len(t.history) == 0 {
+		return ""
+	}
+	last := t.history[len(t.history)-1]
+	if last.Role != RoleAssistant {
+		return ""
+	}
+	return last.Text()
+}
+
+// Route returns the name of the language route serving this context, or an empty string for the default backend.
+func (t *TranslationContext) Route() string {
+	return t.route
 }
 
 func (b *Backend) NewTranslation(ctx context.Context, input string, outputLanguage language.Tag) (*TranslationContext, string, error) {
+	return b.NewTranslationFrom(ctx, input, language.Und, outputLanguage)
+}
+
+// NewTranslationFrom starts a translation of input written in sourceLanguage, which may be language.Und when unknown.
+// The backend selected for the language pair stays with the returned context for all improvements.
+func (b *Backend) NewTranslationFrom(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (*TranslationContext, string, error) {
+	return b.newTranslation(ctx, OperationStart, input, sourceLanguage, outputLanguage, nil)
+}
+
+// NewTranslationStream is NewTranslationFrom calling onDelta with every piece of the translation as it arrives.
+// It fails with ErrStreamingUnsupported unless Capabilities reports streaming.
+func (b *Backend) NewTranslationStream(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag, onDelta func(string) error) (*TranslationContext, string, error) {
+	if !b.Capabilities().Streaming {
+		return nil, "", ErrStreamingUnsupported
+	}
+	return b.newTranslation(ctx, OperationStart, input, sourceLanguage, outputLanguage, onDelta)
+}
+
+// Preview translates input without keeping a context for improvements. It is served by the preview backend when
+// one is configured, which is meant for cheap calls made while the user is typing.
+func (b *Backend) Preview(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (string, error) {
+	_, result, err := b.newTranslation(ctx, OperationPreview, input, sourceLanguage, outputLanguage, nil)
+	return result, err
+}
+
+// PreviewStream is Preview calling onDelta with every piece of the translation as it arrives.
+// It fails with ErrStreamingUnsupported unless Capabilities reports streaming.
+func (b *Backend) PreviewStream(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag, onDelta func(string) error) (string, error) {
+	if !b.Capabilities().Streaming {
+		return "", ErrStreamingUnsupported
+	}
+	_, result, err := b.newTranslation(ctx, OperationPreview, input, sourceLanguage, outputLanguage, onDelta)
+	return result, err
+}
+
+func (b *Backend) newTranslation(ctx context.Context, op Operation, input string, sourceLanguage, outputLanguage language.Tag, onDelta func(string) error) (*TranslationContext, string, error) {
+	system, route := b.systemFor(op), ""
+	_, tiered := b.operations[op]
+	// a dedicated preview backend wins over language routes, previews should stay cheap
+	if b.router != nil && !(op == OperationPreview && tiered) {
+		if name, routed := b.router.Select(sourceLanguage, outputLanguage); name != "" {
+			route, system = name, routed
+		}
+	}
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Route = route
+	}
+
+	translationContext, result, err := b.translate(ctx, op, system, input, outputLanguage, onDelta)
+	if err != nil {
+		return nil, "", err
+	}
+	translationContext.route = route
+
+	// routed contexts stick with their route, others may move to a dedicated improvement model
+	if improver, ok := b.operations[OperationImprove]; ok && route == "" {
+		translationContext.backend = improver
+	}
+
+	return translationContext, result, nil
+}
+
+// translate runs the initial translation on system and returns a context improving on the same system. Input
+// longer than the system's chunk size is translated chunk by chunk, the context holds the whole text either way.
+func (b *Backend) translate(ctx context.Context, op Operation, system AISystem, input string, outputLanguage language.Tag, onDelta func(string) error) (*TranslationContext, string, error) {
+	affinity := newAffinityKey()
+	ctx = WithAffinityKey(ctx, affinity)
+
+	info := callInfoFromContext(ctx)
+	var usage Usage
+	var completion strings.Builder
+	chunks, separators := splitChunks(input, system.Capabilities().ChunkSize())
+	for i, chunk := range chunks {
+		if info != nil {
+			info.Usage = Usage{}
+		}
+		result, err := chatStream(ctx, system, translationMessages(op, chunk, outputLanguage), onDelta)
+		if err != nil {
+			return nil, "", err
+		}
+		if info != nil {
+			usage.PromptTokens += info.Usage.PromptTokens
+			usage.CompletionTokens += info.Usage.CompletionTokens
+		}
+		completion.WriteString(result)
+		completion.WriteString(separators[i])
+		if onDelta != nil && separators[i] != "" {
+			if err := onDelta(separators[i]); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if info != nil {
+		info.Usage = usage
+	}
+	completionMessage := completion.String()
+
+	history := translationMessages(op, input, outputLanguage)
+	history = append(history, AssistantMessage(completionMessage))
+
+	return &TranslationContext{
+		history:        history,
+		backend:        system,
+		outputLanguage: outputLanguage,
+		affinity:       affinity,
+		options:        generationOptions(ctx, GenerationOptions{}),
+	}, completionMessage, nil
+}
+
+// translationMessages is the conversation asking for a translation of input into outputLanguage.
+func translationMessages(op Operation, input string, outputLanguage language.Tag) []Message {
+	return []Message{
+		SystemMessage(translationPrompt(outputLanguage)),
+		UserMessage(input).
+			WithMetadata(MetadataOperation, string(op)).
+			WithMetadata(MetadataTargetLanguage, outputLanguage.String()),
+	}
+}
+
+// translationPrompt builds the system prompt instructing a model to translate into outputLanguage.
+func translationPrompt(outputLanguage language.Tag) string {
 	targetLang := LanguageTagToString(outputLanguage)
 
 	rules := []string{
@@ -60,42 +283,22 @@ func (b *Backend) NewTranslation(ctx context.Context, input string, outputLangua
 		rulesText += fmt.Sprintf("%d. %s\n", i+1, rule)
 	}
 
-	systemPrompt := fmt.Sprintf(
+	return fmt.Sprintf(
 		"You are a translation and rewriting engine. "+
 			"By default, translate ALL user input into %s unless the user explicitly asks you to improve or rewrite existing %s text.\n"+
 			"CRITICAL RULES:\n"+
 			"%s\n"+
 			"Just output the pure %s text as requested.",
 		targetLang, targetLang, rulesText, targetLang)
-
-	baseParams := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(systemPrompt),
-		openai.UserMessage(input),
-	}
-
-	completionMessage, err := b.backend.Chat(ctx, baseParams)
-
-	if err != nil {
-		return nil, "", err
-	}
-
-	history := append([]openai.ChatCompletionMessageParamUnion{}, baseParams...)
-	history = append(history, openai.AssistantMessage(completionMessage))
-
-	return &TranslationContext{
-		history:        history,
-		backend:        b.backend,
-		outputLanguage: outputLanguage,
-	}, completionMessage, nil
 }
 
 func (b *Backend) IdentifyLanguage(ctx context.Context, input string) (language.Tag, error) {
-	baseParams := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage("Identify the language of the following text. Output ONLY the language tag in BCP 47 format."),
-		openai.UserMessage(input),
+	baseParams := []Message{
+		SystemMessage("Identify the language of the following text. Output ONLY the language tag in BCP 47 format."),
+		UserMessage(input).WithMetadata(MetadataOperation, string(OperationIdentify)),
 	}
 
-	completionMessage, err := b.backend.Chat(ctx, baseParams)
+	completionMessage, err := b.systemFor(OperationIdentify).Chat(ctx, baseParams)
 	if err != nil {
 		return language.Und, err
 	}
@@ -109,21 +312,47 @@ func (b *Backend) IdentifyLanguage(ctx context.Context, input string) (language.
 }
 
 func (t *TranslationContext) Improve(ctx context.Context, feedback string) (string, error) {
+	return t.improve(ctx, feedback, nil)
+}
+
+// ImproveStream is Improve calling onDelta with every piece of the improved text as it arrives.
+// It fails with ErrStreamingUnsupported when the backend of the context cannot stream.
+func (t *TranslationContext) ImproveStream(ctx context.Context, feedback string, onDelta func(string) error) (string, error) {
+	if t.backend != nil && !t.backend.Capabilities().Streaming {
+		return "", ErrStreamingUnsupported
+	}
+	return t.improve(ctx, feedback, onDelta)
+}
+
+func (t *TranslationContext) improve(ctx context.Context, feedback string, onDelta func(string) error) (string, error) {
+	if t.backend == nil {
+		return "", ErrImproveUnsupported
+	}
+
 	messages := append(t.history,
-		openai.UserMessage(fmt.Sprintf(
+		UserMessage(fmt.Sprintf(
 			"Improve: %s\n\nApply these instructions to the most recent %s text you produced. Respond with ONLY the improved %s text.",
 			feedback,
 			LanguageTagToString(t.outputLanguage),
 			LanguageTagToString(t.outputLanguage),
-		)),
+		)).
+			WithMetadata(MetadataOperation, string(OperationImprove)).
+			WithMetadata(MetadataTargetLanguage, t.outputLanguage.String()),
 	)
 
-	completionMessage, err := t.backend.Chat(ctx, messages)
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Route = t.route
+	}
+	ctx = WithAffinityKey(ctx, t.affinity)
+	// options given for this call win over those the translation started with
+	ctx = context.WithValue(ctx, generationOptionsKey{}, generationOptions(ctx, t.options))
+
+	completionMessage, err := chatStream(ctx, t.backend, messages, onDelta)
 	if err != nil {
 		return "", err
 	}
 
-	t.history = append(messages, openai.AssistantMessage(completionMessage))
+	t.history = append(messages, AssistantMessage(completionMessage))
 
 	return completionMessage, nil
 }
@@ -3,7 +3,12 @@ package babel_test
 import (
 	"BabelBridge/backend"
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 
@@ -11,6 +16,45 @@ import (
 	"golang.org/x/text/language"
 )
 
+// testEngines returns the engines the translation tests run against. The real engines are replayed from cassettes
+// in testdata/cassettes, set BABEL_RECORD to a comma separated list of engines, or "all", to record them anew.
+func testEngines() map[string]func(t *testing.T) babel.AISystem {
+	return map[string]func(t *testing.T) babel.AISystem{
+		"mock": func(t *testing.T) babel.AISystem { return babel.NewMockAISystem() },
+		"ollama": func(t *testing.T) babel.AISystem {
+			return cassetteEngine(t, "ollama", func() babel.AISystem { return babel.NewOpenAIDefaultLocalBackend() })
+		},
+		"cohere": func(t *testing.T) babel.AISystem {
+			return cassetteEngine(t, "cohere", func() babel.AISystem {
+				return babel.NewCohereClient(os.Getenv("COHERE_API_KEY"), "")
+			})
+		},
+	}
+}
+
+// cassetteEngine replays the cassette of the engine for the running test, or records live into it when
+// BABEL_RECORD asks for the engine. Tests without a cassette are skipped locally, and fail in CI, where a missing
+// cassette would otherwise leave the engine untested without anyone noticing.
+func cassetteEngine(t *testing.T, name string, live func() babel.AISystem) babel.AISystem {
+	test, _, _ := strings.Cut(t.Name(), "/")
+	path := filepath.Join("testdata", "cassettes", test, name+".json")
+
+	record := os.Getenv("BABEL_RECORD")
+	if record == "all" || slices.Contains(strings.Split(record, ","), name) {
+		return babel.NewRecordingAISystem(path, live())
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if os.Getenv("CI") != "" {
+			t.Fatalf("no cassette at %s, record it with BABEL_RECORD=%s", path, name)
+		}
+		t.Skipf("no cassette at %s, record it with BABEL_RECORD=%s", path, name)
+	}
+	// the tests iterate maps, so calls come in any order
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayLenient)
+	require.NoError(t, err)
+	return replay
+}
+
 func TestNewBabel(t *testing.T) {
 	b := babel.NewBabel(babel.NewOpenAIDefaultLocalBackend())
 
@@ -20,13 +64,9 @@ func TestNewBabel(t *testing.T) {
 }
 
 func TestTranslationText(t *testing.T) {
-	for name, engine := range map[string]babel.AISystem{
-		"mock": babel.NewMockAISystem(),
-		//"ollama": babel.NewOpenAIDefaultLocalBackend(),
-		//"cohere": babel.NewCohereClient(os.Getenv("COHERE_API_KEY"), ""),
-	} {
+	for name, engine := range testEngines() {
 		t.Run(fmt.Sprintf("engine: %s", name), func(t *testing.T) {
-			b := babel.NewBabel(engine)
+			b := babel.NewBabel(engine(t))
 			require.NotNil(t, b)
 
 			for _, lang := range []language.Tag{
@@ -72,13 +112,9 @@ func TestTranslationText(t *testing.T) {
 }
 
 func TestLanguageIdentification(t *testing.T) {
-	for name, engine := range map[string]babel.AISystem{
-		"mock": babel.NewMockAISystem(),
-		//"ollama": babel.NewOpenAIDefaultLocalBackend(),
-		//"cohere": babel.NewCohereClient(os.Getenv("COHERE_API_KEY"), ""),
-	} {
+	for name, engine := range testEngines() {
 		t.Run(fmt.Sprintf("engine: %s", name), func(t *testing.T) {
-			b := babel.NewBabel(engine)
+			b := babel.NewBabel(engine(t))
 			require.NotNil(t, b)
 
 			for lang, input := range map[language.Tag]string{
@@ -204,3 +240,64 @@ func TestLanguageIdentificationEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestOperationBackends(t *testing.T) {
+	fallback := &flakyAISystem{mock: babel.NewMockAISystem()}
+	fast := &flakyAISystem{mock: babel.NewMockAISystem()}
+	large := &flakyAISystem{mock: babel.NewMockAISystem()}
+
+	b := babel.NewBabel(fallback)
+	b.SetOperationBackend(babel.OperationIdentify, fast)
+	b.SetOperationBackend(babel.OperationPreview, fast)
+	b.SetOperationBackend(babel.OperationStart, large)
+	b.SetOperationBackend(babel.OperationImprove, large)
+	ctx := context.Background()
+
+	_, err := b.IdentifyLanguage(ctx, "Hola.")
+	require.NoError(t, err)
+	result, err := b.Preview(ctx, "Hello. I like pizza.", language.English, language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.EqualValues(t, 2, fast.calls.Load())
+
+	translationContext, _, err := b.NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	_, err = translationContext.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, large.calls.Load())
+	require.EqualValues(t, 0, fallback.calls.Load())
+}
+
+func TestOperationBackendsWithRoutes(t *testing.T) {
+	fallback := &flakyAISystem{mock: babel.NewMockAISystem()}
+	preview := &flakyAISystem{mock: babel.NewMockAISystem()}
+	improver := &flakyAISystem{mock: babel.NewMockAISystem()}
+	spanish := &flakyAISystem{mock: babel.NewMockAISystem()}
+
+	b := babel.NewBabelWithRouter(babel.NewLanguageRouter(fallback,
+		babel.Route{Name: "spanish", Targets: []language.Tag{language.Spanish}, Backend: spanish},
+	))
+	b.SetOperationBackend(babel.OperationPreview, preview)
+	b.SetOperationBackend(babel.OperationImprove, improver)
+	ctx := context.Background()
+
+	// previews stay on the cheap tier even when a route matches
+	_, err := b.Preview(ctx, "Hello. I like pizza.", language.English, language.Spanish)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, preview.calls.Load())
+
+	// routed contexts keep their route for improvements
+	routed, _, err := b.NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	_, err = routed.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, spanish.calls.Load())
+
+	// unrouted contexts move to the improvement tier
+	unrouted, _, err := b.NewTranslation(ctx, "Hello. I like pizza.", language.German)
+	require.NoError(t, err)
+	_, err = unrouted.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, fallback.calls.Load())
+	require.EqualValues(t, 1, improver.calls.Load())
+}
@@ -0,0 +1,273 @@
+// Package backendtest is a conformance suite for babel.AISystem implementations, with fake providers to run it
+// offline.
+//
+// A backend passes the suite by building itself against a fake provider that serves the Model handed to it:
+//
+//	backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+//		server := backendtest.NewOpenAIServer(t, model)
+//		backend, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, Model: "fake"})
+//		require.NoError(t, err)
+//		return backend
+//	})
+//
+// Backends for other providers bring their own fake server and call the Model with the conversation they
+// received, mapped back to babel messages.
+package backendtest
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Model stands in for the language model behind a fake provider. It receives the conversation as the provider
+// understood it and returns the completion, or an error the provider reports as a server error. ctx is done once
+// the client gave up on the request.
+type Model func(ctx context.Context, conversation []babel.Message) (string, error)
+
+// NewSystem builds the AISystem under test against a fake provider serving model. It is called once per check.
+type NewSystem func(t *testing.T, model Model) babel.AISystem
+
+// Turn is one message of a conversation as a model received it.
+type Turn struct {
+	Role babel.Role `json:"role"`
+	Text string     `json:"text"`
+}
+
+// Echo is a Model answering with the transcript of the conversation it received, see Transcript.
+func Echo(_ context.Context, conversation []babel.Message) (string, error) {
+	turns := make([]Turn, len(conversation))
+	for i, m := range conversation {
+		turns[i] = Turn{Role: m.Role, Text: m.Text()}
+	}
+	data, err := json.Marshal(turns)
+	return string(data), err
+}
+
+// Transcript decodes a completion of Echo.
+func Transcript(t *testing.T, completion string) []Turn {
+	t.Helper()
+	var turns []Turn
+	require.NoError(t, json.Unmarshal([]byte(completion), &turns), "the completion is not the transcript the model answered with")
+	return turns
+}
+
+// turns is what a model is expected to receive of messages.
+func turns(messages []babel.Message) []Turn {
+	result := make([]Turn, len(messages))
+	for i, m := range messages {
+		result[i] = Turn{Role: m.Role, Text: m.Text()}
+	}
+	return result
+}
+
+// Run checks that the AISystem built by newSystem behaves like every backend must.
+func Run(t *testing.T, newSystem NewSystem) {
+	t.Run("history", func(t *testing.T) { testHistory(t, newSystem) })
+	t.Run("multipart", func(t *testing.T) { testMultipart(t, newSystem) })
+	t.Run("system message", func(t *testing.T) { testSystemMessage(t, newSystem) })
+	t.Run("cancellation", func(t *testing.T) { testCancellation(t, newSystem) })
+	t.Run("empty output", func(t *testing.T) { testEmptyOutput(t, newSystem) })
+	t.Run("unicode", func(t *testing.T) { testUnicode(t, newSystem) })
+	t.Run("concurrency", func(t *testing.T) { testConcurrency(t, newSystem) })
+	t.Run("streaming", func(t *testing.T) { testStreaming(t, newSystem) })
+}
+
+// testHistory checks that every turn of a long conversation reaches the model in order, with metadata left out.
+func testHistory(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, Echo)
+	conversation := []babel.Message{
+		babel.SystemMessage("Translate into Spanish."),
+		{
+			Role:     babel.RoleUser,
+			Parts:    []babel.Part{{Text: "Hello. I like pizza."}},
+			Metadata: map[string]string{babel.MetadataOperation: "start", babel.MetadataTargetLanguage: "es"},
+		},
+		babel.AssistantMessage("Hola. Me gusta la pizza."),
+		babel.UserMessage("Make it more formal."),
+		babel.AssistantMessage("Hola. Me encanta la pizza."),
+		babel.UserMessage("Mention the cheese."),
+	}
+
+	completion, err := system.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+}
+
+// testMultipart checks that a message with several parts either reaches the model with every part in order, or is
+// rejected with babel.ErrMultipartUnsupported before the provider is called. Joining parts silently is not allowed.
+func testMultipart(t *testing.T, newSystem NewSystem) {
+	var calls atomic.Int32
+	system := newSystem(t, func(ctx context.Context, conversation []babel.Message) (string, error) {
+		calls.Add(1)
+		return Echo(ctx, conversation)
+	})
+	conversation := []babel.Message{
+		babel.SystemMessage("Translate into Spanish."),
+		{Role: babel.RoleUser, Parts: []babel.Part{{Text: "Hello. "}, {Text: "I like pizza."}}},
+	}
+
+	completion, err := system.Chat(context.Background(), conversation)
+	if errors.Is(err, babel.ErrMultipartUnsupported) {
+		assert.Zero(t, calls.Load(), "the provider was called with a message it cannot take")
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+}
+
+// testSystemMessage checks that a system message reaches the model as such, and that none is made up without one.
+func testSystemMessage(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, Echo)
+
+	conversation := []babel.Message{babel.SystemMessage("Answer in German only."), babel.UserMessage("Hello")}
+	completion, err := system.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+
+	conversation = []babel.Message{babel.UserMessage("Hello")}
+	completion, err = system.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+}
+
+// testCancellation checks that a call returns promptly with the context's error once the caller gives up, and
+// that the provider sees the request go away.
+func testCancellation(t *testing.T, newSystem NewSystem) {
+	called := make(chan struct{}, 1)
+	abandoned := make(chan struct{}, 1)
+	system := newSystem(t, func(ctx context.Context, _ []babel.Message) (string, error) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		select {
+		case <-ctx.Done():
+			select {
+			case abandoned <- struct{}{}:
+			default:
+			}
+			return "", ctx.Err()
+		case <-time.After(time.Minute):
+			return "too late", nil
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-called
+		cancel()
+	}()
+	defer cancel()
+
+	started := time.Now()
+	_, err := system.Chat(ctx, []babel.Message{babel.UserMessage("Hello")})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled, "the error should tell that the call was cancelled")
+	assert.Less(t, time.Since(started), 5*time.Second, "the call should return once cancelled")
+
+	select {
+	case <-abandoned:
+	case <-time.After(5 * time.Second):
+		t.Error("the request to the provider was not abandoned")
+	}
+}
+
+// testEmptyOutput checks that an empty completion is passed on as empty or reported as an error, never replaced.
+func testEmptyOutput(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, func(context.Context, []babel.Message) (string, error) { return "", nil })
+
+	completion, err := system.Chat(context.Background(), []babel.Message{babel.UserMessage("Hello")})
+	if err == nil {
+		assert.Empty(t, completion)
+	}
+}
+
+// testUnicode checks that text outside ASCII, including emoji sequences, combining marks, right-to-left scripts
+// and characters with a meaning in JSON or HTML, arrives and returns unchanged.
+func testUnicode(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, Echo)
+	texts := []string{
+		"こんにちは。ピザが好きです。",
+		"Ça va? Ünïcödé, é and ß",
+		"שלום עולם, مرحبا بالعالم",
+		"🍕 👩‍👩‍👧 🇯🇵 ❤️",
+		`"quotes", 'apostrophes', <tags> & \backslashes\`,
+		"line one\nline two\ttabbed",
+	}
+	conversation := []babel.Message{babel.SystemMessage(texts[0])}
+	for _, text := range texts[1:] {
+		conversation = append(conversation, babel.UserMessage(text), babel.AssistantMessage(text))
+	}
+	conversation = conversation[:len(conversation)-1]
+
+	completion, err := system.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+}
+
+// testConcurrency checks that concurrent calls on one system each get their own answer. Run it with -race.
+func testConcurrency(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, Echo)
+
+	const calls = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conversation := []babel.Message{babel.UserMessage(fmt.Sprintf("request %d", i))}
+			completion, err := system.Chat(context.Background(), conversation)
+			if err != nil {
+				errs <- fmt.Errorf("call %d: %w", i, err)
+				return
+			}
+			var received []Turn
+			if err := json.Unmarshal([]byte(completion), &received); err != nil {
+				errs <- fmt.Errorf("call %d: %w", i, err)
+				return
+			}
+			if len(received) != 1 || received[0] != turns(conversation)[0] {
+				errs <- fmt.Errorf("call %d got the answer to %v", i, received)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var all []error
+	for err := range errs {
+		all = append(all, err)
+	}
+	require.NoError(t, errors.Join(all...))
+}
+
+// testStreaming checks that a streamed completion is the sum of its deltas, for systems that can stream.
+func testStreaming(t *testing.T, newSystem NewSystem) {
+	system := newSystem(t, Echo)
+	streaming, ok := system.(babel.StreamingAISystem)
+	if !ok || !system.Capabilities().Streaming {
+		t.Skip("the system does not stream")
+	}
+
+	conversation := []babel.Message{babel.SystemMessage("Translate into Spanish."), babel.UserMessage("Hello")}
+	var deltas strings.Builder
+	completion, err := streaming.ChatStream(context.Background(), conversation, func(delta string) error {
+		deltas.WriteString(delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, completion, deltas.String())
+	assert.Equal(t, turns(conversation), Transcript(t, completion))
+}
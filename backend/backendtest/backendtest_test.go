@@ -0,0 +1,55 @@
+package backendtest_test
+
+import (
+	"BabelBridge/backend"
+	"BabelBridge/backend/backendtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIBackend(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+		server := backendtest.NewOpenAIServer(t, model)
+		backend, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, APIKey: "test-key", Model: "fake-model"})
+		require.NoError(t, err)
+		return backend
+	})
+}
+
+func TestCohereClient(t *testing.T) {
+	backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+		server := backendtest.NewCohereServer(t, model)
+		backend, err := babel.NewCohereBackend(babel.CohereConfig{APIKey: "test-key", BaseURL: server.URL, Model: "fake-model"})
+		require.NoError(t, err)
+		return backend
+	})
+}
+
+// the decorators must not break what the backends they wrap get right
+func TestDecorators(t *testing.T) {
+	openAI := func(t *testing.T, model backendtest.Model) babel.AISystem {
+		server := backendtest.NewOpenAIServer(t, model)
+		backend, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, Model: "fake-model"})
+		require.NoError(t, err)
+		return backend
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+			return babel.NewRetryAISystem("openai", openAI(t, model), babel.RetryConfig{})
+		})
+	})
+	t.Run("failover", func(t *testing.T) {
+		backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+			return babel.NewFailoverAISystem(babel.FailoverConfig{}, babel.FailoverMember{Name: "openai", Backend: openAI(t, model)})
+		})
+	})
+	t.Run("chaos without faults", func(t *testing.T) {
+		backendtest.Run(t, func(t *testing.T, model backendtest.Model) babel.AISystem {
+			chaos, err := babel.NewChaos(babel.ChaosConfig{})
+			require.NoError(t, err)
+			return chaos.Wrap("openai", openAI(t, model))
+		})
+	})
+}
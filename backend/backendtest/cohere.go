@@ -0,0 +1,80 @@
+package backendtest
+
+import (
+	"BabelBridge/backend"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cohereChatRequest is the part of a Cohere v1 chat request the fake understands.
+type cohereChatRequest struct {
+	Message     string  `json:"message"`
+	Preamble    *string `json:"preamble"`
+	ChatHistory []struct {
+		Role    string `json:"role"`
+		Message string `json:"message"`
+	} `json:"chat_history"`
+}
+
+// conversation maps the request back to babel messages: the preamble is the system message, the message the last
+// user turn.
+func (r cohereChatRequest) conversation() ([]babel.Message, bool) {
+	roles := map[string]babel.Role{"SYSTEM": babel.RoleSystem, "USER": babel.RoleUser, "CHATBOT": babel.RoleAssistant}
+	var conversation []babel.Message
+	if r.Preamble != nil {
+		conversation = append(conversation, babel.SystemMessage(*r.Preamble))
+	}
+	for _, m := range r.ChatHistory {
+		role, ok := roles[m.Role]
+		if !ok {
+			return nil, false
+		}
+		conversation = append(conversation, babel.Message{Role: role, Parts: []babel.Part{{Text: m.Message}}})
+	}
+	return append(conversation, babel.UserMessage(r.Message)), true
+}
+
+// NewCohereServer starts a fake Cohere server answering v1 chat requests with model. Point CohereConfig.BaseURL at
+// its URL. It also lists a single chat model, "fake-model". The server is closed when the test ends.
+func NewCohereServer(t testing.TB, model Model) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/models"):
+			writeJSON(w, http.StatusOK, map[string]any{
+				"models": []map[string]any{{"name": "fake-model", "endpoints": []string{"chat"}, "context_length": 8192}},
+			})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat"):
+			var req cohereChatRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"message": err.Error()})
+				return
+			}
+			conversation, ok := req.conversation()
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"message": "unknown role in chat_history"})
+				return
+			}
+			completion, err := model(r.Context(), conversation)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"message": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"text":          completion,
+				"generation_id": "backendtest",
+				"finish_reason": "COMPLETE",
+				"meta": map[string]any{"tokens": map[string]any{
+					"input_tokens":  countWords(conversation),
+					"output_tokens": len(strings.Fields(completion)),
+				}},
+			})
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]any{"message": "unknown endpoint " + r.URL.Path})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,118 @@
+package backendtest
+
+import (
+	"BabelBridge/backend"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openAIRequest is the part of a chat completion request the fake understands.
+type openAIRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+// conversation maps the request back to babel messages, content is either a string or text parts.
+func (r openAIRequest) conversation() ([]babel.Message, bool) {
+	var conversation []babel.Message
+	for _, m := range r.Messages {
+		message := babel.Message{Role: babel.Role(m.Role)}
+		var text string
+		if json.Unmarshal(m.Content, &text) == nil {
+			message.Parts = []babel.Part{{Text: text}}
+		} else {
+			var parts []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(m.Content, &parts); err != nil {
+				return nil, false
+			}
+			for _, p := range parts {
+				if p.Type != "text" {
+					return nil, false
+				}
+				message.Parts = append(message.Parts, babel.Part{Text: p.Text})
+			}
+		}
+		conversation = append(conversation, message)
+	}
+	return conversation, true
+}
+
+// NewOpenAIServer starts a fake OpenAI compatible server answering chat completions with model. Point
+// OpenAIConfig.BaseURL at its URL. It also lists a single model, "fake-model". The server is closed when the test
+// ends.
+func NewOpenAIServer(t testing.TB, model Model) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/models"):
+			writeJSON(w, http.StatusOK, map[string]any{
+				"object": "list",
+				"data":   []map[string]any{{"id": "fake-model", "object": "model", "created": 0, "owned_by": "backendtest"}},
+			})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat/completions"):
+			var req openAIRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+			conversation, ok := req.conversation()
+			if !ok {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "unsupported message content")
+				return
+			}
+			completion, err := model(r.Context(), conversation)
+			if err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"id":      "chatcmpl-backendtest",
+				"object":  "chat.completion",
+				"created": time.Now().Unix(),
+				"model":   req.Model,
+				"choices": []map[string]any{{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": completion},
+					"finish_reason": "stop",
+				}},
+				"usage": map[string]any{
+					"prompt_tokens":     countWords(conversation),
+					"completion_tokens": len(strings.Fields(completion)),
+					"total_tokens":      countWords(conversation) + len(strings.Fields(completion)),
+				},
+			})
+		default:
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "unknown endpoint "+r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, kind, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]any{"message": message, "type": kind}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// countWords stands in for a token count.
+func countWords(conversation []babel.Message) int {
+	n := 0
+	for _, m := range conversation {
+		n += len(strings.Fields(m.Text()))
+	}
+	return n
+}
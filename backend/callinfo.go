@@ -0,0 +1,36 @@
+package babel
+
+import "context"
+
+type callInfoKey struct{}
+
+// CallInfo collects details about how a single Chat call was served. Backends and decorators fill in the
+// fields they know about when the context passed to Chat carries a CallInfo.
+type CallInfo struct {
+	// Backend is the name of the backend that produced the completion
+	Backend string
+	// Endpoint is the host within a pool that served the call
+	Endpoint string
+	// Route is the name of the language route that selected the backend, empty for the default backend
+	Route string
+	// Usage is the token usage reported by the backend, zero when it does not report any
+	Usage Usage
+}
+
+// Usage counts the tokens consumed by a completion.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// WithCallInfo returns a context that records details about the next Chat call into the returned CallInfo.
+func WithCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	info := &CallInfo{}
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
+
+// callInfoFromContext returns the CallInfo carried by ctx, or nil.
+func callInfoFromContext(ctx context.Context) *CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*CallInfo)
+	return info
+}
@@ -0,0 +1,174 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrStreamingUnsupported is returned when streaming is requested from a backend that cannot stream.
+var ErrStreamingUnsupported = errors.New("streaming is not supported by this engine")
+
+// Sampling parameters a backend may accept, named like the OpenAI chat completions fields.
+const (
+	ParamTemperature = "temperature"
+	ParamTopP        = "top_p"
+	ParamMaxTokens   = "max_tokens"
+	ParamSeed        = "seed"
+	ParamStop        = "stop"
+)
+
+// AllSamplingParameters lists every sampling parameter in a stable order.
+var AllSamplingParameters = []string{ParamTemperature, ParamTopP, ParamMaxTokens, ParamSeed, ParamStop}
+
+// DefaultChunkSize is the number of characters translated per request when a backend's context length is unknown.
+const DefaultChunkSize = 4000
+
+const (
+	// promptReserve is the number of tokens kept free for the system prompt and improvement instructions
+	promptReserve = 1024
+	// charsPerToken is deliberately low, scripts like Japanese take about one token per character
+	charsPerToken = 2
+	// maxChunkSize keeps single requests reasonably fast even on models with huge context windows
+	maxChunkSize = 32000
+)
+
+// Capabilities describes what a backend can do, so that the service can adapt to it instead of failing at
+// request time.
+type Capabilities struct {
+	// Streaming means the backend implements StreamingAISystem and can deliver a completion as it is generated
+	Streaming bool `json:"streaming"`
+	// StructuredOutput means the provider can constrain completions to a JSON schema
+	StructuredOutput bool `json:"structuredOutput"`
+	// Images means the provider accepts image input, the configured model may still be text only
+	Images bool `json:"images"`
+	// ContextLength is the context window in tokens, zero when unknown
+	ContextLength int `json:"contextLength,omitempty"`
+	// SamplingParameters lists the sampling parameters the backend accepts, see AllSamplingParameters
+	SamplingParameters []string `json:"samplingParameters"`
+}
+
+// SupportsSampling reports whether the backend accepts the sampling parameter.
+func (c Capabilities) SupportsSampling(param string) bool {
+	return slices.Contains(c.SamplingParameters, param)
+}
+
+// Intersect returns what both c and other can do, for decorators that may send a call to either of them.
+// The smaller known context length wins.
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	result := Capabilities{
+		Streaming:        c.Streaming && other.Streaming,
+		StructuredOutput: c.StructuredOutput && other.StructuredOutput,
+		Images:           c.Images && other.Images,
+		ContextLength:    c.ContextLength,
+	}
+	if c.ContextLength == 0 || (other.ContextLength > 0 && other.ContextLength < c.ContextLength) {
+		result.ContextLength = other.ContextLength
+	}
+	result.SamplingParameters = []string{}
+	for _, param := range c.SamplingParameters {
+		if other.SupportsSampling(param) {
+			result.SamplingParameters = append(result.SamplingParameters, param)
+		}
+	}
+	return result
+}
+
+// ChunkSize is the number of characters of input translated per request. Half of the context window left after
+// the prompt is kept for the translation itself.
+func (c Capabilities) ChunkSize() int {
+	if c.ContextLength == 0 {
+		return DefaultChunkSize
+	}
+	size := (c.ContextLength - promptReserve) / 2 * charsPerToken
+	return min(max(size, 200), maxChunkSize)
+}
+
+// capabilitiesOf intersects the capabilities of every system, e.g. the members of a failover chain.
+func capabilitiesOf(systems ...AISystem) Capabilities {
+	if len(systems) == 0 {
+		return Capabilities{SamplingParameters: []string{}}
+	}
+	result := systems[0].Capabilities()
+	for _, system := range systems[1:] {
+		result = result.Intersect(system.Capabilities())
+	}
+	return result
+}
+
+// chatStream streams the completion from system when onDelta is set and the system can stream. Otherwise the
+// whole completion is handed to onDelta at once.
+func chatStream(ctx context.Context, system AISystem, messages []Message, onDelta func(string) error) (string, error) {
+	if onDelta == nil {
+		return system.Chat(ctx, messages)
+	}
+	if streaming, ok := system.(StreamingAISystem); ok && system.Capabilities().Streaming {
+		return streaming.ChatStream(ctx, messages, onDelta)
+	}
+	result, err := system.Chat(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	if err := onDelta(result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// trackDelta wraps onDelta to record whether any text was delivered, after which a call must not be repeated
+// or the caller would see the text twice. A nil onDelta stays nil.
+func trackDelta(onDelta func(string) error, started *bool) func(string) error {
+	if onDelta == nil {
+		return nil
+	}
+	return func(delta string) error {
+		*started = true
+		return onDelta(delta)
+	}
+}
+
+// splitChunks splits text into chunks of at most size characters, preferring paragraph, line, sentence and word
+// boundaries in that order. The whitespace between two chunks is returned as the separator following the first,
+// so that translated chunks can be joined with the original layout.
+func splitChunks(text string, size int) (chunks, separators []string) {
+	for utf8.RuneCountInString(text) > size {
+		cut := chunkBoundary(text, size)
+		chunk := strings.TrimRightFunc(text[:cut], unicode.IsSpace)
+		rest := strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+		separator := text[len(chunk) : len(text)-len(rest)]
+		if chunk == "" {
+			// only whitespace before the boundary, move on
+			text = rest
+			continue
+		}
+		chunks = append(chunks, chunk)
+		separators = append(separators, separator)
+		text = rest
+	}
+	chunks = append(chunks, text)
+	separators = append(separators, "")
+	return chunks, separators
+}
+
+// chunkBoundary returns the byte offset at which to cut text so that the first part holds at most size characters.
+// Boundaries in the first half are ignored, they would leave a tiny chunk.
+func chunkBoundary(text string, size int) int {
+	limit := len(text)
+	for i := range text {
+		if size == 0 {
+			limit = i
+			break
+		}
+		size--
+	}
+	window := text[:limit]
+	for _, boundary := range []string{"\n\n", "\n", ". ", "。", "! ", "? ", " "} {
+		if i := strings.LastIndex(window, boundary); i > len(window)/2 {
+			return i + len(boundary)
+		}
+	}
+	return limit
+}
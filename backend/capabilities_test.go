@@ -0,0 +1,141 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+// streamingAISystem streams its reply word by word, failing after failAfter words when set.
+type streamingAISystem struct {
+	reply     string
+	failAfter int
+	calls     int
+}
+
+func (s *streamingAISystem) Capabilities() babel.Capabilities {
+	return babel.Capabilities{Streaming: true, ContextLength: 8192, SamplingParameters: babel.AllSamplingParameters}
+}
+
+func (s *streamingAISystem) Chat(ctx context.Context, messages []babel.Message) (string, error) {
+	return s.ChatStream(ctx, messages, func(string) error { return nil })
+}
+
+func (s *streamingAISystem) ChatStream(_ context.Context, _ []babel.Message, onDelta func(string) error) (string, error) {
+	s.calls++
+	for i, word := range strings.SplitAfter(s.reply, " ") {
+		if s.failAfter > 0 && i == s.failAfter {
+			return "", io.ErrUnexpectedEOF
+		}
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+	return s.reply, nil
+}
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	a := babel.Capabilities{Streaming: true, StructuredOutput: true, ContextLength: 8192, SamplingParameters: babel.AllSamplingParameters}
+	b := babel.Capabilities{Streaming: true, Images: true, SamplingParameters: []string{babel.ParamTemperature, babel.ParamSeed}}
+
+	require.Equal(t, babel.Capabilities{
+		Streaming:          true,
+		ContextLength:      8192,
+		SamplingParameters: []string{babel.ParamTemperature, babel.ParamSeed},
+	}, a.Intersect(b))
+	require.Equal(t, 4096, a.Intersect(babel.Capabilities{ContextLength: 4096}).ContextLength)
+	require.True(t, a.SupportsSampling(babel.ParamTopP))
+	require.False(t, b.SupportsSampling(babel.ParamTopP))
+}
+
+func TestCapabilitiesChunkSize(t *testing.T) {
+	require.Equal(t, babel.DefaultChunkSize, babel.Capabilities{}.ChunkSize())
+	require.Equal(t, 7168, babel.Capabilities{ContextLength: 8192}.ChunkSize())
+	require.Equal(t, 200, babel.Capabilities{ContextLength: 1024}.ChunkSize())
+	require.Equal(t, 32000, babel.Capabilities{ContextLength: 1 << 20}.ChunkSize())
+}
+
+func TestDecoratorCapabilities(t *testing.T) {
+	streaming := &streamingAISystem{}
+	plain := &recordingAISystem{capabilities: babel.Capabilities{ContextLength: 4096, SamplingParameters: []string{babel.ParamTemperature}}}
+
+	require.Equal(t, streaming.Capabilities(), babel.NewRetryAISystem("test", streaming, babel.RetryConfig{}).Capabilities())
+
+	failover := babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "streaming", Backend: streaming},
+		babel.FailoverMember{Name: "plain", Backend: plain})
+	require.Equal(t, babel.Capabilities{ContextLength: 4096, SamplingParameters: []string{babel.ParamTemperature}}, failover.Capabilities())
+
+	pool := babel.NewPoolAISystem(babel.PoolConfig{HealthInterval: -1}, babel.PoolEndpoint{Name: "a", Backend: streaming})
+	defer func() { _ = pool.Close() }()
+	require.True(t, pool.Capabilities().Streaming)
+}
+
+func TestBackendChunksLongInput(t *testing.T) {
+	// a context of 1224 tokens leaves 200 characters per chunk
+	system := &recordingAISystem{capabilities: babel.Capabilities{ContextLength: 1224}}
+	paragraph := strings.Repeat("This sentence is filler. ", 6)
+	input := strings.TrimSpace(paragraph) + "\n\n" + strings.TrimSpace(paragraph) + "\n" + strings.TrimSpace(paragraph)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	translationContext, result, err := babel.NewBabel(system).NewTranslation(ctx, input, language.Spanish)
+	require.NoError(t, err)
+
+	// every chunk was a separate request, and the layout between them survived
+	require.Len(t, system.calls, 3)
+	for _, call := range system.calls {
+		require.LessOrEqual(t, len(call[len(call)-1].Text()), 200)
+	}
+	require.Equal(t, input, result)
+	require.Equal(t, input, translationContext.LastResult())
+	require.Equal(t, babel.Usage{}, info.Usage)
+}
+
+func TestBackendStreaming(t *testing.T) {
+	system := &streamingAISystem{reply: "Hola. Me gusta la pizza."}
+	b := babel.NewBabel(babel.NewRetryAISystem("test", system, babel.RetryConfig{}))
+	require.True(t, b.Capabilities().Streaming)
+
+	var deltas []string
+	onDelta := func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	}
+	translationContext, result, err := b.NewTranslationStream(context.Background(), "Hello. I like pizza.", language.Und, language.Spanish, onDelta)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, []string{"Hola. ", "Me ", "gusta ", "la ", "pizza."}, deltas)
+
+	deltas = nil
+	_, err = translationContext.ImproveStream(context.Background(), "Make it formal", onDelta)
+	require.NoError(t, err)
+	require.Len(t, deltas, 5)
+}
+
+func TestBackendStreamingUnsupported(t *testing.T) {
+	b := babel.NewBabel(&recordingAISystem{reply: "Hola."})
+	require.False(t, b.Capabilities().Streaming)
+
+	_, err := b.PreviewStream(context.Background(), "Hello.", language.Und, language.Spanish, func(string) error { return nil })
+	require.ErrorIs(t, err, babel.ErrStreamingUnsupported)
+}
+
+func TestRetryStopsOnceStreamStarted(t *testing.T) {
+	// an unexpected EOF is retried, but not after the caller has seen part of the answer
+	system := &streamingAISystem{reply: "Hola. Me gusta la pizza.", failAfter: 2}
+	retry := babel.NewRetryAISystem("test", system, babel.RetryConfig{MaxAttempts: 3})
+
+	var deltas []string
+	_, err := retry.ChatStream(context.Background(), []babel.Message{babel.UserMessage("Hello.")}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 1, system.calls)
+	require.Equal(t, []string{"Hola. ", "Me "}, deltas)
+}
@@ -0,0 +1,345 @@
+package babel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrNoRecording is returned by ReplayAISystem for a call its cassette holds no answer for.
+var ErrNoRecording = errors.New("no recorded interaction matches the call")
+
+// Cassette is a recording of the calls made to an AISystem, stored as JSON so that it can be reviewed and committed
+// next to the tests that replay it.
+type Cassette struct {
+	// Capabilities of the recorded backend
+	Capabilities Capabilities  `json:"capabilities"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded Chat call.
+type Interaction struct {
+	Messages []Message         `json:"messages"`
+	Options  GenerationOptions `json:"options"`
+	// Deltas are the pieces a streamed completion arrived in, empty when the call was not streamed
+	Deltas   []string `json:"deltas,omitempty"`
+	Response string   `json:"response"`
+	// Error is the message of the error the call failed with
+	Error string `json:"error,omitempty"`
+	Usage Usage  `json:"usage"`
+}
+
+// LoadCassette reads a cassette written by RecordingAISystem.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, replacing it atomically so that an interrupted recording never leaves a
+// truncated file behind.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// RecordingAISystem passes every call through to a backend and records it into a cassette file, which
+// ReplayAISystem serves back without the backend.
+type RecordingAISystem struct {
+	backend AISystem
+	path    string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecordingAISystem records the calls made to backend into the cassette at path. An existing cassette is
+// replaced, the file is rewritten after every call.
+func NewRecordingAISystem(path string, backend AISystem) *RecordingAISystem {
+	return &RecordingAISystem{
+		backend:  backend,
+		path:     path,
+		cassette: Cassette{Capabilities: backend.Capabilities(), Interactions: []Interaction{}},
+	}
+}
+
+func (r *RecordingAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return r.record(ctx, messages, nil)
+}
+
+// ChatStream streams from the backend and records the deltas, so that a replay streams the same way.
+func (r *RecordingAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return r.record(ctx, messages, onDelta)
+}
+
+func (r *RecordingAISystem) Capabilities() Capabilities {
+	return r.backend.Capabilities()
+}
+
+// Close closes the wrapped backend. The cassette needs no flushing, it is written after every call.
+func (r *RecordingAISystem) Close() error {
+	return closeAll(r.backend)
+}
+
+// HealthCheck probes the wrapped backend, the probe is not recorded.
+func (r *RecordingAISystem) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, r.backend)
+}
+
+func (r *RecordingAISystem) record(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	info := callInfoFromContext(ctx)
+	if info == nil {
+		ctx, info = WithCallInfo(ctx)
+	}
+
+	interaction := Interaction{
+		Messages: messages,
+		Options:  generationOptions(ctx, GenerationOptions{}),
+	}
+	if onDelta != nil {
+		deltas := onDelta
+		onDelta = func(delta string) error {
+			interaction.Deltas = append(interaction.Deltas, delta)
+			return deltas(delta)
+		}
+	}
+
+	result, err := chatStream(ctx, r.backend, messages, onDelta)
+	interaction.Response = result
+	interaction.Usage = info.Usage
+	if err != nil {
+		// a cancelled call says nothing about the backend, replaying it would only confuse
+		if ctx.Err() != nil {
+			return "", err
+		}
+		interaction.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if saveErr := r.cassette.Save(r.path); saveErr != nil {
+		return "", errors.Join(err, saveErr)
+	}
+	return result, err
+}
+
+// ReplayMatch selects how ReplayAISystem matches a call against the recorded interactions.
+type ReplayMatch int
+
+const (
+	// ReplayStrict serves an interaction only for the exact messages, metadata and generation options it was
+	// recorded with, and each interaction only once in the order they were recorded. Any change to prompts or
+	// call order fails the replay.
+	ReplayStrict ReplayMatch = iota
+	// ReplayLenient ignores system prompts, generation options, differences in whitespace and metadata other than
+	// the operation and target language, and serves an interaction as often as it is asked for. Replays survive
+	// prompt tweaks as long as the conversation, the operation and the language stay the same.
+	ReplayLenient
+)
+
+// ParseReplayMatch parses "strict" or "lenient".
+func ParseReplayMatch(s string) (ReplayMatch, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "strict":
+		return ReplayStrict, nil
+	case "lenient":
+		return ReplayLenient, nil
+	default:
+		return 0, fmt.Errorf("unknown replay match %q, expected strict or lenient", s)
+	}
+}
+
+func (m ReplayMatch) String() string {
+	if m == ReplayLenient {
+		return "lenient"
+	}
+	return "strict"
+}
+
+// ReplayAISystem answers calls from a cassette recorded by RecordingAISystem, without any network.
+type ReplayAISystem struct {
+	cassette *Cassette
+	match    ReplayMatch
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayAISystem replays the cassette at path.
+func NewReplayAISystem(path string, match ReplayMatch) (*ReplayAISystem, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassetteAISystem(cassette, match), nil
+}
+
+// NewCassetteAISystem replays a cassette already in memory.
+func NewCassetteAISystem(cassette *Cassette, match ReplayMatch) *ReplayAISystem {
+	return &ReplayAISystem{cassette: cassette, match: match}
+}
+
+func (r *ReplayAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return r.replay(ctx, messages, nil)
+}
+
+// ChatStream delivers the recorded deltas, or the whole response at once when the call was not streamed.
+func (r *ReplayAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return r.replay(ctx, messages, onDelta)
+}
+
+func (r *ReplayAISystem) Capabilities() Capabilities {
+	return r.cassette.Capabilities
+}
+
+// HealthCheck always succeeds without using up a recording.
+func (r *ReplayAISystem) HealthCheck(context.Context) error {
+	return nil
+}
+
+// Remaining returns the number of recorded interactions a strict replay has not served yet, so that tests can
+// assert every recorded call was made.
+func (r *ReplayAISystem) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions) - r.next
+}
+
+func (r *ReplayAISystem) replay(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	interaction, err := r.find(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Usage = interaction.Usage
+	}
+	if onDelta != nil {
+		deltas := interaction.Deltas
+		if len(deltas) == 0 && interaction.Response != "" {
+			deltas = []string{interaction.Response}
+		}
+		for _, delta := range deltas {
+			if err := onDelta(delta); err != nil {
+				return "", err
+			}
+		}
+	}
+	if interaction.Error != "" {
+		return "", errors.New(interaction.Error)
+	}
+	return interaction.Response, nil
+}
+
+func (r *ReplayAISystem) find(ctx context.Context, messages []Message) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.match == ReplayStrict {
+		if r.next >= len(r.cassette.Interactions) {
+			return Interaction{}, fmt.Errorf("%w: all %d recorded interactions were served", ErrNoRecording,
+				len(r.cassette.Interactions))
+		}
+		interaction := r.cassette.Interactions[r.next]
+		options := generationOptions(ctx, GenerationOptions{})
+		if !reflect.DeepEqual(normalizeStrict(interaction.Messages), normalizeStrict(messages)) ||
+			!reflect.DeepEqual(interaction.Options, options) {
+			return Interaction{}, fmt.Errorf("%w: call %d differs from the recording, last message %q",
+				ErrNoRecording, r.next+1, lastText(messages))
+		}
+		r.next++
+		return interaction, nil
+	}
+
+	want := normalizeLenient(messages)
+	for _, interaction := range r.cassette.Interactions {
+		if reflect.DeepEqual(normalizeLenient(interaction.Messages), want) {
+			return interaction, nil
+		}
+	}
+	return Interaction{}, fmt.Errorf("%w: last message %q", ErrNoRecording, lastText(messages))
+}
+
+// normalizeStrict makes messages comparable after a JSON round trip, where empty metadata and parts are lost.
+func normalizeStrict(messages []Message) []Message {
+	normalized := make([]Message, len(messages))
+	for i, m := range messages {
+		if len(m.Metadata) == 0 {
+			m.Metadata = nil
+		}
+		if len(m.Parts) == 0 {
+			m.Parts = nil
+		}
+		normalized[i] = m
+	}
+	return normalized
+}
+
+// lenientMessage is what lenient matching compares of a message. The operation and target language stay in, as they
+// are all that tells apart translations of the same text into different languages once system prompts are ignored.
+type lenientMessage struct {
+	role           Role
+	text           string
+	operation      string
+	targetLanguage string
+}
+
+func normalizeLenient(messages []Message) []lenientMessage {
+	var normalized []lenientMessage
+	for _, m := range messages {
+		if m.Role == RoleSystem {
+			continue
+		}
+		normalized = append(normalized, lenientMessage{
+			role:           m.Role,
+			text:           strings.Join(strings.Fields(m.Text()), " "),
+			operation:      m.Metadata[MetadataOperation],
+			targetLanguage: m.Metadata[MetadataTargetLanguage],
+		})
+	}
+	return normalized
+}
+
+func lastText(messages []Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	return messages[len(messages)-1].Text()
+}
@@ -0,0 +1,149 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestCassetteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mock.json")
+	recorder := babel.NewRecordingAISystem(path, babel.NewMockAISystem())
+
+	ctx := context.Background()
+	translationContext, translated, err := babel.NewBabel(recorder).NewTranslation(ctx, "Hello. I like pizza.", language.Japanese)
+	require.NoError(t, err)
+	improved, err := translationContext.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayStrict)
+	require.NoError(t, err)
+	assert.Equal(t, babel.NewMockAISystem().Capabilities(), replay.Capabilities())
+
+	translationContext, replayed, err := babel.NewBabel(replay).NewTranslation(ctx, "Hello. I like pizza.", language.Japanese)
+	require.NoError(t, err)
+	assert.Equal(t, translated, replayed)
+	replayed, err = translationContext.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	assert.Equal(t, improved, replayed)
+	assert.Zero(t, replay.Remaining())
+
+	_, err = translationContext.Improve(ctx, "Make it more formal")
+	assert.ErrorIs(t, err, babel.ErrNoRecording, "a strict replay serves every interaction once")
+}
+
+func TestReplayStrictRejectsChangedCalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strict.json")
+	recorder := babel.NewRecordingAISystem(path, &recordingAISystem{reply: "Hallo"})
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{Temperature: float(0.2)})
+	_, err := recorder.Chat(ctx, []babel.Message{babel.SystemMessage("Translate."), babel.UserMessage("Hello")})
+	require.NoError(t, err)
+
+	for name, call := range map[string]struct {
+		ctx      context.Context
+		messages []babel.Message
+	}{
+		"system prompt": {ctx, []babel.Message{babel.SystemMessage("Translate!"), babel.UserMessage("Hello")}},
+		"metadata": {ctx, []babel.Message{
+			babel.SystemMessage("Translate."),
+			babel.UserMessage("Hello").WithMetadata(babel.MetadataOperation, "translate"),
+		}},
+		"options": {context.Background(), []babel.Message{babel.SystemMessage("Translate."), babel.UserMessage("Hello")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			replay, err := babel.NewReplayAISystem(path, babel.ReplayStrict)
+			require.NoError(t, err)
+			_, err = replay.Chat(call.ctx, call.messages)
+			require.ErrorIs(t, err, babel.ErrNoRecording)
+		})
+	}
+
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayStrict)
+	require.NoError(t, err)
+	result, err := replay.Chat(ctx, []babel.Message{babel.SystemMessage("Translate."), babel.UserMessage("Hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo", result)
+}
+
+func TestReplayLenient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lenient.json")
+	recorder := babel.NewRecordingAISystem(path, &recordingAISystem{})
+	_, err := recorder.Chat(context.Background(), []babel.Message{
+		babel.SystemMessage("Translate."),
+		babel.UserMessage("Hello  world").WithMetadata(babel.MetadataOperation, "translate"),
+	})
+	require.NoError(t, err)
+
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayLenient)
+	require.NoError(t, err)
+	messages := []babel.Message{
+		babel.SystemMessage("Translate into German, keep the tone."),
+		babel.UserMessage(" Hello\nworld ").WithMetadata(babel.MetadataOperation, "translate"),
+	}
+	for range 2 {
+		result, err := replay.Chat(context.Background(), messages)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello  world", result)
+	}
+
+	_, err = replay.Chat(context.Background(), []babel.Message{babel.UserMessage("Goodbye")})
+	require.ErrorIs(t, err, babel.ErrNoRecording)
+}
+
+func TestReplayLenientKeepsTargetLanguages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "languages.json")
+	recorder := babel.NewRecordingAISystem(path, babel.NewMockAISystem())
+	ctx := context.Background()
+	_, japanese, err := babel.NewBabel(recorder).NewTranslation(ctx, "Hello. I like pizza.", language.Japanese)
+	require.NoError(t, err)
+	_, german, err := babel.NewBabel(recorder).NewTranslation(ctx, "Hello. I like pizza.", language.German)
+	require.NoError(t, err)
+	require.NotEqual(t, japanese, german)
+
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayLenient)
+	require.NoError(t, err)
+	_, replayed, err := babel.NewBabel(replay).NewTranslation(ctx, "Hello. I like pizza.", language.German)
+	require.NoError(t, err)
+	assert.Equal(t, german, replayed)
+	_, replayed, err = babel.NewBabel(replay).NewTranslation(ctx, "Hello. I like pizza.", language.Japanese)
+	require.NoError(t, err)
+	assert.Equal(t, japanese, replayed)
+
+	_, _, err = babel.NewBabel(replay).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.ErrorIs(t, err, babel.ErrNoRecording)
+}
+
+func TestReplayStreamsAndFailsLikeTheRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	streaming := babel.NewRecordingAISystem(path, &streamingAISystem{reply: "Hallo schöne Welt"})
+	_, err := streaming.ChatStream(context.Background(), []babel.Message{babel.UserMessage("Hello")}, func(string) error { return nil })
+	require.NoError(t, err)
+
+	replay, err := babel.NewReplayAISystem(path, babel.ReplayStrict)
+	require.NoError(t, err)
+	assert.True(t, replay.Capabilities().Streaming)
+	var deltas []string
+	result, err := replay.ChatStream(context.Background(), []babel.Message{babel.UserMessage("Hello")}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo schöne Welt", result)
+	assert.Equal(t, []string{"Hallo ", "schöne ", "Welt"}, deltas)
+
+	failingPath := filepath.Join(t.TempDir(), "failing.json")
+	failing := babel.NewRecordingAISystem(failingPath, &scriptedAISystem{errs: []error{errors.New("model overloaded")}})
+	_, err = failing.Chat(context.Background(), []babel.Message{babel.UserMessage("Hello")})
+	require.Error(t, err)
+
+	replay, err = babel.NewReplayAISystem(failingPath, babel.ReplayStrict)
+	require.NoError(t, err)
+	_, err = replay.Chat(context.Background(), []babel.Message{babel.UserMessage("Hello")})
+	require.EqualError(t, err, "model overloaded")
+}
@@ -0,0 +1,210 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChaosConfig sets the faults Chaos injects. Rates are probabilities between 0 and 1, drawn independently for
+// every call in the order timeout, error, empty, garbage.
+type ChaosConfig struct {
+	// Latency is added to every call before anything else happens
+	Latency Latency `json:"latency"`
+	// TimeoutRate makes calls hang until the caller gives up
+	TimeoutRate float64 `json:"timeoutRate"`
+	// ErrorRate fails calls with an APIError of ErrorStatus
+	ErrorRate float64 `json:"errorRate"`
+	// ErrorStatus is the HTTP status of injected errors, 503 when zero
+	ErrorStatus int `json:"errorStatus,omitempty"`
+	// EmptyRate replaces the completion with an empty one
+	EmptyRate float64 `json:"emptyRate"`
+	// GarbageRate mangles the completion: truncated, wrapped in chatter or markdown, or with broken characters
+	GarbageRate float64 `json:"garbageRate"`
+}
+
+// Validate reports rates outside [0, 1] and other impossible settings.
+func (c ChaosConfig) Validate() error {
+	var errs []error
+	for _, rate := range []struct {
+		name  string
+		value float64
+	}{
+		{"timeoutRate", c.TimeoutRate},
+		{"errorRate", c.ErrorRate},
+		{"emptyRate", c.EmptyRate},
+		{"garbageRate", c.GarbageRate},
+	} {
+		if rate.value < 0 || rate.value > 1 {
+			errs = append(errs, fmt.Errorf("%s must be between 0 and 1", rate.name))
+		}
+	}
+	if c.ErrorStatus != 0 && (c.ErrorStatus < 400 || c.ErrorStatus > 599) {
+		errs = append(errs, errors.New("errorStatus must be an HTTP error status"))
+	}
+	if err := c.Latency.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Chaos injects faults into the AISystems it wraps, to exercise how the API and UI cope with slow, failing and
+// misbehaving models. The configuration can be changed at runtime and applies to every wrapped system at once.
+// It is meant for development and tests only.
+type Chaos struct {
+	mu     sync.RWMutex
+	config ChaosConfig
+}
+
+// NewChaos returns a Chaos injecting the faults of config.
+func NewChaos(config ChaosConfig) (*Chaos, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Chaos{config: config}, nil
+}
+
+// Config returns the faults currently injected.
+func (c *Chaos) Config() ChaosConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// SetConfig replaces the faults injected from the next call on.
+func (c *Chaos) SetConfig(config ChaosConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+	return nil
+}
+
+// Wrap returns backend with the faults of c injected.
+func (c *Chaos) Wrap(name string, backend AISystem) *ChaosAISystem {
+	return &ChaosAISystem{chaos: c, name: name, backend: backend}
+}
+
+// ChaosAISystem is an AISystem wrapped by Chaos.
+type ChaosAISystem struct {
+	chaos   *Chaos
+	name    string
+	backend AISystem
+}
+
+func (s *ChaosAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return s.chat(ctx, messages, nil)
+}
+
+// ChatStream streams from the wrapped backend. Mangled or emptied completions are delivered at once after the
+// backend finished.
+func (s *ChaosAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return s.chat(ctx, messages, onDelta)
+}
+
+func (s *ChaosAISystem) Capabilities() Capabilities {
+	return s.backend.Capabilities()
+}
+
+// ListModels lists the models of the wrapped backend, listing is not disturbed.
+func (s *ChaosAISystem) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return listModels(ctx, s.backend)
+}
+
+// Close closes the wrapped backend.
+func (s *ChaosAISystem) Close() error {
+	return closeAll(s.backend)
+}
+
+// HealthCheck probes the wrapped backend, probing is not disturbed.
+func (s *ChaosAISystem) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, s.backend)
+}
+
+func (s *ChaosAISystem) chat(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	config := s.chaos.Config()
+
+	if err := sleep(ctx, config.Latency.sample()); err != nil {
+		return "", err
+	}
+	if chance(config.TimeoutRate) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	if chance(config.ErrorRate) {
+		status := config.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return "", &APIError{Provider: s.name, StatusCode: status, Message: "injected by chaos"}
+	}
+
+	empty, garbage := chance(config.EmptyRate), chance(config.GarbageRate)
+	if !empty && !garbage {
+		return chatStream(ctx, s.backend, messages, onDelta)
+	}
+	result, err := chatStream(ctx, s.backend, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	if empty {
+		result = ""
+	} else {
+		result = mangle(result)
+	}
+	if onDelta != nil && result != "" {
+		if err := onDelta(result); err != nil {
+			return "", err
+		}
+	}
+	return result, nil
+}
+
+// mangle turns a completion into one of the malformed outputs models are known to produce.
+func mangle(completion string) string {
+	runes := []rune(completion)
+	switch rand.IntN(4) {
+	case 0:
+		return string(runes[:len(runes)/2])
+	case 1:
+		return "Sure! Here is the translation you asked for:\n\n" + completion + "\n\nLet me know if you need anything else."
+	case 2:
+		return "```\n" + completion + "\n```"
+	default:
+		var b strings.Builder
+		for i, r := range runes {
+			if i%3 == 0 {
+				r = '�'
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+}
+
+// chance reports true with probability rate.
+func chance(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosInjectsFaults(t *testing.T) {
+	chaos, err := babel.NewChaos(babel.ChaosConfig{})
+	require.NoError(t, err)
+	system := chaos.Wrap("test", &recordingAISystem{reply: "Hallo schöne Welt"})
+	messages := []babel.Message{babel.UserMessage("Hello")}
+
+	result, err := system.Chat(context.Background(), messages)
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo schöne Welt", result, "without faults calls pass through")
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{ErrorRate: 1}))
+	_, err = system.Chat(context.Background(), messages)
+	var apiErr *babel.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 503, apiErr.StatusCode)
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{EmptyRate: 1}))
+	result, err = system.Chat(context.Background(), messages)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{GarbageRate: 1}))
+	for range 10 {
+		result, err = system.Chat(context.Background(), messages)
+		require.NoError(t, err)
+		assert.NotEqual(t, "Hallo schöne Welt", result)
+	}
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{TimeoutRate: 1}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = system.Chat(ctx, messages)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{Latency: babel.Latency{Min: 20 * time.Millisecond}}))
+	started := time.Now()
+	_, err = system.Chat(context.Background(), messages)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(started), 20*time.Millisecond)
+}
+
+func TestChaosStreamsUndisturbedCalls(t *testing.T) {
+	chaos, err := babel.NewChaos(babel.ChaosConfig{})
+	require.NoError(t, err)
+	system := chaos.Wrap("test", &streamingAISystem{reply: "Hallo schöne Welt"})
+	assert.True(t, system.Capabilities().Streaming)
+
+	var deltas []string
+	_, err = system.ChatStream(context.Background(), []babel.Message{babel.UserMessage("Hello")}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hallo ", "schöne ", "Welt"}, deltas)
+
+	require.NoError(t, chaos.SetConfig(babel.ChaosConfig{EmptyRate: 1}))
+	deltas = nil
+	result, err := system.ChatStream(context.Background(), []babel.Message{babel.UserMessage("Hello")}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result)
+	assert.Empty(t, deltas)
+}
+
+func TestChaosConfigValidation(t *testing.T) {
+	_, err := babel.NewChaos(babel.ChaosConfig{ErrorRate: 1.5, EmptyRate: -0.1, ErrorStatus: 200})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "errorRate")
+	assert.ErrorContains(t, err, "emptyRate")
+	assert.ErrorContains(t, err, "errorStatus")
+
+	chaos, err := babel.NewChaos(babel.ChaosConfig{GarbageRate: 0.5})
+	require.NoError(t, err)
+	require.Error(t, chaos.SetConfig(babel.ChaosConfig{TimeoutRate: 2}))
+	assert.Equal(t, 0.5, chaos.Config().GarbageRate, "an invalid configuration is not applied")
+
+	var config babel.ChaosConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"latency": {"mean": "1.5s", "stdDev": "200ms"}}`), &config))
+	assert.Equal(t, babel.Latency{Mean: 1500 * time.Millisecond, StdDev: 200 * time.Millisecond}, config.Latency)
+	data, err := json.Marshal(config.Latency)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mean": "1.5s", "stdDev": "200ms"}`, string(data))
+}
@@ -0,0 +1,23 @@
+package babel
+
+import (
+	"errors"
+	"io"
+)
+
+// CloseAISystem stops the background work of system and of every system it wraps, such as the health checks of
+// pools and the processes of plugins. Systems without background work are left alone.
+func CloseAISystem(system AISystem) error {
+	return closeAll(system)
+}
+
+// closeAll closes every system that can be closed, reporting all failures.
+func closeAll(systems ...AISystem) error {
+	var errs []error
+	for _, system := range systems {
+		if closer, ok := system.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
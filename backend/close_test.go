@@ -0,0 +1,45 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closingAISystem counts how often it was closed.
+type closingAISystem struct {
+	*babel.MockAISystem
+	closed int
+}
+
+func (c *closingAISystem) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestCloseAISystemThroughDecorators(t *testing.T) {
+	chaos, err := babel.NewChaos(babel.ChaosConfig{})
+	require.NoError(t, err)
+	wrappers := map[string]func(babel.AISystem) babel.AISystem{
+		"retry": func(s babel.AISystem) babel.AISystem { return babel.NewRetryAISystem("test", s, babel.RetryConfig{}) },
+		"chaos": func(s babel.AISystem) babel.AISystem { return chaos.Wrap("test", s) },
+		"failover": func(s babel.AISystem) babel.AISystem {
+			return babel.NewFailoverAISystem(babel.FailoverConfig{}, babel.FailoverMember{Name: "test", Backend: s})
+		},
+		"recording": func(s babel.AISystem) babel.AISystem {
+			return babel.NewRecordingAISystem(filepath.Join(t.TempDir(), "cassette.json"), s)
+		},
+	}
+	for name, wrap := range wrappers {
+		t.Run(name, func(t *testing.T) {
+			inner := &closingAISystem{MockAISystem: babel.NewMockAISystem()}
+			require.NoError(t, babel.CloseAISystem(wrap(babel.NewRetryAISystem("inner", inner, babel.RetryConfig{}))))
+			assert.Equal(t, 1, inner.closed)
+		})
+	}
+
+	assert.NoError(t, babel.CloseAISystem(babel.NewMockAISystem()), "systems without background work are left alone")
+}
@@ -2,58 +2,211 @@ package babel
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
 
 	cohere "github.com/cohere-ai/cohere-go/v2"
 	client "github.com/cohere-ai/cohere-go/v2/client"
-	"github.com/openai/openai-go"
+	"github.com/cohere-ai/cohere-go/v2/option"
 )
 
 type CohereClient struct {
-	client *client.Client
-	model  string
+	client        *client.Client
+	model         string
+	contextLength int
+	options       GenerationOptions
+}
+
+// CohereConfig configures the Cohere chat API client.
+type CohereConfig struct {
+	APIKey string
+	// Model defaults to c4ai-aya-expanse-8b
+	Model string
+	// BaseURL defaults to https://api.cohere.com, and may point at a proxy or a private deployment
+	BaseURL string
+	// ContextLength is the model's context window in tokens, zero when unknown
+	ContextLength int
+	// Options are the default generation options, requests may override them
+	Options  GenerationOptions
+	Endpoint EndpointConfig
 }
 
 func NewCohereClient(apiKey string, model string) *CohereClient {
-	c := client.NewClient(client.WithToken(apiKey))
+	return newCohereClient(CohereConfig{APIKey: apiKey, Model: model}, http.DefaultClient)
+}
+
+// NewCohereBackend creates a Cohere client from a full endpoint configuration. It fails when the TLS or proxy
+// settings cannot be applied.
+func NewCohereBackend(config CohereConfig) (*CohereClient, error) {
+	httpClient, err := config.Endpoint.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("cohere: %w", err)
+	}
+	return newCohereClient(config, httpClient), nil
+}
 
+func newCohereClient(config CohereConfig, httpClient *http.Client) *CohereClient {
+	// RetryAISystem retries, not the SDK
+	options := []option.RequestOption{
+		option.WithToken(config.APIKey),
+		option.WithHTTPClient(cohereDoer{client: httpClient}),
+		option.WithMaxAttempts(1),
+	}
+	if config.BaseURL != "" {
+		options = append(options, option.WithBaseURL(strings.TrimSuffix(config.BaseURL, "/")))
+	}
+
+	model := config.Model
 	if model == "" {
 		model = "c4ai-aya-expanse-8b"
 	}
 
 	return &CohereClient{
-		client: c,
-		model:  model,
+		client:        client.NewClient(options...),
+		model:         model,
+		contextLength: config.ContextLength,
+		options:       config.Options,
 	}
 }
 
-func (c *CohereClient) Chat(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion) (string, error) {
-	// convert the openai format to the cohere format
-	chatRequest := cohere.ChatRequest{
-		Model: &c.model,
+// cohereDoer fails retryable responses before cohere-go sees them. Even limited to a single attempt, its retrier
+// sleeps out the Retry-After hint, up to a minute and regardless of the context, before giving up.
+type cohereDoer struct {
+	client *http.Client
+}
+
+func (d cohereDoer) Do(req *http.Request) (*http.Response, error) {
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		defer func() { _ = res.Body.Close() }()
+		return nil, checkResponse(res, "cohere", cohereErrorMessage)
 	}
+	return res, nil
+}
 
-	for idx, m := range messages {
-		if m.OfSystem != nil {
-			s := m.OfSystem.Content.OfString.String()
-			chatRequest.Preamble = &s
-		}
-		if m.OfAssistant != nil {
-			s := m.OfAssistant.Content.OfString.String()
-			chatRequest.ChatHistory = append(chatRequest.ChatHistory, &cohere.Message{Chatbot: &cohere.ChatMessage{Message: s}})
-		}
-		if m.OfUser != nil {
-			if idx == len(messages)-1 {
-				chatRequest.Message = m.OfUser.Content.OfString.String()
-			} else {
-				s := m.OfUser.Content.OfString.String()
-				chatRequest.ChatHistory = append(chatRequest.ChatHistory, &cohere.Message{User: &cohere.ChatMessage{Message: s}})
-			}
-		}
+func cohereErrorMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Message
+}
+
+func (c *CohereClient) Capabilities() Capabilities {
+	return Capabilities{
+		ContextLength:      c.contextLength,
+		SamplingParameters: AllSamplingParameters,
+	}
+}
+
+func (c *CohereClient) Chat(ctx context.Context, messages []Message) (string, error) {
+	options := generationOptions(ctx, c.options)
+	model := options.modelOr(c.model)
+	chatRequest, err := toCohereChatRequest(messages)
+	if err != nil {
+		return "", err
 	}
+	chatRequest.Model = &model
+	chatRequest.Temperature = options.Temperature
+	chatRequest.P = options.TopP
+	chatRequest.MaxTokens = options.MaxTokens
+	chatRequest.Seed = options.Seed
+	chatRequest.StopSequences = options.Stop
 
 	chatResponse, err := c.client.Chat(ctx, &chatRequest)
 	if err != nil {
 		return "", err
 	}
+	if info := callInfoFromContext(ctx); info != nil && chatResponse.Meta != nil && chatResponse.Meta.Tokens != nil {
+		tokens := chatResponse.Meta.Tokens
+		if tokens.InputTokens != nil {
+			info.Usage.PromptTokens = int(*tokens.InputTokens)
+		}
+		if tokens.OutputTokens != nil {
+			info.Usage.CompletionTokens = int(*tokens.OutputTokens)
+		}
+	}
 	return chatResponse.Text, nil
 }
+
+// HealthCheck lists a single chat model, which checks the key without generating anything.
+func (c *CohereClient) HealthCheck(ctx context.Context) error {
+	endpoint := cohere.CompatibleEndpointChat
+	pageSize := float64(1)
+	_, err := c.client.Models.List(ctx, &cohere.ModelsListRequest{Endpoint: &endpoint, PageSize: &pageSize})
+	return err
+}
+
+// ListModels lists the models usable with the chat endpoint along with the context length Cohere reports for
+// each of them.
+func (c *CohereClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	endpoint := cohere.CompatibleEndpointChat
+	pageSize := float64(1000)
+	request := &cohere.ModelsListRequest{Endpoint: &endpoint, PageSize: &pageSize}
+	var models []ModelInfo
+	for {
+		res, err := c.client.Models.List(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range res.Models {
+			if m.Name == nil {
+				continue
+			}
+			capabilities := c.Capabilities()
+			if *m.Name != c.model {
+				capabilities.ContextLength = 0
+			}
+			if m.ContextLength != nil {
+				capabilities.ContextLength = int(*m.ContextLength)
+			}
+			models = append(models, ModelInfo{Name: *m.Name, Capabilities: capabilities})
+		}
+		if res.NextPageToken == nil || *res.NextPageToken == "" {
+			return models, nil
+		}
+		request.PageToken = res.NextPageToken
+	}
+}
+
+// toCohereChatRequest maps the history to Cohere's chat request. The system messages before the first turn become
+// the preamble, later ones stay in the chat history so none of them is lost, and a final user message is the
+// message to answer. Cohere takes a single string per message, so messages with several parts are rejected.
+func toCohereChatRequest(messages []Message) (cohere.ChatRequest, error) {
+	var chatRequest cohere.ChatRequest
+	var preamble []string
+	for idx, m := range messages {
+		text, err := singleText(m)
+		if err != nil {
+			return cohere.ChatRequest{}, err
+		}
+		switch m.Role {
+		case RoleSystem:
+			if len(chatRequest.ChatHistory) == 0 {
+				preamble = append(preamble, text)
+				continue
+			}
+			chatRequest.ChatHistory = append(chatRequest.ChatHistory, &cohere.Message{System: &cohere.ChatMessage{Message: text}})
+		case RoleAssistant:
+			chatRequest.ChatHistory = append(chatRequest.ChatHistory, &cohere.Message{Chatbot: &cohere.ChatMessage{Message: text}})
+		case RoleUser:
+			if idx == len(messages)-1 {
+				chatRequest.Message = text
+				continue
+			}
+			chatRequest.ChatHistory = append(chatRequest.ChatHistory, &cohere.Message{User: &cohere.ChatMessage{Message: text}})
+		}
+	}
+	if len(preamble) > 0 {
+		s := strings.Join(preamble, "\n\n")
+		chatRequest.Preamble = &s
+	}
+	return chatRequest, nil
+}
@@ -0,0 +1,169 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cohereChatRequest struct {
+	Model         string   `json:"model"`
+	Temperature   *float64 `json:"temperature"`
+	P             *float64 `json:"p"`
+	MaxTokens     *int     `json:"max_tokens"`
+	Seed          *int     `json:"seed"`
+	StopSequences []string `json:"stop_sequences"`
+	Message       string   `json:"message"`
+	Preamble      *string  `json:"preamble"`
+	ChatHistory   []struct {
+		Role    string `json:"role"`
+		Message string `json:"message"`
+	} `json:"chat_history"`
+}
+
+// messages maps the request back to the history it was made from.
+func (r cohereChatRequest) messages() []babel.Message {
+	var messages []babel.Message
+	if r.Preamble != nil {
+		messages = append(messages, babel.SystemMessage(*r.Preamble))
+	}
+	roles := map[string]babel.Role{"SYSTEM": babel.RoleSystem, "USER": babel.RoleUser, "CHATBOT": babel.RoleAssistant}
+	for _, m := range r.ChatHistory {
+		messages = append(messages, babel.Message{Role: roles[m.Role], Parts: []babel.Part{{Text: m.Message}}})
+	}
+	return append(messages, babel.UserMessage(r.Message))
+}
+
+func newFakeCohere(t *testing.T, requests *[]cohereChatRequest) *babel.CohereClient {
+	return newFakeCohereWithOptions(t, requests, babel.GenerationOptions{})
+}
+
+func newFakeCohereWithOptions(t *testing.T, requests *[]cohereChatRequest, options babel.GenerationOptions) *babel.CohereClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req cohereChatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		*requests = append(*requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":"ok","generation_id":"1"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := babel.NewCohereBackend(babel.CohereConfig{APIKey: "test-key", BaseURL: server.URL, Options: options})
+	require.NoError(t, err)
+	return c
+}
+
+func TestCohereRoundTrip(t *testing.T) {
+	var requests []cohereChatRequest
+	c := newFakeCohere(t, &requests)
+
+	_, err := c.Chat(context.Background(), plainConversation)
+	require.NoError(t, err)
+	require.Equal(t, withoutMetadata(plainConversation), requests[0].messages())
+}
+
+func TestCohereRejectsMultipartMessages(t *testing.T) {
+	var requests []cohereChatRequest
+	c := newFakeCohere(t, &requests)
+
+	_, err := c.Chat(context.Background(), conversation)
+	require.ErrorIs(t, err, babel.ErrMultipartUnsupported)
+	require.Empty(t, requests)
+}
+
+func TestCohereKeepsEverySystemMessage(t *testing.T) {
+	var requests []cohereChatRequest
+	c := newFakeCohere(t, &requests)
+
+	_, err := c.Chat(context.Background(), []babel.Message{
+		babel.SystemMessage("first rule"),
+		babel.SystemMessage("second rule"),
+		babel.UserMessage("Hello."),
+		babel.AssistantMessage("Hola."),
+		babel.SystemMessage("third rule"),
+		babel.UserMessage("Again."),
+	})
+	require.NoError(t, err)
+
+	req := requests[0]
+	require.NotNil(t, req.Preamble)
+	require.True(t, strings.Contains(*req.Preamble, "first rule") && strings.Contains(*req.Preamble, "second rule"))
+	require.Len(t, req.ChatHistory, 3)
+	require.Equal(t, "SYSTEM", req.ChatHistory[2].Role)
+	require.Equal(t, "third rule", req.ChatHistory[2].Message)
+	require.Equal(t, "Again.", req.Message)
+}
+
+func TestCohereGenerationOptions(t *testing.T) {
+	var requests []cohereChatRequest
+	c := newFakeCohereWithOptions(t, &requests, babel.GenerationOptions{Temperature: float(0.3), Seed: integer(1)})
+
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{
+		Model:     "command-r",
+		TopP:      float(0.8),
+		MaxTokens: integer(300),
+		Stop:      []string{"END"},
+	})
+	_, err := c.Chat(ctx, []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	req := requests[0]
+	require.Equal(t, "command-r", req.Model)
+	require.Equal(t, float(0.3), req.Temperature)
+	require.Equal(t, float(0.8), req.P)
+	require.Equal(t, integer(300), req.MaxTokens)
+	require.Equal(t, integer(1), req.Seed)
+	require.Equal(t, []string{"END"}, req.StopSequences)
+}
+
+func TestCohereListModels(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		assert.Equal(t, "chat", r.URL.Query().Get("endpoint"))
+		pages = append(pages, r.URL.Query().Get("page_token"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page_token") == "" {
+			_, _ = w.Write([]byte(`{"models":[{"name":"c4ai-aya-expanse-8b","endpoints":["chat"],"context_length":8192}],"next_page_token":"2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"command-r","endpoints":["chat"],"context_length":128000},{"name":"command-light","endpoints":["chat"]}]}`))
+	}))
+	defer server.Close()
+
+	c, err := babel.NewCohereBackend(babel.CohereConfig{APIKey: "test-key", BaseURL: server.URL, ContextLength: 4096})
+	require.NoError(t, err)
+	models, err := c.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "2"}, pages)
+
+	lengths := map[string]int{}
+	for _, m := range models {
+		lengths[m.Name] = m.Capabilities.ContextLength
+	}
+	// the length Cohere reports wins, models without one have an unknown length
+	require.Equal(t, map[string]int{"c4ai-aya-expanse-8b": 8192, "command-r": 128000, "command-light": 0}, lengths)
+}
+
+func TestCohereHealthCheck(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path, "no chat is sent")
+		queries = append(queries, r.URL.Query().Get("page_size"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"models":[{"name":"c4ai-aya-expanse-8b","endpoints":["chat"]}]}`))
+	}))
+	defer server.Close()
+
+	c, err := babel.NewCohereBackend(babel.CohereConfig{APIKey: "test-key", BaseURL: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, c.HealthCheck(context.Background()))
+	require.Equal(t, []string{"1"}, queries)
+}
@@ -0,0 +1,67 @@
+package babel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// Candidate is a named backend taking part in a side-by-side comparison.
+type Candidate struct {
+	Name    string
+	Backend AISystem
+}
+
+// ComparisonResult is the outcome of a single candidate in a comparison.
+type ComparisonResult struct {
+	Name    string
+	Result  string
+	Err     error
+	Latency time.Duration
+	Usage   Usage
+	// Context continues the candidate's translation on the same backend, nil when the candidate failed
+	Context *TranslationContext
+}
+
+// SetCompareCandidates configures the backends Compare runs side by side. It must be called before the Backend is used.
+func (b *Backend) SetCompareCandidates(candidates ...Candidate) {
+	b.candidates = candidates
+}
+
+// CompareCandidates returns the names of the configured comparison candidates.
+func (b *Backend) CompareCandidates() []string {
+	names := make([]string, 0, len(b.candidates))
+	for _, c := range b.candidates {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// Compare translates input on every configured candidate in parallel and returns the results in candidate order.
+// Language routes and operation tiers are bypassed so each candidate is measured on its own. Every successful
+// result carries a context that can be improved like one returned by NewTranslation.
+func (b *Backend) Compare(ctx context.Context, input string, outputLanguage language.Tag) []ComparisonResult {
+	results := make([]ComparisonResult, len(b.candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range b.candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCtx, info := WithCallInfo(ctx)
+			start := time.Now()
+			translationContext, result, err := b.translate(callCtx, OperationStart, candidate.Backend, input, outputLanguage, nil)
+			results[i] = ComparisonResult{
+				Name:    candidate.Name,
+				Result:  result,
+				Err:     err,
+				Latency: time.Since(start),
+				Usage:   info.Usage,
+				Context: translationContext,
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,44 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestCompareRunsCandidatesInParallel(t *testing.T) {
+	broken := &flakyAISystem{mock: babel.NewMockAISystem()}
+	broken.down.Store(true)
+
+	b := babel.NewBabel(babel.NewMockAISystem())
+	b.SetCompareCandidates(
+		babel.Candidate{Name: "slow", Backend: babel.NewMockAISystemWithDelay(100 * time.Millisecond)},
+		babel.Candidate{Name: "also-slow", Backend: babel.NewMockAISystemWithDelay(100 * time.Millisecond)},
+		babel.Candidate{Name: "broken", Backend: broken},
+	)
+	require.Equal(t, []string{"slow", "also-slow", "broken"}, b.CompareCandidates())
+
+	start := time.Now()
+	results := b.Compare(context.Background(), "Hello. I like pizza.", language.Spanish)
+	require.Less(t, time.Since(start), 190*time.Millisecond, "candidates should run in parallel")
+
+	require.Len(t, results, 3)
+	for _, r := range results[:2] {
+		require.NoError(t, r.Err)
+		require.Equal(t, "Hola. Me gusta la pizza.", r.Result)
+		require.GreaterOrEqual(t, r.Latency, 100*time.Millisecond)
+		require.NotNil(t, r.Context)
+	}
+	require.Equal(t, "broken", results[2].Name)
+	require.Error(t, results[2].Err)
+	require.Nil(t, results[2].Context)
+
+	// a candidate's context continues like any other translation
+	improved, err := results[0].Context.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me encanta la pizza.", improved)
+}
@@ -0,0 +1,148 @@
+package babel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DeepLConfig configures the DeepL translation API client.
+type DeepLConfig struct {
+	APIKey string
+	// BaseURL defaults to https://api-free.deepl.com for free keys, which end in ":fx", and https://api.deepl.com otherwise
+	BaseURL string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// DeepLTranslator translates with the DeepL v2 REST API.
+type DeepLTranslator struct {
+	config DeepLConfig
+}
+
+// NewDeepLTranslator creates a DeepL client, filling in defaults for unset configuration.
+func NewDeepLTranslator(config DeepLConfig) *DeepLTranslator {
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.deepl.com"
+		if strings.HasSuffix(config.APIKey, ":fx") {
+			config.BaseURL = "https://api-free.deepl.com"
+		}
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &DeepLTranslator{config: config}
+}
+
+type deeplRequest struct {
+	Text       []string `json:"text"`
+	TargetLang string   `json:"target_lang"`
+	SourceLang string   `json:"source_lang,omitempty"`
+}
+
+type deeplResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+func deeplErrorMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Message
+}
+
+// deeplTargetLang maps tag to a DeepL target language code. English, Portuguese and Chinese require a variant.
+func deeplTargetLang(tag language.Tag) string {
+	base, script, region := tag.Raw()
+	switch base.String() {
+	case "en":
+		if region.String() == "GB" {
+			return "EN-GB"
+		}
+		return "EN-US"
+	case "pt":
+		if region.String() == "PT" {
+			return "PT-PT"
+		}
+		return "PT-BR"
+	case "zh":
+		if script.String() == "Hant" || region.String() == "TW" || region.String() == "HK" || region.String() == "MO" {
+			return "ZH-HANT"
+		}
+		return "ZH-HANS"
+	}
+	return strings.ToUpper(base.String())
+}
+
+// deeplSourceLang maps tag to a DeepL source language code, which never carries a variant.
+func deeplSourceLang(tag language.Tag) string {
+	if tag == language.Und {
+		return ""
+	}
+	base, _ := tag.Base()
+	return strings.ToUpper(base.String())
+}
+
+func (d *DeepLTranslator) translate(ctx context.Context, text string, source, target language.Tag) (deeplResponse, error) {
+	var res deeplResponse
+	req, err := newJSONRequest(ctx, d.config.BaseURL+"/v2/translate", deeplRequest{
+		Text:       []string{text},
+		TargetLang: deeplTargetLang(target),
+		SourceLang: deeplSourceLang(source),
+	})
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.config.APIKey)
+
+	if err := doJSON(d.config.HTTPClient, req, "deepl", deeplErrorMessage, &res); err != nil {
+		return res, err
+	}
+	if len(res.Translations) == 0 {
+		return res, fmt.Errorf("deepl: no translation returned")
+	}
+	return res, nil
+}
+
+// HealthCheck asks for the usage of the key, which is not billed unlike detection.
+func (d *DeepLTranslator) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.config.BaseURL+"/v2/usage", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.config.APIKey)
+	return doJSON(d.config.HTTPClient, req, "deepl", deeplErrorMessage, nil)
+}
+
+func (d *DeepLTranslator) Translate(ctx context.Context, text string, source, target language.Tag) (string, error) {
+	res, err := d.translate(ctx, text, source, target)
+	if err != nil {
+		return "", err
+	}
+	return res.Translations[0].Text, nil
+}
+
+// Detect identifies the language of text. DeepL has no detection endpoint, so this translates the text to English
+// and reports the detected source language, which is billed like any other translation.
+func (d *DeepLTranslator) Detect(ctx context.Context, text string) (language.Tag, error) {
+	res, err := d.translate(ctx, text, language.Und, language.English)
+	if err != nil {
+		return language.Und, err
+	}
+	tag, err := language.Parse(res.Translations[0].DetectedSourceLanguage)
+	if err != nil {
+		return language.Und, fmt.Errorf("deepl: %w", err)
+	}
+	return tag, nil
+}
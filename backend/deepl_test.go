@@ -0,0 +1,122 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type deeplRequest struct {
+	Text       []string `json:"text"`
+	TargetLang string   `json:"target_lang"`
+	SourceLang string   `json:"source_lang"`
+}
+
+// newFakeDeepL serves /v2/translate with a fixed translation and records the requests it saw.
+func newFakeDeepL(t *testing.T, detected, translation string, requests *[]deeplRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/translate", r.URL.Path)
+		assert.Equal(t, "DeepL-Auth-Key test-key", r.Header.Get("Authorization"))
+
+		var req deeplRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"translations": []map[string]string{{"detected_source_language": detected, "text": translation}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDeepLTranslate(t *testing.T) {
+	var requests []deeplRequest
+	server := newFakeDeepL(t, "EN", "Hola. Me gusta la pizza.", &requests)
+	d := babel.NewDeepLTranslator(babel.DeepLConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	result, err := d.Translate(context.Background(), "Hello. I like pizza.", language.English, language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, []string{"Hello. I like pizza."}, requests[0].Text)
+	require.Equal(t, "ES", requests[0].TargetLang)
+	require.Equal(t, "EN", requests[0].SourceLang)
+
+	// variants are required for some targets, and an unknown source is left for DeepL to detect
+	for _, tc := range []struct {
+		target language.Tag
+		code   string
+	}{
+		{language.English, "EN-US"},
+		{language.BritishEnglish, "EN-GB"},
+		{language.Portuguese, "PT-BR"},
+		{language.EuropeanPortuguese, "PT-PT"},
+		{language.SimplifiedChinese, "ZH-HANS"},
+		{language.TraditionalChinese, "ZH-HANT"},
+		{language.Japanese, "JA"},
+	} {
+		_, err := d.Translate(context.Background(), "text", language.Und, tc.target)
+		require.NoError(t, err)
+		last := requests[len(requests)-1]
+		require.Equal(t, tc.code, last.TargetLang, tc.target.String())
+		require.Empty(t, last.SourceLang)
+	}
+}
+
+func TestDeepLDetect(t *testing.T) {
+	var requests []deeplRequest
+	server := newFakeDeepL(t, "JA", "Hello.", &requests)
+	d := babel.NewDeepLTranslator(babel.DeepLConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	tag, err := d.Detect(context.Background(), "こんにちは。")
+	require.NoError(t, err)
+	require.Equal(t, language.Japanese, tag)
+	require.Equal(t, "EN-US", requests[0].TargetLang)
+}
+
+func TestDeepLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(456)
+		_, _ = w.Write([]byte(`{"message":"Quota exceeded"}`))
+	}))
+	defer server.Close()
+	d := babel.NewDeepLTranslator(babel.DeepLConfig{APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := d.Translate(context.Background(), "Hello.", language.Und, language.German)
+	var apiErr *babel.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, 456, apiErr.StatusCode)
+	require.Equal(t, "Quota exceeded", apiErr.Message)
+}
+
+func TestDeepLHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v2/usage", r.URL.Path, "usage is not billed, unlike detection")
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"Wrong endpoint"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"character_count":180,"character_limit":500000}`))
+	}))
+	defer server.Close()
+
+	require.NoError(t, babel.NewDeepLTranslator(babel.DeepLConfig{APIKey: "test-key", BaseURL: server.URL}).HealthCheck(context.Background()))
+	err := babel.NewDeepLTranslator(babel.DeepLConfig{APIKey: "wrong", BaseURL: server.URL}).HealthCheck(context.Background())
+	var apiErr *babel.APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+}
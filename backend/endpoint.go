@@ -0,0 +1,97 @@
+package babel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// EndpointConfig describes how to reach an HTTP API beyond its URL: extra headers, timeouts, a proxy and TLS
+// settings. The zero value behaves like http.DefaultClient.
+type EndpointConfig struct {
+	// Headers are added to every request, e.g. for an authenticating gateway
+	Headers http.Header
+	// Timeout bounds a whole request including reading the response. Zero means no limit.
+	Timeout time.Duration
+	// ConnectTimeout bounds dialing the server. Defaults to 30s.
+	ConnectTimeout time.Duration
+	// ProxyURL sends requests through this proxy instead of the one from HTTP_PROXY and HTTPS_PROXY
+	ProxyURL string
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to the system pool
+	CAFile string
+	// CertFile and KeyFile hold a client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables certificate verification and must only be used in development
+	InsecureSkipVerify bool
+}
+
+// HTTPClient builds a client applying the configuration. Certificate and key files are read once, here.
+func (c EndpointConfig) HTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	connectTimeout := c.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = 30 * time.Second
+	}
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+
+	if c.ProxyURL != "" {
+		proxy, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.InsecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA file: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", c.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if c.CertFile != "" || c.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if len(c.Headers) > 0 {
+		roundTripper = &headerTransport{headers: c.Headers, next: transport}
+	}
+	return &http.Client{Transport: roundTripper, Timeout: c.Timeout}, nil
+}
+
+// headerTransport sets fixed headers on every request, replacing values set by the API client.
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range h.headers {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	return h.next.RoundTrip(req)
+}
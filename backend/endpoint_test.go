@@ -0,0 +1,144 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+const chatCompletion = `{"id":"1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hola. Me gusta la pizza."},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":4}}`
+
+// writeCAFile stores the certificate of a TLS test server as a PEM bundle.
+func writeCAFile(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, block, 0o600))
+	return path
+}
+
+func TestOpenAIEndpointConfig(t *testing.T) {
+	var model string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/gateway/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		assert.Equal(t, "team-a", r.Header.Get("X-Tenant"))
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		model = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletion))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{
+		BaseURL: server.URL + "/gateway/v1",
+		APIKey:  "test-key",
+		Model:   "test-model",
+		Endpoint: babel.EndpointConfig{
+			Headers: http.Header{"X-Tenant": {"team-a"}},
+			Timeout: 5 * time.Second,
+			CAFile:  writeCAFile(t, server),
+		},
+	})
+	require.NoError(t, err)
+
+	_, result, err := babel.NewBabel(o).NewTranslation(context.Background(), "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, "test-model", model)
+}
+
+func TestOpenAIEndpointUntrustedCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletion))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	_, err = o.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.Error(t, err)
+
+	// development setups can opt out of verification
+	o, err = babel.NewOpenAIBackend(babel.OpenAIConfig{
+		BaseURL:  server.URL + "/v1",
+		Endpoint: babel.EndpointConfig{InsecureSkipVerify: true},
+	})
+	require.NoError(t, err)
+	_, err = o.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+}
+
+func TestOpenAIEndpointInvalidFiles(t *testing.T) {
+	_, err := babel.NewOpenAIBackend(babel.OpenAIConfig{Endpoint: babel.EndpointConfig{CAFile: "/does/not/exist.pem"}})
+	require.Error(t, err)
+
+	_, err = babel.NewOpenAIBackend(babel.OpenAIConfig{Endpoint: babel.EndpointConfig{ProxyURL: "://bad"}})
+	require.Error(t, err)
+}
+
+func TestAzureOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/deployments/my-deployment/chat/completions", r.URL.Path)
+		assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "azure-key", r.Header.Get("api-key"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletion))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{
+		BaseURL: server.URL,
+		APIKey:  "azure-key",
+		Model:   "my-deployment",
+		Azure:   true,
+	})
+	require.NoError(t, err)
+
+	result, err := o.Chat(context.Background(), []babel.Message{babel.UserMessage("Hello. I like pizza.")})
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+
+	_, err = babel.NewOpenAIBackend(babel.OpenAIConfig{Azure: true})
+	require.Error(t, err)
+}
+
+func TestCohereBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/cohere/v1/chat", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		assert.Equal(t, "team-a", r.Header.Get("X-Tenant"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":"Hola. Me gusta la pizza.","generation_id":"1","meta":{"tokens":{"input_tokens":9,"output_tokens":4}}}`))
+	}))
+	defer server.Close()
+
+	c, err := babel.NewCohereBackend(babel.CohereConfig{
+		APIKey:   "test-key",
+		BaseURL:  server.URL + "/cohere/",
+		Endpoint: babel.EndpointConfig{Headers: http.Header{"X-Tenant": {"team-a"}}},
+	})
+	require.NoError(t, err)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	result, err := c.Chat(ctx, []babel.Message{babel.UserMessage("Hello. I like pizza.")})
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, babel.Usage{PromptTokens: 9, CompletionTokens: 4}, info.Usage)
+}
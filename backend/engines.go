@@ -0,0 +1,417 @@
+package babel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// the engines shipped with babel, further engines register themselves from their own packages
+func init() {
+	RegisterEngine(Engine{
+		Name:        "mock",
+		Description: "Canned answers for testing, no model involved",
+		Settings: []Setting{
+			{Name: "MOCK_FIXTURES", Description: "JSON or YAML file of scenarios to answer from instead of the built-in answers"},
+		},
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			if path := s.String("MOCK_FIXTURES"); path != "" {
+				slog.Info("Using mock backend with fixtures", "fixtures", path)
+				return NewFixtureAISystemFromFile(path)
+			}
+			slog.Info("Using mock backend for testing")
+			return NewMockAISystem(), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "replay",
+		Description: "Answers recorded from a real engine, no network involved",
+		Settings: []Setting{
+			{Name: "REPLAY_CASSETTE", Required: true, Description: "cassette file written by RecordingAISystem"},
+			{Name: "REPLAY_MATCH", Default: ReplayLenient.String(), Values: []string{ReplayStrict.String(), ReplayLenient.String()}, Description: "how calls are matched against the recording"},
+		},
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			match, err := ParseReplayMatch(s.String("REPLAY_MATCH"))
+			if err != nil {
+				return nil, err
+			}
+			slog.Info("Using replay backend", "cassette", s.String("REPLAY_CASSETTE"), "match", match)
+			return NewReplayAISystem(s.String("REPLAY_CASSETTE"), match)
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "openai",
+		Description: "OpenAI compatible chat completions, e.g. OpenAI, Azure OpenAI, vLLM or Ollama's /v1",
+		Settings: append([]Setting{
+			{Name: "OPENAI_HOST", Default: "localhost", Description: "host of the server, ignored when OPENAI_BASE_URL is set"},
+			{Name: "OPENAI_PORT", Type: SettingInt, Default: "11434", Description: "port of the server"},
+			{Name: "OPENAI_BASE_URL", Type: SettingURL, Description: "full base URL including any path prefix, or the Azure resource endpoint"},
+			{Name: "OPENAI_MODEL", Default: "aya-expanse:8b", Description: "model, or deployment name with Azure"},
+			{Name: "OPENAI_API_KEY", Description: "API key, unauthenticated when empty"},
+			{Name: "OPENAI_AZURE", Type: SettingBool, Default: "false", Description: "talk to an Azure OpenAI deployment"},
+			{Name: "OPENAI_API_VERSION", Default: "2024-10-21", Description: "Azure api-version"},
+			{Name: "OPENAI_CONTEXT_LENGTH", Type: SettingInt, Description: "context window of the model in tokens"},
+			{Name: "OPENAI_HOSTS", Description: "comma separated host:port or base URLs to pool instead of a single server"},
+			{Name: "OPENAI_POOL_POLICY", Default: string(PoolLeastOutstanding), Values: []string{string(PoolLeastOutstanding), string(PoolRoundRobin)}, Description: "how the pool picks a host"},
+			{Name: "OPENAI_POOL_AFFINITY", Type: SettingBool, Default: "false", Description: "keep each translation context on one host"},
+		}, slices.Concat(generationSettings("OPENAI", AllSamplingParameters...), endpointSettings("OPENAI"))...),
+		ModelSetting: "OPENAI_MODEL",
+		New:          newOpenAIEngine,
+	})
+
+	RegisterEngine(Engine{
+		Name:        "ollama",
+		Description: "Ollama's native API with keep-alive, options, model pulls and warm-up",
+		Settings: append([]Setting{
+			{Name: "OLLAMA_BASE_URL", Type: SettingURL, Default: "http://localhost:11434", Description: "URL of the Ollama server"},
+			{Name: "OLLAMA_MODEL", Default: "aya-expanse:8b", Description: "model"},
+			{Name: "OLLAMA_KEEP_ALIVE", Description: "how long the model stays loaded, e.g. 30m or -1"},
+			{Name: "OLLAMA_NUM_CTX", Type: SettingInt, Description: "context window in tokens"},
+			{Name: "OLLAMA_OPTIONS", Type: SettingJSON, Description: `model options as a JSON object, e.g. {"temperature":0.2}`},
+			{Name: "OLLAMA_PULL", Type: SettingBool, Default: "false", Description: "pull the model at startup when missing"},
+			{Name: "OLLAMA_WARMUP", Type: SettingBool, Default: "true", Description: "load the model before serving"},
+			{Name: "OLLAMA_PULL_TIMEOUT", Type: SettingDuration, Default: "30m", Description: "time allowed to pull and load the model before serving"},
+		}, generationSettings("OLLAMA", AllSamplingParameters...)...),
+		ModelSetting: "OLLAMA_MODEL",
+		New:          newOllamaEngine,
+	})
+
+	RegisterEngine(Engine{
+		Name:        "cohere",
+		Description: "Cohere chat API",
+		Settings: append([]Setting{
+			{Name: "COHERE_API_KEY", Required: true, Description: "API key"},
+			{Name: "COHERE_MODEL", Default: "c4ai-aya-expanse-8b", Description: "model"},
+			{Name: "COHERE_BASE_URL", Type: SettingURL, Description: "base URL, defaults to https://api.cohere.com"},
+			{Name: "COHERE_CONTEXT_LENGTH", Type: SettingInt, Default: "8192", Description: "context window of the model in tokens"},
+		}, slices.Concat(generationSettings("COHERE", AllSamplingParameters...), endpointSettings("COHERE"))...),
+		ModelSetting: "COHERE_MODEL",
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			endpoint, err := endpointConfig("COHERE", s)
+			if err != nil {
+				return nil, err
+			}
+			options, err := generationConfig("COHERE", s)
+			if err != nil {
+				return nil, err
+			}
+			system, err := NewCohereBackend(CohereConfig{
+				APIKey:        s.String("COHERE_API_KEY"),
+				Model:         s.String("COHERE_MODEL"),
+				BaseURL:       s.String("COHERE_BASE_URL"),
+				ContextLength: s.Int("COHERE_CONTEXT_LENGTH"),
+				Options:       options,
+				Endpoint:      endpoint,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return NewRetryAISystem("cohere", system, RetryConfig{}), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "anthropic",
+		Description: "Anthropic Messages API",
+		Settings: append([]Setting{
+			{Name: "ANTHROPIC_API_KEY", Required: true, Description: "API key"},
+			{Name: "ANTHROPIC_MODEL", Default: "claude-3-5-haiku-latest", Description: "model"},
+			{Name: "ANTHROPIC_BASE_URL", Type: SettingURL, Default: "https://api.anthropic.com", Description: "base URL"},
+			{Name: "ANTHROPIC_CONTEXT_LENGTH", Type: SettingInt, Default: "200000", Description: "context window of the model in tokens"},
+		}, generationSettings("ANTHROPIC", ParamTemperature, ParamTopP, ParamMaxTokens, ParamStop)...),
+		ModelSetting: "ANTHROPIC_MODEL",
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			options, err := generationConfig("ANTHROPIC", s)
+			if err != nil {
+				return nil, err
+			}
+			return NewRetryAISystem("anthropic", NewAnthropicBackend(AnthropicConfig{
+				APIKey:        s.String("ANTHROPIC_API_KEY"),
+				Model:         s.String("ANTHROPIC_MODEL"),
+				BaseURL:       s.String("ANTHROPIC_BASE_URL"),
+				ContextLength: s.Int("ANTHROPIC_CONTEXT_LENGTH"),
+				Options:       options,
+			}), RetryConfig{}), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "gemini",
+		Description: "Google Gemini generateContent API",
+		Settings: append([]Setting{
+			{Name: "GEMINI_API_KEY", Required: true, Description: "API key"},
+			{Name: "GEMINI_MODEL", Default: "gemini-2.0-flash", Description: "model"},
+			{Name: "GEMINI_BASE_URL", Type: SettingURL, Default: "https://generativelanguage.googleapis.com/v1beta", Description: "base URL"},
+			{Name: "GEMINI_CONTEXT_LENGTH", Type: SettingInt, Default: "1048576", Description: "context window of the model in tokens"},
+		}, generationSettings("GEMINI", AllSamplingParameters...)...),
+		ModelSetting: "GEMINI_MODEL",
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			options, err := generationConfig("GEMINI", s)
+			if err != nil {
+				return nil, err
+			}
+			return NewRetryAISystem("gemini", NewGeminiBackend(GeminiConfig{
+				APIKey:        s.String("GEMINI_API_KEY"),
+				Model:         s.String("GEMINI_MODEL"),
+				BaseURL:       s.String("GEMINI_BASE_URL"),
+				ContextLength: s.Int("GEMINI_CONTEXT_LENGTH"),
+				Options:       options,
+			}), RetryConfig{}), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "plugin",
+		Description: "An executable speaking JSON lines over stdin and stdout",
+		Settings: append([]Setting{
+			{Name: "PLUGIN_COMMAND", Required: true, Description: "executable to launch"},
+			{Name: "PLUGIN_ARGS", Description: "space separated arguments"},
+			{Name: "PLUGIN_MODEL", Description: "model passed to the plugin with every request"},
+			{Name: "PLUGIN_TIMEOUT", Type: SettingDuration, Default: "2m", Description: "time allowed per request before the process is restarted"},
+			{Name: "PLUGIN_CONTEXT_LENGTH", Type: SettingInt, Description: "context window of the model in tokens"},
+			{Name: "PLUGIN_SAMPLING_PARAMETERS", Description: "comma separated sampling parameters the plugin honours, e.g. temperature,max_tokens"},
+		}, generationSettings("PLUGIN", AllSamplingParameters...)...),
+		ModelSetting: "PLUGIN_MODEL",
+		New: func(_ context.Context, s Settings) (AISystem, error) {
+			slog.Info("Using plugin backend", "command", s.String("PLUGIN_COMMAND"))
+			var params []string
+			for _, param := range strings.Split(s.String("PLUGIN_SAMPLING_PARAMETERS"), ",") {
+				if param = strings.TrimSpace(param); param == "" {
+					continue
+				}
+				if !slices.Contains(AllSamplingParameters, param) {
+					return nil, fmt.Errorf("invalid PLUGIN_SAMPLING_PARAMETERS entry %q, must be one of %s", param, strings.Join(AllSamplingParameters, ", "))
+				}
+				params = append(params, param)
+			}
+			options, err := generationConfig("PLUGIN", s)
+			if err != nil {
+				return nil, err
+			}
+			// a local process gains nothing from retries, a timeout already restarted it
+			return NewPluginAISystem(PluginConfig{
+				Command:            s.String("PLUGIN_COMMAND"),
+				Args:               strings.Fields(s.String("PLUGIN_ARGS")),
+				Model:              s.String("PLUGIN_MODEL"),
+				Timeout:            s.Duration("PLUGIN_TIMEOUT"),
+				ContextLength:      s.Int("PLUGIN_CONTEXT_LENGTH"),
+				SamplingParameters: params,
+				Options:            options,
+			}), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "deepl",
+		Description: "DeepL machine translation, improvements need a POST_EDITOR",
+		Settings: []Setting{
+			{Name: "DEEPL_API_KEY", Required: true, Description: "API key, free keys end in :fx"},
+			{Name: "DEEPL_BASE_URL", Type: SettingURL, Description: "base URL, picked from the key by default"},
+		},
+		NewTranslator: func(s Settings) (MachineTranslator, error) {
+			return NewDeepLTranslator(DeepLConfig{
+				APIKey:  s.String("DEEPL_API_KEY"),
+				BaseURL: s.String("DEEPL_BASE_URL"),
+			}), nil
+		},
+	})
+
+	RegisterEngine(Engine{
+		Name:        "libretranslate",
+		Description: "LibreTranslate machine translation, improvements need a POST_EDITOR",
+		Settings: []Setting{
+			{Name: "LIBRETRANSLATE_URL", Type: SettingURL, Default: "http://localhost:5000", Description: "URL of the server"},
+			{Name: "LIBRETRANSLATE_API_KEY", Description: "API key, if the server requires one"},
+		},
+		NewTranslator: func(s Settings) (MachineTranslator, error) {
+			return NewLibreTranslateTranslator(LibreTranslateConfig{
+				BaseURL: s.String("LIBRETRANSLATE_URL"),
+				APIKey:  s.String("LIBRETRANSLATE_API_KEY"),
+			}), nil
+		},
+	})
+}
+
+func newOpenAIEngine(_ context.Context, s Settings) (AISystem, error) {
+	endpoint, err := endpointConfig("OPENAI", s)
+	if err != nil {
+		return nil, err
+	}
+	options, err := generationConfig("OPENAI", s)
+	if err != nil {
+		return nil, err
+	}
+	config := OpenAIConfig{
+		BaseURL:       s.String("OPENAI_BASE_URL"),
+		APIKey:        s.String("OPENAI_API_KEY"),
+		Model:         s.String("OPENAI_MODEL"),
+		Azure:         s.Bool("OPENAI_AZURE"),
+		APIVersion:    s.String("OPENAI_API_VERSION"),
+		ContextLength: s.Int("OPENAI_CONTEXT_LENGTH"),
+		Options:       options,
+		Endpoint:      endpoint,
+	}
+	if config.APIKey == "" {
+		slog.Warn("OPENAI_API_KEY not set, using without authentication")
+	}
+
+	hosts := s.String("OPENAI_HOSTS")
+	if hosts == "" {
+		if config.BaseURL == "" {
+			config.BaseURL = fmt.Sprintf("http://%s/v1", net.JoinHostPort(s.String("OPENAI_HOST"), strconv.Itoa(s.Int("OPENAI_PORT"))))
+		}
+		system, err := NewOpenAIBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewRetryAISystem("openai", system, RetryConfig{}), nil
+	}
+
+	// several equivalent hosts, e.g. OPENAI_HOSTS=gpu1:11434,https://gpu2.example.com/v1
+	poolConfig := PoolConfig{
+		Policy:   PoolPolicy(s.String("OPENAI_POOL_POLICY")),
+		Affinity: s.Bool("OPENAI_POOL_AFFINITY"),
+	}
+	var endpoints []PoolEndpoint
+	for _, entry := range strings.Split(hosts, ",") {
+		entry = strings.TrimSpace(entry)
+		hostConfig := config
+		if strings.Contains(entry, "://") {
+			hostConfig.BaseURL = entry
+		} else {
+			if _, _, err := net.SplitHostPort(entry); err != nil {
+				return nil, fmt.Errorf("invalid OPENAI_HOSTS entry %q: %w", entry, err)
+			}
+			hostConfig.BaseURL = fmt.Sprintf("http://%s/v1", entry)
+		}
+		system, err := NewOpenAIBackend(hostConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPENAI_HOSTS entry %q: %w", entry, err)
+		}
+		endpoints = append(endpoints, PoolEndpoint{Name: entry, Backend: system})
+	}
+	slog.Info("Using pool of OpenAI compatible hosts", "hosts", len(endpoints), "policy", poolConfig.Policy, "affinity", poolConfig.Affinity)
+	return NewRetryAISystem("openai", NewPoolAISystem(poolConfig, endpoints...), RetryConfig{}), nil
+}
+
+func newOllamaEngine(ctx context.Context, s Settings) (AISystem, error) {
+	config := OllamaConfig{
+		BaseURL:   s.String("OLLAMA_BASE_URL"),
+		Model:     s.String("OLLAMA_MODEL"),
+		KeepAlive: s.String("OLLAMA_KEEP_ALIVE"),
+		NumCtx:    s.Int("OLLAMA_NUM_CTX"),
+	}
+	if err := s.JSON("OLLAMA_OPTIONS", &config.Options); err != nil {
+		return nil, fmt.Errorf("invalid OLLAMA_OPTIONS, must be a JSON object: %w", err)
+	}
+	generation, err := generationConfig("OLLAMA", s)
+	if err != nil {
+		return nil, err
+	}
+	config.Generation = generation
+	system := NewOllamaBackend(config)
+	// make sure the model is there and loaded before serving, a cold load can take half a minute and a pull far
+	// longer, so a stalled server must not block startup or a reload forever
+	ctx, cancel := context.WithTimeout(ctx, s.Duration("OLLAMA_PULL_TIMEOUT"))
+	defer cancel()
+	if err := system.EnsureModel(ctx, s.Bool("OLLAMA_PULL")); err != nil {
+		return nil, err
+	}
+	if s.Bool("OLLAMA_WARMUP") {
+		if err := system.WarmUp(ctx); err != nil {
+			return nil, fmt.Errorf("warming up ollama: %w", err)
+		}
+	}
+	return NewRetryAISystem("ollama", system, RetryConfig{}), nil
+}
+
+// generationSettings are the default generation options of an engine accepting params, named with the engine's
+// prefix.
+func generationSettings(prefix string, params ...string) []Setting {
+	all := map[string]Setting{
+		ParamTemperature: {Name: prefix + "_TEMPERATURE", Type: SettingFloat, Description: "sampling temperature, provider default when empty"},
+		ParamTopP:        {Name: prefix + "_TOP_P", Type: SettingFloat, Description: "nucleus sampling probability mass"},
+		ParamMaxTokens:   {Name: prefix + "_MAX_TOKENS", Type: SettingInt, Description: "limit for the length of a completion in tokens"},
+		ParamSeed:        {Name: prefix + "_SEED", Type: SettingInt, Description: "seed for reproducible sampling, where the provider supports it"},
+		ParamStop:        {Name: prefix + "_STOP", Type: SettingJSON, Description: `stop sequences as a JSON array, e.g. ["\n\n"]`},
+	}
+	settings := make([]Setting, 0, len(params))
+	for _, param := range params {
+		settings = append(settings, all[param])
+	}
+	return settings
+}
+
+// generationConfig builds the GenerationOptions described by generationSettings. Unset settings stay unset, so
+// that the provider's default applies.
+func generationConfig(prefix string, s Settings) (GenerationOptions, error) {
+	var options GenerationOptions
+	if s.String(prefix+"_TEMPERATURE") != "" {
+		v := s.Float(prefix + "_TEMPERATURE")
+		options.Temperature = &v
+	}
+	if s.String(prefix+"_TOP_P") != "" {
+		v := s.Float(prefix + "_TOP_P")
+		options.TopP = &v
+	}
+	if s.String(prefix+"_MAX_TOKENS") != "" {
+		v := s.Int(prefix + "_MAX_TOKENS")
+		options.MaxTokens = &v
+	}
+	if s.String(prefix+"_SEED") != "" {
+		v := s.Int(prefix + "_SEED")
+		options.Seed = &v
+	}
+	if err := s.JSON(prefix+"_STOP", &options.Stop); err != nil {
+		return options, fmt.Errorf("invalid %s_STOP, must be a JSON array of strings: %w", prefix, err)
+	}
+	return options, nil
+}
+
+// endpointSettings are the connection settings shared by HTTP engines, named with the engine's prefix.
+func endpointSettings(prefix string) []Setting {
+	return []Setting{
+		{Name: prefix + "_HEADERS", Description: "extra headers as comma separated name=value pairs"},
+		{Name: prefix + "_TIMEOUT", Type: SettingDuration, Description: "limit for a whole request"},
+		{Name: prefix + "_CONNECT_TIMEOUT", Type: SettingDuration, Default: "30s", Description: "limit for connecting"},
+		{Name: prefix + "_PROXY", Type: SettingURL, Description: "proxy URL, otherwise HTTPS_PROXY applies"},
+		{Name: prefix + "_CA_FILE", Description: "PEM file of additional certificate authorities"},
+		{Name: prefix + "_CLIENT_CERT", Description: "client certificate for mutual TLS"},
+		{Name: prefix + "_CLIENT_KEY", Description: "key of the client certificate"},
+		{Name: prefix + "_TLS_INSECURE", Type: SettingBool, Default: "false", Description: "skip certificate verification, development only"},
+	}
+}
+
+// endpointConfig builds the EndpointConfig described by endpointSettings.
+func endpointConfig(prefix string, s Settings) (EndpointConfig, error) {
+	config := EndpointConfig{
+		Timeout:            s.Duration(prefix + "_TIMEOUT"),
+		ConnectTimeout:     s.Duration(prefix + "_CONNECT_TIMEOUT"),
+		ProxyURL:           s.String(prefix + "_PROXY"),
+		CAFile:             s.String(prefix + "_CA_FILE"),
+		CertFile:           s.String(prefix + "_CLIENT_CERT"),
+		KeyFile:            s.String(prefix + "_CLIENT_KEY"),
+		InsecureSkipVerify: s.Bool(prefix + "_TLS_INSECURE"),
+	}
+	if raw := s.String(prefix + "_HEADERS"); raw != "" {
+		config.Headers = http.Header{}
+		for _, pair := range strings.Split(raw, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return config, fmt.Errorf("invalid %s_HEADERS entry %q, must be name=value", prefix, pair)
+			}
+			config.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	if config.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification disabled", "engine", strings.ToLower(prefix))
+	}
+	return config, nil
+}
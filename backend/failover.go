@@ -0,0 +1,250 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrNoBackends is returned by FailoverAISystem when it has no members to try.
+var ErrNoBackends = errors.New("no backends configured")
+
+// FailoverMember is a named backend taking part in a FailoverAISystem.
+type FailoverMember struct {
+	Name    string
+	Backend AISystem
+}
+
+// FailoverConfig controls how FailoverAISystem tracks the health of its members.
+type FailoverConfig struct {
+	// AttemptTimeout bounds each attempt on a single member. Zero leaves attempts bounded only by the caller's context.
+	AttemptTimeout time.Duration
+	// FailureThreshold is the number of consecutive failures after which a member is skipped, default 1
+	FailureThreshold int
+	// Cooldown is how long an unhealthy member is skipped before it is tried again, default 30s
+	Cooldown time.Duration
+}
+
+// FailoverStatus is a snapshot of the health of a single member.
+type FailoverStatus struct {
+	Name           string    `json:"name"`
+	Healthy        bool      `json:"healthy"`
+	Failures       int       `json:"failures"`
+	UnhealthyUntil time.Time `json:"unhealthyUntil,omitzero"`
+	Served         int64     `json:"served"`
+	LastError      string    `json:"lastError,omitempty"`
+}
+
+type failoverMember struct {
+	FailoverMember
+
+	mu             sync.Mutex
+	failures       int
+	unhealthyUntil time.Time
+	served         int64
+	lastErr        error
+}
+
+// FailoverAISystem tries an ordered list of backends, moving on to the next one when a backend is unavailable, fails
+// with a server error or times out. Client errors are returned as they are, without counting against the backend.
+// Members that keep failing are skipped until their cooldown has passed. Because translation contexts keep their
+// whole history, a context started on one member continues seamlessly on another.
+type FailoverAISystem struct {
+	members []*failoverMember
+	config  FailoverConfig
+}
+
+// NewFailoverAISystem builds a failover chain trying members in the given order.
+func NewFailoverAISystem(config FailoverConfig, members ...FailoverMember) *FailoverAISystem {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 1
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+
+	f := &FailoverAISystem{config: config}
+	for _, m := range members {
+		f.members = append(f.members, &failoverMember{FailoverMember: m})
+	}
+	return f
+}
+
+func (f *FailoverAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return f.chat(ctx, messages, nil)
+}
+
+// ChatStream streams from the first member that answers. Once text was delivered there is no failing over.
+func (f *FailoverAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return f.chat(ctx, messages, onDelta)
+}
+
+// Capabilities is what every member supports, as any of them may end up serving a call.
+func (f *FailoverAISystem) Capabilities() Capabilities {
+	systems := make([]AISystem, 0, len(f.members))
+	for _, m := range f.members {
+		systems = append(systems, m.Backend)
+	}
+	return capabilitiesOf(systems...)
+}
+
+// Close closes every member.
+func (f *FailoverAISystem) Close() error {
+	systems := make([]AISystem, 0, len(f.members))
+	for _, m := range f.members {
+		systems = append(systems, m.Backend)
+	}
+	return closeAll(systems...)
+}
+
+// HealthCheck succeeds as soon as one member answers its probe, in order.
+func (f *FailoverAISystem) HealthCheck(ctx context.Context) error {
+	if len(f.members) == 0 {
+		return ErrNoBackends
+	}
+	var errs []error
+	for _, m := range f.members {
+		err := healthCheck(ctx, m.Backend)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FailoverAISystem) chat(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	if len(f.members) == 0 {
+		return "", ErrNoBackends
+	}
+
+	// healthy members go first in configured order; if none are healthy, try the unhealthy ones rather than fail
+	now := time.Now()
+	var candidates, cooling []*failoverMember
+	for _, m := range f.members {
+		if m.healthy(now) {
+			candidates = append(candidates, m)
+		} else {
+			cooling = append(cooling, m)
+		}
+	}
+	candidates = append(candidates, cooling...)
+
+	var errs []error
+	for i, m := range candidates {
+		var started bool
+		result, err := f.attempt(ctx, m, messages, trackDelta(onDelta, &started))
+		if err == nil {
+			m.recordSuccess()
+			if info := callInfoFromContext(ctx); info != nil {
+				info.Backend = m.Name
+			}
+			if i > 0 {
+				slog.Info("request served by fallback backend", "backend", m.Name, "skipped", i)
+			} else {
+				slog.Debug("request served", "backend", m.Name)
+			}
+			return result, nil
+		}
+
+		// the caller gave up, no point trying anyone else
+		if ctx.Err() != nil {
+			return "", err
+		}
+		// a bad request, an exceeded context length or a blocked prompt would fail on every member, and says
+		// nothing about the health of this one
+		if !failsOver(err) {
+			return "", fmt.Errorf("%s: %w", m.Name, err)
+		}
+		if started {
+			m.recordFailure(err, f.config)
+			return "", fmt.Errorf("%s: %w", m.Name, err)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+		if m.recordFailure(err, f.config) {
+			slog.Warn("backend marked unhealthy", "backend", m.Name, "cooldown", f.config.Cooldown, "error", err)
+		} else {
+			slog.Warn("backend failed, failing over", "backend", m.Name, "error", err)
+		}
+	}
+
+	return "", fmt.Errorf("all backends failed: %w", errors.Join(errs...))
+}
+
+// failsOver reports whether err is the member's fault, so that another member may do better: the errors
+// RetryAISystem retries, and a member whose circuit breaker is open.
+func failsOver(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	retryable, _ := classifyError(err)
+	return retryable
+}
+
+func (f *FailoverAISystem) attempt(ctx context.Context, m *failoverMember, messages []Message, onDelta func(string) error) (string, error) {
+	if f.config.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.config.AttemptTimeout)
+		defer cancel()
+	}
+	return chatStream(ctx, m.Backend, messages, onDelta)
+}
+
+// Status returns the health of every member in configured order.
+func (f *FailoverAISystem) Status() []FailoverStatus {
+	now := time.Now()
+	statuses := make([]FailoverStatus, 0, len(f.members))
+	for _, m := range f.members {
+		m.mu.Lock()
+		s := FailoverStatus{
+			Name:     m.Name,
+			Healthy:  now.After(m.unhealthyUntil),
+			Failures: m.failures,
+			Served:   m.served,
+		}
+		if !s.Healthy {
+			s.UnhealthyUntil = m.unhealthyUntil
+		}
+		if m.lastErr != nil {
+			s.LastError = m.lastErr.Error()
+		}
+		m.mu.Unlock()
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+func (m *failoverMember) healthy(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return now.After(m.unhealthyUntil)
+}
+
+func (m *failoverMember) recordSuccess() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures > 0 {
+		slog.Info("backend healthy again", "backend", m.Name)
+	}
+	m.failures = 0
+	m.unhealthyUntil = time.Time{}
+	m.lastErr = nil
+	m.served++
+}
+
+// recordFailure counts a failure and reports whether it made the member unhealthy.
+func (m *failoverMember) recordFailure(err error, config FailoverConfig) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+	m.lastErr = err
+	if m.failures >= config.FailureThreshold {
+		m.unhealthyUntil = time.Now().Add(config.Cooldown)
+		return true
+	}
+	return false
+}
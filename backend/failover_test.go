@@ -0,0 +1,189 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+// flakyAISystem delegates to the mock until it is taken down.
+type flakyAISystem struct {
+	mock  *babel.MockAISystem
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func (f *flakyAISystem) Capabilities() babel.Capabilities { return babel.Capabilities{} }
+
+func (f *flakyAISystem) Chat(ctx context.Context, messages []babel.Message) (string, error) {
+	f.calls.Add(1)
+	if f.down.Load() {
+		return "", syscall.ECONNREFUSED
+	}
+	return f.mock.Chat(ctx, messages)
+}
+
+func (f *flakyAISystem) HealthCheck(ctx context.Context) error {
+	if f.down.Load() {
+		return syscall.ECONNREFUSED
+	}
+	return nil
+}
+
+// slowAISystem blocks until the context is done.
+type slowAISystem struct{}
+
+func (slowAISystem) Capabilities() babel.Capabilities { return babel.Capabilities{} }
+
+func (slowAISystem) Chat(ctx context.Context, messages []babel.Message) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestFailoverUsesPrimaryWhenHealthy(t *testing.T) {
+	primary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	secondary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	f := babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "primary", Backend: primary},
+		babel.FailoverMember{Name: "secondary", Backend: secondary},
+	)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	_, result, err := babel.NewBabel(f).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, "primary", info.Backend)
+	require.EqualValues(t, 0, secondary.calls.Load())
+}
+
+func TestFailoverContinuesContextOnSecondary(t *testing.T) {
+	primary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	secondary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	f := babel.NewFailoverAISystem(babel.FailoverConfig{Cooldown: time.Minute},
+		babel.FailoverMember{Name: "primary", Backend: primary},
+		babel.FailoverMember{Name: "secondary", Backend: secondary},
+	)
+
+	translationContext, _, err := babel.NewBabel(f).NewTranslation(context.Background(), "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+
+	primary.down.Store(true)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	result, err := translationContext.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me encanta la pizza.", result)
+	require.Equal(t, "secondary", info.Backend)
+
+	status := f.Status()
+	require.False(t, status[0].Healthy)
+	require.True(t, status[1].Healthy)
+
+	// the unhealthy primary is skipped during its cooldown
+	calls := primary.calls.Load()
+	_, err = translationContext.Improve(context.Background(), "Add details")
+	require.NoError(t, err)
+	require.Equal(t, calls, primary.calls.Load())
+}
+
+func TestFailoverOnTimeout(t *testing.T) {
+	secondary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	f := babel.NewFailoverAISystem(babel.FailoverConfig{AttemptTimeout: 20 * time.Millisecond},
+		babel.FailoverMember{Name: "slow", Backend: slowAISystem{}},
+		babel.FailoverMember{Name: "secondary", Backend: secondary},
+	)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	_, result, err := babel.NewBabel(f).NewTranslation(ctx, "Hello. I like pizza.", language.German)
+	require.NoError(t, err)
+	require.Equal(t, "Hallo. Ich mag Pizza.", result)
+	require.Equal(t, "secondary", info.Backend)
+}
+
+func TestFailoverAllBackendsFail(t *testing.T) {
+	primary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	primary.down.Store(true)
+	secondary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	secondary.down.Store(true)
+	f := babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "primary", Backend: primary},
+		babel.FailoverMember{Name: "secondary", Backend: secondary},
+	)
+
+	_, _, err := babel.NewBabel(f).NewTranslation(context.Background(), "Hello", language.Spanish)
+	require.ErrorContains(t, err, "all backends failed")
+
+	// with everyone cooling down, members are still tried rather than failing outright
+	primary.down.Store(false)
+	_, _, err = babel.NewBabel(f).NewTranslation(context.Background(), "Hello", language.Spanish)
+	require.NoError(t, err)
+}
+
+func TestHealthCheckThroughDecorators(t *testing.T) {
+	a := &flakyAISystem{mock: babel.NewMockAISystem()}
+	b := &flakyAISystem{mock: babel.NewMockAISystem()}
+	chaos, err := babel.NewChaos(babel.ChaosConfig{ErrorRate: 1})
+	require.NoError(t, err)
+	failover := babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "a", Backend: babel.NewRetryAISystem("a", a, babel.RetryConfig{})},
+		babel.FailoverMember{Name: "b", Backend: chaos.Wrap("b", b)},
+	)
+	backend := babel.NewBabel(failover)
+
+	require.NoError(t, backend.HealthCheck(context.Background()))
+	a.down.Store(true)
+	require.NoError(t, backend.HealthCheck(context.Background()), "one healthy member is enough, probes are not disturbed by chaos")
+	b.down.Store(true)
+	err = backend.HealthCheck(context.Background())
+	require.ErrorContains(t, err, "a: connection refused")
+	require.ErrorContains(t, err, "b: connection refused")
+	require.Zero(t, a.calls.Load()+b.calls.Load(), "health checkers are used instead of completions")
+}
+
+// chatOnlyAISystem counts its completions and has no health check of its own.
+type chatOnlyAISystem struct {
+	calls atomic.Int32
+}
+
+func (c *chatOnlyAISystem) Capabilities() babel.Capabilities { return babel.Capabilities{} }
+
+func (c *chatOnlyAISystem) Chat(context.Context, []babel.Message) (string, error) {
+	c.calls.Add(1)
+	return "OK", nil
+}
+
+func TestHealthCheckNeverGenerates(t *testing.T) {
+	system := &chatOnlyAISystem{}
+	backend := babel.NewBabel(babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "a", Backend: babel.NewRetryAISystem("a", system, babel.RetryConfig{})},
+	))
+	require.NoError(t, backend.HealthCheck(context.Background()), "backends without a health check are taken as healthy")
+	require.Zero(t, system.calls.Load())
+}
+
+func TestFailoverReturnsClientErrors(t *testing.T) {
+	badRequest := &babel.APIError{Provider: "openai", StatusCode: http.StatusBadRequest, Message: "context length exceeded"}
+	primary := &scriptedAISystem{errs: []error{badRequest}}
+	secondary := &flakyAISystem{mock: babel.NewMockAISystem()}
+	failover := babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "primary", Backend: primary},
+		babel.FailoverMember{Name: "secondary", Backend: secondary},
+	)
+
+	_, err := failover.Chat(context.Background(), []babel.Message{babel.UserMessage("hello")})
+	require.ErrorIs(t, err, badRequest)
+	require.Zero(t, secondary.calls.Load(), "the request would fail on any backend")
+	require.True(t, failover.Status()[0].Healthy, "a bad request does not eject the primary")
+
+	result, err := failover.Chat(context.Background(), []babel.Message{babel.UserMessage("hello")})
+	require.NoError(t, err)
+	require.Equal(t, "ok", result)
+	require.Equal(t, 2, primary.callCount())
+}
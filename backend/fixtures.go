@@ -0,0 +1,346 @@
+package babel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoScenario is returned by FixtureAISystem for a call none of its scenarios matches.
+var ErrNoScenario = errors.New("no fixture scenario matches the call")
+
+// Fixtures script the answers of FixtureAISystem. They are loaded from JSON, or YAML for files ending in .yaml or
+// .yml, using the JSON field names in both.
+type Fixtures struct {
+	// Capabilities reported by the mock, those of MockAISystem when omitted
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// Scenarios are tried in order, the first one matching a call answers it
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// Scenario answers the calls it matches with a response or an error, after a latency. Empty match fields match
+// any call, a scenario without any is a catch-all.
+type Scenario struct {
+	// Name identifies the scenario in errors and logs
+	Name string `json:"name,omitempty"`
+
+	// Operation the call is made for, as tagged on the messages by Backend
+	Operation Operation `json:"operation,omitempty"`
+	// Language is the BCP 47 tag of the target language. A tag without region, e.g. "ja", matches every region.
+	Language string `json:"language,omitempty"`
+	// Input is a regular expression matched against the text being translated or identified
+	Input string `json:"input,omitempty"`
+	// Turn counts the user messages of the conversation: 1 for the translation, 2 for the first improvement and so on
+	Turn int `json:"turn,omitempty"`
+	// Times limits how many calls the scenario answers, unlimited when zero. Later scenarios answer the calls after,
+	// e.g. to fail once and then succeed.
+	Times int `json:"times,omitempty"`
+
+	// Response is the completion returned
+	Response string `json:"response,omitempty"`
+	// Error fails the call with this message instead
+	Error string `json:"error,omitempty"`
+	// Status turns the error into an APIError with this HTTP status, so that retries and failover treat it like a
+	// provider's error
+	Status int `json:"status,omitempty"`
+	// Latency delays the answer
+	Latency Latency `json:"latency,omitempty"`
+}
+
+// Latency is a distribution of delays. With Mean set delays are normally distributed around it with StdDev,
+// otherwise uniformly distributed between Min and Max, or exactly Min without Max. Min and Max also bound the
+// normal distribution.
+type Latency struct {
+	Min    time.Duration `json:"min,omitempty"`
+	Max    time.Duration `json:"max,omitempty"`
+	Mean   time.Duration `json:"mean,omitempty"`
+	StdDev time.Duration `json:"stdDev,omitempty"`
+}
+
+// latencyJSON is Latency with its durations written like "250ms" or "1.5s".
+type latencyJSON struct {
+	Min    string `json:"min,omitempty"`
+	Max    string `json:"max,omitempty"`
+	Mean   string `json:"mean,omitempty"`
+	StdDev string `json:"stdDev,omitempty"`
+}
+
+func (l *Latency) UnmarshalJSON(data []byte) error {
+	var durations latencyJSON
+	if err := json.Unmarshal(data, &durations); err != nil {
+		return err
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		out   *time.Duration
+	}{
+		{"min", durations.Min, &l.Min},
+		{"max", durations.Max, &l.Max},
+		{"mean", durations.Mean, &l.Mean},
+		{"stdDev", durations.StdDev, &l.StdDev},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("latency %s: %w", d.name, err)
+		}
+		*d.out = parsed
+	}
+	return nil
+}
+
+func (l Latency) MarshalJSON() ([]byte, error) {
+	format := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	return json.Marshal(latencyJSON{Min: format(l.Min), Max: format(l.Max), Mean: format(l.Mean), StdDev: format(l.StdDev)})
+}
+
+// validate reports negative durations.
+func (l Latency) validate() error {
+	if l.Min < 0 || l.Max < 0 || l.Mean < 0 || l.StdDev < 0 {
+		return errors.New("latency must not be negative")
+	}
+	return nil
+}
+
+// sample draws a delay from the distribution.
+func (l Latency) sample() time.Duration {
+	lower, upper := l.Min, l.Max
+	var d time.Duration
+	switch {
+	case l.Mean > 0:
+		d = time.Duration(float64(l.Mean) + rand.NormFloat64()*float64(l.StdDev))
+	case upper > lower:
+		d = lower + time.Duration(rand.Int64N(int64(upper-lower)+1))
+	default:
+		d = lower
+	}
+	if d < lower {
+		d = lower
+	}
+	if upper > 0 && d > upper {
+		d = upper
+	}
+	return d
+}
+
+// LoadFixtures reads a fixture file, NewFixtureAISystem validates the scenarios.
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// YAML goes through JSON so that both formats share the field names and the duration parsing
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+		}
+	}
+
+	var fixtures Fixtures
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %s: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+// FixtureAISystem is a mock answering from scripted scenarios, so that new end-to-end scenarios need a fixture file
+// instead of a Go change.
+type FixtureAISystem struct {
+	capabilities Capabilities
+	scenarios    []fixtureScenario
+
+	mu sync.Mutex
+}
+
+type fixtureScenario struct {
+	Scenario
+	language language.Tag
+	input    *regexp.Regexp
+	calls    int
+}
+
+// NewFixtureAISystem validates the scenarios and compiles their patterns.
+func NewFixtureAISystem(fixtures *Fixtures) (*FixtureAISystem, error) {
+	f := &FixtureAISystem{capabilities: NewMockAISystem().Capabilities()}
+	if fixtures.Capabilities != nil {
+		f.capabilities = *fixtures.Capabilities
+	}
+
+	var errs []error
+	for i, s := range fixtures.Scenarios {
+		scenario := fixtureScenario{Scenario: s}
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if s.Operation != "" && !slices.Contains(Operations, s.Operation) {
+			errs = append(errs, fmt.Errorf("scenario %s: unknown operation %q", name, s.Operation))
+		}
+		if s.Language != "" {
+			tag, err := language.Parse(s.Language)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("scenario %s: language: %w", name, err))
+			}
+			scenario.language = tag
+		}
+		if s.Input != "" {
+			input, err := regexp.Compile(s.Input)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("scenario %s: input: %w", name, err))
+			}
+			scenario.input = input
+		}
+		if s.Turn < 0 || s.Times < 0 {
+			errs = append(errs, fmt.Errorf("scenario %s: turn and times must not be negative", name))
+		}
+		if s.Status != 0 && s.Error == "" {
+			errs = append(errs, fmt.Errorf("scenario %s: status needs an error", name))
+		}
+		if err := s.Latency.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("scenario %s: %w", name, err))
+		}
+		scenario.Name = name
+		f.scenarios = append(f.scenarios, scenario)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return f, nil
+}
+
+// NewFixtureAISystemFromFile loads the scenarios of a fixture file.
+func NewFixtureAISystemFromFile(path string) (*FixtureAISystem, error) {
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFixtureAISystem(fixtures)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixtures %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f *FixtureAISystem) Capabilities() Capabilities {
+	return f.capabilities
+}
+
+// HealthCheck always succeeds without matching a scenario, so that probes do not use up their times.
+func (f *FixtureAISystem) HealthCheck(context.Context) error {
+	return nil
+}
+
+func (f *FixtureAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return f.ChatStream(ctx, messages, nil)
+}
+
+// ChatStream delivers the response word by word.
+func (f *FixtureAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	scenario, err := f.match(messages)
+	if err != nil {
+		return "", err
+	}
+
+	if err := sleep(ctx, scenario.Latency.sample()); err != nil {
+		return "", err
+	}
+
+	if scenario.Error != "" {
+		if scenario.Status != 0 {
+			return "", &APIError{Provider: "mock", StatusCode: scenario.Status, Message: scenario.Error}
+		}
+		return "", errors.New(scenario.Error)
+	}
+	if onDelta != nil {
+		for _, word := range strings.SplitAfter(scenario.Response, " ") {
+			if err := onDelta(word); err != nil {
+				return "", err
+			}
+		}
+	}
+	return scenario.Response, nil
+}
+
+// match picks the first scenario matching the call and counts the call against it.
+func (f *FixtureAISystem) match(messages []Message) (Scenario, error) {
+	var input, last Message
+	turn := 0
+	for _, m := range messages {
+		if m.Role != RoleUser {
+			continue
+		}
+		if turn == 0 {
+			input = m
+		}
+		last = m
+		turn++
+	}
+	op := Operation(last.Metadata[MetadataOperation])
+	target, _ := language.Parse(last.Metadata[MetadataTargetLanguage])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.scenarios {
+		s := &f.scenarios[i]
+		if s.Times > 0 && s.calls >= s.Times {
+			continue
+		}
+		if s.Operation != "" && s.Operation != op {
+			continue
+		}
+		if s.Language != "" && !matchesLanguage(s.language, target) {
+			continue
+		}
+		if s.input != nil && !s.input.MatchString(input.Text()) {
+			continue
+		}
+		if s.Turn != 0 && s.Turn != turn {
+			continue
+		}
+		s.calls++
+		return s.Scenario, nil
+	}
+	return Scenario{}, fmt.Errorf("%w: operation %q, language %q, turn %d, input %q", ErrNoScenario,
+		op, last.Metadata[MetadataTargetLanguage], turn, truncateString(input.Text(), 50))
+}
+
+// matchesLanguage reports whether target is want, or has the language of want when want has no region.
+func matchesLanguage(want, target language.Tag) bool {
+	if want == target {
+		return true
+	}
+	if _, confidence := want.Region(); confidence == language.Exact {
+		return false
+	}
+	wantBase, _ := want.Base()
+	targetBase, _ := target.Base()
+	return wantBase == targetBase
+}
@@ -0,0 +1,115 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestFixtureAISystem(t *testing.T) {
+	system, err := babel.NewFixtureAISystemFromFile(filepath.Join("testdata", "fixtures", "pizza.yaml"))
+	require.NoError(t, err)
+	b := babel.NewBabel(system)
+	ctx := context.Background()
+
+	identified, err := b.IdentifyLanguage(ctx, "Hola. Me gusta la pizza.")
+	require.NoError(t, err)
+	assert.Equal(t, language.MustParse("es-ES"), identified)
+	identified, err = b.IdentifyLanguage(ctx, "Hello.")
+	require.NoError(t, err)
+	assert.Equal(t, language.MustParse("en-US"), identified)
+
+	translationContext, result, err := b.NewTranslation(ctx, "Hello. I like pizza.", language.German)
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo. Ich mag Pizza.", result)
+	result, err = translationContext.Improve(ctx, "Make it more formal")
+	require.NoError(t, err)
+	assert.Equal(t, "Hallo. Ich liebe Pizza.", result)
+
+	// a regional target matches a scenario for the language
+	_, result, err = b.NewTranslation(ctx, "Hello. I like pizza.", language.MustParse("ja-JP"))
+	require.NoError(t, err)
+	assert.Equal(t, "こにちは。ピザがすきです。", result)
+
+	// the overloaded scenario fails only once, the next call falls through to the later scenarios
+	_, _, err = b.NewTranslation(ctx, "The model is overloaded", language.Japanese)
+	var apiErr *babel.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 503, apiErr.StatusCode)
+	_, result, err = b.NewTranslation(ctx, "The model is overloaded", language.Japanese)
+	require.NoError(t, err)
+	assert.Equal(t, "こにちは。ピザがすきです。", result)
+
+	_, _, err = b.NewTranslation(ctx, "Hello.", language.French)
+	require.ErrorIs(t, err, babel.ErrNoScenario)
+}
+
+func TestFixtureAISystemFromJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"capabilities": {"streaming": true, "contextLength": 4096},
+		"scenarios": [
+			{"operation": "preview", "response": "Vorschau", "latency": {"mean": "20ms", "stdDev": "5ms", "min": "10ms", "max": "30ms"}},
+			{"error": "no translation for you"}
+		]
+	}`), 0o644))
+	system, err := babel.NewFixtureAISystemFromFile(path)
+	require.NoError(t, err)
+	assert.True(t, system.Capabilities().Streaming)
+	assert.Equal(t, 4096, system.Capabilities().ContextLength)
+	b := babel.NewBabel(system)
+
+	started := time.Now()
+	var deltas []string
+	result, err := b.PreviewStream(context.Background(), "Hello", language.English, language.German, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Vorschau", result)
+	assert.Equal(t, []string{"Vorschau"}, deltas)
+	assert.GreaterOrEqual(t, time.Since(started), 10*time.Millisecond)
+
+	_, _, err = b.NewTranslation(context.Background(), "Hello", language.German)
+	require.EqualError(t, err, "no translation for you")
+}
+
+func TestFixturesAreValidated(t *testing.T) {
+	_, err := babel.NewFixtureAISystem(&babel.Fixtures{Scenarios: []babel.Scenario{
+		{Name: "bad operation", Operation: "summarize"},
+		{Name: "bad language", Language: "not a language"},
+		{Name: "bad input", Input: "("},
+		{Name: "status without error", Status: 500},
+	}})
+	require.Error(t, err)
+	for _, name := range []string{"bad operation", "bad language", "bad input", "status without error"} {
+		assert.ErrorContains(t, err, name)
+	}
+
+	path := filepath.Join(t.TempDir(), "fixtures.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("scenarios:\n  - respnse: typo\n"), 0o644))
+	_, err = babel.NewFixtureAISystemFromFile(path)
+	assert.ErrorContains(t, err, "respnse")
+
+	_, err = babel.NewFixtureAISystemFromFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestFixtureLatencyHonoursCancellation(t *testing.T) {
+	system, err := babel.NewFixtureAISystem(&babel.Fixtures{Scenarios: []babel.Scenario{
+		{Response: "slow", Latency: babel.Latency{Min: time.Minute}},
+	}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = system.Chat(ctx, []babel.Message{babel.UserMessage("Hello")})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,276 @@
+package babel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrContentBlocked matches any GeminiBlockedError with errors.Is.
+var ErrContentBlocked = errors.New("content blocked by provider")
+
+// GeminiSafetyRating is the probability Gemini assigned to a harm category.
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// GeminiSafetySetting overrides the blocking threshold of a harm category,
+// e.g. {Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"}.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// GeminiBlockedError is returned when Gemini refuses to answer: the prompt was blocked, the candidate was stopped
+// by a safety or recitation filter, or no text was produced at all.
+type GeminiBlockedError struct {
+	// PromptBlocked is true when the prompt itself was rejected before generation
+	PromptBlocked bool
+	// Reason is the blockReason of the prompt or the finishReason of the candidate, e.g. SAFETY or RECITATION
+	Reason        string
+	SafetyRatings []GeminiSafetyRating
+}
+
+func (e *GeminiBlockedError) Error() string {
+	if e.PromptBlocked {
+		return fmt.Sprintf("gemini: prompt blocked: %s", e.Reason)
+	}
+	return fmt.Sprintf("gemini: response blocked: %s", e.Reason)
+}
+
+func (e *GeminiBlockedError) Is(target error) bool {
+	return target == ErrContentBlocked
+}
+
+// GeminiConfig configures the Gemini generateContent backend.
+type GeminiConfig struct {
+	APIKey string
+	// Model defaults to gemini-2.0-flash
+	Model string
+	// BaseURL defaults to https://generativelanguage.googleapis.com/v1beta
+	BaseURL string
+	// ContextLength is the model's context window in tokens, defaults to 1048576
+	ContextLength int
+	// SafetySettings are sent with every request to adjust Gemini's blocking thresholds
+	SafetySettings []GeminiSafetySetting
+	// Options are the default generation options, requests may override them
+	Options GenerationOptions
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// GeminiBackend talks to the Google Gemini generateContent REST API.
+type GeminiBackend struct {
+	config GeminiConfig
+}
+
+// NewGeminiBackend creates a Gemini backend, filling in defaults for unset configuration.
+func NewGeminiBackend(config GeminiConfig) *GeminiBackend {
+	if config.Model == "" {
+		config.Model = "gemini-2.0-flash"
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.ContextLength <= 0 {
+		config.ContextLength = 1 << 20
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &GeminiBackend{config: config}
+}
+
+func (g *GeminiBackend) Capabilities() Capabilities {
+	// Messages only carry text parts, so images are not offered even though the API takes them
+	return Capabilities{
+		StructuredOutput:   true,
+		ContextLength:      g.config.ContextLength,
+		SamplingParameters: AllSamplingParameters,
+	}
+}
+
+// ListModels returns the configured model, other models are only usable when allowed for requests.
+func (g *GeminiBackend) ListModels(context.Context) ([]ModelInfo, error) {
+	return []ModelInfo{{Name: g.config.Model, Capabilities: g.Capabilities()}}, nil
+}
+
+// HealthCheck looks up the configured model, which checks the key and the model without generating anything.
+func (g *GeminiBackend) HealthCheck(ctx context.Context) error {
+	endpoint := fmt.Sprintf("%s/models/%s", g.config.BaseURL, url.PathEscape(g.config.Model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", g.config.APIKey)
+	return doJSON(g.config.HTTPClient, req, "gemini", geminiErrorMessage, nil)
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// toGeminiGenerationConfig maps the sampling parameters of options, nil when none is set.
+func toGeminiGenerationConfig(options GenerationOptions) *geminiGenerationConfig {
+	if len(options.Parameters()) == 0 {
+		return nil
+	}
+	return &geminiGenerationConfig{
+		Temperature:     options.Temperature,
+		TopP:            options.TopP,
+		MaxOutputTokens: options.MaxTokens,
+		Seed:            options.Seed,
+		StopSequences:   options.Stop,
+	}
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content       geminiContent        `json:"content"`
+		FinishReason  string               `json:"finishReason"`
+		SafetyRatings []GeminiSafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason   string               `json:"blockReason"`
+		SafetyRatings []GeminiSafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func geminiErrorMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Error.Message
+}
+
+func toGeminiParts(parts []Part) []geminiPart {
+	result := make([]geminiPart, 0, len(parts))
+	for _, p := range parts {
+		result = append(result, geminiPart{Text: p.Text})
+	}
+	return result
+}
+
+// toGeminiContents maps the history to Gemini's system instruction and user/model contents.
+// Consecutive messages of the same role are merged into one content with several parts.
+func toGeminiContents(messages []Message) (*geminiContent, []geminiContent) {
+	var system *geminiContent
+	var contents []geminiContent
+	for _, m := range messages {
+		var role string
+		switch m.Role {
+		case RoleSystem:
+			if system == nil {
+				system = &geminiContent{}
+			}
+			system.Parts = append(system.Parts, toGeminiParts(m.Parts)...)
+			continue
+		case RoleAssistant:
+			role = "model"
+		case RoleUser:
+			role = "user"
+		default:
+			continue
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, toGeminiParts(m.Parts)...)
+			continue
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: toGeminiParts(m.Parts)})
+	}
+	return system, contents
+}
+
+func (g *GeminiBackend) Chat(ctx context.Context, messages []Message) (string, error) {
+	system, contents := toGeminiContents(messages)
+	options := generationOptions(ctx, g.config.Options)
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", g.config.BaseURL, url.PathEscape(options.modelOr(g.config.Model)))
+	req, err := newJSONRequest(ctx, endpoint, geminiRequest{
+		SystemInstruction: system,
+		Contents:          contents,
+		SafetySettings:    g.config.SafetySettings,
+		GenerationConfig:  toGeminiGenerationConfig(options),
+	})
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-goog-api-key", g.config.APIKey)
+
+	var res geminiResponse
+	if err := doJSON(g.config.HTTPClient, req, "gemini", geminiErrorMessage, &res); err != nil {
+		return "", err
+	}
+
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Usage = Usage{
+			PromptTokens:     res.UsageMetadata.PromptTokenCount,
+			CompletionTokens: res.UsageMetadata.CandidatesTokenCount,
+		}
+	}
+
+	if res.PromptFeedback != nil && res.PromptFeedback.BlockReason != "" {
+		return "", &GeminiBlockedError{
+			PromptBlocked: true,
+			Reason:        res.PromptFeedback.BlockReason,
+			SafetyRatings: res.PromptFeedback.SafetyRatings,
+		}
+	}
+	if len(res.Candidates) == 0 {
+		return "", &GeminiBlockedError{Reason: "NO_CANDIDATES"}
+	}
+
+	candidate := res.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	// a truncated answer is still an answer, anything else without text is a refusal
+	switch candidate.FinishReason {
+	case "STOP", "MAX_TOKENS", "":
+		if text.Len() > 0 {
+			return text.String(), nil
+		}
+	}
+	reason := candidate.FinishReason
+	if reason == "" || reason == "STOP" {
+		reason = "EMPTY_RESPONSE"
+	}
+	return "", &GeminiBlockedError{Reason: reason, SafetyRatings: candidate.SafetyRatings}
+}
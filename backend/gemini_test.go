@@ -0,0 +1,227 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type geminiRequest struct {
+	SystemInstruction *struct {
+		Parts []babel.Part `json:"parts"`
+	} `json:"systemInstruction"`
+	Contents []struct {
+		Role  string       `json:"role"`
+		Parts []babel.Part `json:"parts"`
+	} `json:"contents"`
+	SafetySettings   []babel.GeminiSafetySetting `json:"safetySettings"`
+	GenerationConfig map[string]any              `json:"generationConfig"`
+}
+
+// messages maps the request back to the history it was made from.
+func (r geminiRequest) messages() []babel.Message {
+	var messages []babel.Message
+	if r.SystemInstruction != nil {
+		messages = append(messages, babel.Message{Role: babel.RoleSystem, Parts: r.SystemInstruction.Parts})
+	}
+	for _, c := range r.Contents {
+		role := babel.RoleUser
+		if c.Role == "model" {
+			role = babel.RoleAssistant
+		}
+		messages = append(messages, babel.Message{Role: role, Parts: c.Parts})
+	}
+	return messages
+}
+
+// newFakeGemini serves generateContent with a fixed response body and records the requests it saw.
+func newFakeGemini(t *testing.T, response string, requests *[]geminiRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models/test-model:generateContent", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+
+		var req geminiRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestGemini(url string) *babel.GeminiBackend {
+	return babel.NewGeminiBackend(babel.GeminiConfig{
+		APIKey:         "test-key",
+		Model:          "test-model",
+		BaseURL:        url,
+		SafetySettings: []babel.GeminiSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"}},
+	})
+}
+
+func TestGeminiMapsHistory(t *testing.T) {
+	var requests []geminiRequest
+	server := newFakeGemini(t, `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "Hola. "}, {"text": "Me gusta la pizza."}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 7}
+	}`, &requests)
+	g := newTestGemini(server.URL)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	translationContext, result, err := babel.NewBabel(g).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, babel.Usage{PromptTokens: 20, CompletionTokens: 7}, info.Usage)
+
+	_, err = translationContext.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+
+	require.Len(t, requests, 2)
+	second := requests[1]
+	require.NotNil(t, second.SystemInstruction)
+	require.Contains(t, second.SystemInstruction.Parts[0].Text, "You are a translation and rewriting engine")
+	require.Len(t, second.Contents, 3)
+	for i, role := range []string{"user", "model", "user"} {
+		require.Equal(t, role, second.Contents[i].Role)
+	}
+	require.Equal(t, "Hola. Me gusta la pizza.", second.Contents[1].Parts[0].Text)
+	require.Equal(t, []babel.GeminiSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"}}, second.SafetySettings)
+}
+
+func TestGeminiRoundTrip(t *testing.T) {
+	var requests []geminiRequest
+	server := newFakeGemini(t, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "ok"}]}, "finishReason": "STOP"}]}`, &requests)
+
+	_, err := newTestGemini(server.URL).Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	require.Equal(t, withoutMetadata(conversation), requests[0].messages())
+}
+
+func TestGeminiBlockedResponses(t *testing.T) {
+	testCases := []struct {
+		name          string
+		response      string
+		promptBlocked bool
+		reason        string
+	}{
+		{
+			name:          "prompt blocked",
+			response:      `{"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH", "blocked": true}]}}`,
+			promptBlocked: true,
+			reason:        "SAFETY",
+		},
+		{
+			name:     "candidate blocked",
+			response: `{"candidates": [{"finishReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH", "blocked": true}]}]}`,
+			reason:   "SAFETY",
+		},
+		{
+			name:     "recitation",
+			response: `{"candidates": [{"content": {"parts": [{"text": "partial"}]}, "finishReason": "RECITATION"}]}`,
+			reason:   "RECITATION",
+		},
+		{
+			name:     "empty completion",
+			response: `{"candidates": [{"content": {"parts": []}, "finishReason": "STOP"}]}`,
+			reason:   "EMPTY_RESPONSE",
+		},
+		{
+			name:     "no candidates",
+			response: `{"candidates": []}`,
+			reason:   "NO_CANDIDATES",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []geminiRequest
+			server := newFakeGemini(t, tc.response, &requests)
+
+			_, err := newTestGemini(server.URL).Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+			require.ErrorIs(t, err, babel.ErrContentBlocked)
+
+			var blocked *babel.GeminiBlockedError
+			require.True(t, errors.As(err, &blocked))
+			require.Equal(t, tc.promptBlocked, blocked.PromptBlocked)
+			require.Equal(t, tc.reason, blocked.Reason)
+		})
+	}
+}
+
+func TestGeminiAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error": {"code": 503, "message": "The model is overloaded.", "status": "UNAVAILABLE"}}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestGemini(server.URL).Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	var apiErr *babel.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.Equal(t, "The model is overloaded.", apiErr.Message)
+}
+
+func TestGeminiGenerationOptions(t *testing.T) {
+	var requests []geminiRequest
+	server := newFakeGemini(t, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "ok"}]}, "finishReason": "STOP"}]}`, &requests)
+	g := newTestGemini(server.URL)
+
+	_, err := g.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Nil(t, requests[0].GenerationConfig)
+
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{
+		Temperature: float(0),
+		TopP:        float(0.5),
+		MaxTokens:   integer(128),
+		Seed:        integer(7),
+		Stop:        []string{"END"},
+	})
+	_, err = g.Chat(ctx, []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"temperature":     float64(0),
+		"topP":            0.5,
+		"maxOutputTokens": float64(128),
+		"seed":            float64(7),
+		"stopSequences":   []any{"END"},
+	}, requests[1].GenerationConfig)
+}
+
+func TestGeminiHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method, "nothing is generated")
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		if r.URL.Path != "/models/test-model" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"model not found","status":"NOT_FOUND"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"models/test-model","inputTokenLimit":1048576}`))
+	}))
+	defer server.Close()
+
+	require.NoError(t, newTestGemini(server.URL).HealthCheck(context.Background()))
+	err := babel.NewGeminiBackend(babel.GeminiConfig{APIKey: "test-key", Model: "gone", BaseURL: server.URL}).HealthCheck(context.Background())
+	require.ErrorContains(t, err, "model not found")
+}
+
+func TestGeminiCapabilities(t *testing.T) {
+	capabilities := babel.NewGeminiBackend(babel.GeminiConfig{APIKey: "test-key"}).Capabilities()
+	require.True(t, capabilities.StructuredOutput)
+	// images stay off until messages can carry image parts
+	require.False(t, capabilities.Images)
+}
@@ -0,0 +1,82 @@
+package babel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned by the HTTP based backends when the provider answers with an error status.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Header     http.Header
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %d %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s: %d %s: %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// newJSONRequest builds a POST request carrying body as JSON.
+func newJSONRequest(ctx context.Context, url string, body any) (*http.Request, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// doJSON sends req and decodes a successful JSON response into out. Error responses are turned into an APIError,
+// using errorMessage to pull the provider's message out of the body.
+func doJSON(client *http.Client, req *http.Request, provider string, errorMessage func([]byte) string, out any) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if err := checkResponse(res, provider, errorMessage); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: decoding response: %w", provider, err)
+	}
+	return nil
+}
+
+// checkResponse returns an APIError for non-2xx responses, reading the body to extract the message.
+func checkResponse(res *http.Response, provider string, errorMessage func([]byte) string) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	message := ""
+	if errorMessage != nil {
+		message = errorMessage(body)
+	}
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+	return &APIError{
+		Provider:   provider,
+		StatusCode: res.StatusCode,
+		Message:    message,
+		Header:     res.Header,
+	}
+}
@@ -0,0 +1,129 @@
+package babel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// LibreTranslateConfig configures the LibreTranslate API client.
+type LibreTranslateConfig struct {
+	// BaseURL defaults to http://localhost:5000, where a self-hosted LibreTranslate listens
+	BaseURL string
+	// APIKey is only needed by instances that require one
+	APIKey string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// LibreTranslateTranslator translates with a LibreTranslate server.
+type LibreTranslateTranslator struct {
+	config LibreTranslateConfig
+}
+
+// NewLibreTranslateTranslator creates a LibreTranslate client, filling in defaults for unset configuration.
+func NewLibreTranslateTranslator(config LibreTranslateConfig) *LibreTranslateTranslator {
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:5000"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &LibreTranslateTranslator{config: config}
+}
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	Format string `json:"format,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+func libreTranslateErrorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Error
+}
+
+// libreTranslateLang maps tag to the language code LibreTranslate expects, "auto" when unknown.
+func libreTranslateLang(tag language.Tag) string {
+	if tag == language.Und {
+		return "auto"
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// HealthCheck lists the languages of the server, which translates nothing.
+func (l *LibreTranslateTranslator) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.config.BaseURL+"/languages", nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(l.config.HTTPClient, req, "libretranslate", libreTranslateErrorMessage, nil)
+}
+
+func (l *LibreTranslateTranslator) Translate(ctx context.Context, text string, source, target language.Tag) (string, error) {
+	req, err := newJSONRequest(ctx, l.config.BaseURL+"/translate", libreTranslateRequest{
+		Q:      text,
+		Source: libreTranslateLang(source),
+		Target: libreTranslateLang(target),
+		Format: "text",
+		APIKey: l.config.APIKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		TranslatedText string `json:"translatedText"`
+	}
+	if err := doJSON(l.config.HTTPClient, req, "libretranslate", libreTranslateErrorMessage, &res); err != nil {
+		return "", err
+	}
+	return res.TranslatedText, nil
+}
+
+// Detect returns the most confident language reported by the server.
+func (l *LibreTranslateTranslator) Detect(ctx context.Context, text string) (language.Tag, error) {
+	req, err := newJSONRequest(ctx, l.config.BaseURL+"/detect", libreTranslateRequest{
+		Q:      text,
+		APIKey: l.config.APIKey,
+	})
+	if err != nil {
+		return language.Und, err
+	}
+
+	var res []struct {
+		Confidence float64 `json:"confidence"`
+		Language   string  `json:"language"`
+	}
+	if err := doJSON(l.config.HTTPClient, req, "libretranslate", libreTranslateErrorMessage, &res); err != nil {
+		return language.Und, err
+	}
+
+	best := -1
+	for i, detection := range res {
+		if best < 0 || detection.Confidence > res[best].Confidence {
+			best = i
+		}
+	}
+	if best < 0 {
+		return language.Und, fmt.Errorf("libretranslate: no language detected")
+	}
+	tag, err := language.Parse(res[best].Language)
+	if err != nil {
+		return language.Und, fmt.Errorf("libretranslate: %w", err)
+	}
+	return tag, nil
+}
@@ -0,0 +1,112 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key"`
+}
+
+// newFakeLibreTranslate serves /translate and /detect, recording the requests it saw.
+func newFakeLibreTranslate(t *testing.T, requests *[]libreTranslateRequest) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req libreTranslateRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, req)
+		if req.APIKey != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"Invalid API key"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/translate":
+			_, _ = w.Write([]byte(`{"translatedText":"Hallo. Ich mag Pizza."}`))
+		case "/detect":
+			_, _ = w.Write([]byte(`[{"confidence":12.0,"language":"nl"},{"confidence":91.0,"language":"de"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLibreTranslateTranslate(t *testing.T) {
+	var requests []libreTranslateRequest
+	server := newFakeLibreTranslate(t, &requests)
+	l := babel.NewLibreTranslateTranslator(babel.LibreTranslateConfig{BaseURL: server.URL, APIKey: "test-key"})
+
+	result, err := l.Translate(context.Background(), "Hello. I like pizza.", language.Und, language.German)
+	require.NoError(t, err)
+	require.Equal(t, "Hallo. Ich mag Pizza.", result)
+	require.Equal(t, libreTranslateRequest{
+		Q:      "Hello. I like pizza.",
+		Source: "auto",
+		Target: "de",
+		Format: "text",
+		APIKey: "test-key",
+	}, requests[0])
+
+	_, err = l.Translate(context.Background(), "Hello.", language.BritishEnglish, language.German)
+	require.NoError(t, err)
+	require.Equal(t, "en", requests[1].Source)
+}
+
+func TestLibreTranslateDetect(t *testing.T) {
+	var requests []libreTranslateRequest
+	server := newFakeLibreTranslate(t, &requests)
+	l := babel.NewLibreTranslateTranslator(babel.LibreTranslateConfig{BaseURL: server.URL, APIKey: "test-key"})
+
+	tag, err := l.Detect(context.Background(), "Hallo. Ich mag Pizza.")
+	require.NoError(t, err)
+	require.Equal(t, language.German, tag)
+}
+
+func TestLibreTranslateErrors(t *testing.T) {
+	var requests []libreTranslateRequest
+	server := newFakeLibreTranslate(t, &requests)
+	l := babel.NewLibreTranslateTranslator(babel.LibreTranslateConfig{BaseURL: server.URL})
+
+	_, err := l.Translate(context.Background(), "Hello.", language.Und, language.German)
+	var apiErr *babel.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	require.Equal(t, "Invalid API key", apiErr.Message)
+}
+
+func TestLibreTranslateHealthCheck(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		_, _ = w.Write([]byte(`[{"code":"en","name":"English","targets":["es"]}]`))
+	}))
+	defer server.Close()
+
+	l := babel.NewLibreTranslateTranslator(babel.LibreTranslateConfig{BaseURL: server.URL})
+	require.NoError(t, l.HealthCheck(context.Background()))
+	require.Equal(t, []string{"GET /languages"}, paths)
+
+	server.Close()
+	require.Error(t, l.HealthCheck(context.Background()))
+}
@@ -0,0 +1,90 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// ErrImproveUnsupported is returned by TranslationContext.Improve when the context was produced by a machine
+// translation engine and no post-editor is configured to act on feedback.
+var ErrImproveUnsupported = errors.New("improvements not supported by this translation engine")
+
+// MachineTranslator is a dedicated translation engine such as DeepL or LibreTranslate, which translates text
+// directly instead of following chat instructions.
+type MachineTranslator interface {
+	// Translate translates text into target. The source may be language.Und to let the engine detect it.
+	Translate(ctx context.Context, text string, source, target language.Tag) (string, error)
+	// Detect identifies the language text is written in.
+	Detect(ctx context.Context, text string) (language.Tag, error)
+}
+
+// MachineTranslationBackend serves translations from a MachineTranslator. Improvements are delegated to an
+// optional LLM post-editor, which sees the engine's translation as its own previous answer.
+type MachineTranslationBackend struct {
+	engine     MachineTranslator
+	postEditor AISystem
+}
+
+// NewMachineTranslationBackend creates a backend translating with engine. With a nil postEditor every
+// improvement fails with ErrImproveUnsupported.
+func NewMachineTranslationBackend(engine MachineTranslator, postEditor AISystem) *MachineTranslationBackend {
+	return &MachineTranslationBackend{
+		engine:     engine,
+		postEditor: postEditor,
+	}
+}
+
+func (m *MachineTranslationBackend) NewTranslation(ctx context.Context, input string, outputLanguage language.Tag) (*TranslationContext, string, error) {
+	return m.NewTranslationFrom(ctx, input, language.Und, outputLanguage)
+}
+
+// NewTranslationFrom translates input with the engine and returns a context the post-editor can improve on.
+func (m *MachineTranslationBackend) NewTranslationFrom(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (*TranslationContext, string, error) {
+	result, err := m.engine.Translate(ctx, input, sourceLanguage, outputLanguage)
+	if err != nil {
+		return nil, "", err
+	}
+
+	history := []Message{
+		SystemMessage(translationPrompt(outputLanguage)),
+		UserMessage(input),
+		AssistantMessage(result),
+	}
+
+	return &TranslationContext{
+		history:        history,
+		backend:        m.postEditor,
+		outputLanguage: outputLanguage,
+		affinity:       newAffinityKey(),
+		options:        generationOptions(ctx, GenerationOptions{}),
+	}, result, nil
+}
+
+func (m *MachineTranslationBackend) Preview(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (string, error) {
+	return m.engine.Translate(ctx, input, sourceLanguage, outputLanguage)
+}
+
+func (m *MachineTranslationBackend) IdentifyLanguage(ctx context.Context, input string) (language.Tag, error) {
+	tag, err := m.engine.Detect(ctx, input)
+	if err != nil {
+		return language.Und, err
+	}
+	if tag == language.Und {
+		return language.Und, fmt.Errorf("unable to identify language")
+	}
+	return tag, nil
+}
+
+// Capabilities reports nothing beyond plain translation, machine translation engines neither stream nor sample.
+func (m *MachineTranslationBackend) Capabilities() Capabilities {
+	return Capabilities{SamplingParameters: []string{}}
+}
+
+// HealthCheck probes the engine with its own health check, engines without one are taken as healthy: detecting a
+// text would be billed.
+func (m *MachineTranslationBackend) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, m.engine)
+}
@@ -0,0 +1,65 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+// fixedTranslator answers every translation with result and detects every text as detected.
+type fixedTranslator struct {
+	result   string
+	detected language.Tag
+	sources  []language.Tag
+}
+
+func (f *fixedTranslator) Translate(_ context.Context, _ string, source, _ language.Tag) (string, error) {
+	f.sources = append(f.sources, source)
+	return f.result, nil
+}
+
+func (f *fixedTranslator) Detect(context.Context, string) (language.Tag, error) {
+	return f.detected, nil
+}
+
+func TestMachineTranslationRejectsImprove(t *testing.T) {
+	engine := &fixedTranslator{result: "Hola. Me gusta la pizza.", detected: language.English}
+	b := babel.NewMachineTranslationBackend(engine, nil)
+
+	tag, err := b.IdentifyLanguage(context.Background(), "Hello. I like pizza.")
+	require.NoError(t, err)
+	require.Equal(t, language.English, tag)
+
+	translationContext, result, err := b.NewTranslationFrom(context.Background(), "Hello. I like pizza.", tag, language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, "Hola. Me gusta la pizza.", translationContext.LastResult())
+	require.Equal(t, []language.Tag{language.English}, engine.sources)
+
+	_, err = translationContext.Improve(context.Background(), "Make it more formal")
+	require.ErrorIs(t, err, babel.ErrImproveUnsupported)
+	require.Equal(t, "Hola. Me gusta la pizza.", translationContext.LastResult())
+}
+
+func TestMachineTranslationPostEditor(t *testing.T) {
+	engine := &fixedTranslator{result: "Hola. Me gusta la pizza."}
+	b := babel.NewMachineTranslationBackend(engine, babel.NewMockAISystem())
+
+	translationContext, _, err := b.NewTranslation(context.Background(), "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+
+	// the post-editor continues the conversation as if it had produced the engine's translation
+	result, err := translationContext.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me encanta la pizza.", result)
+	require.Equal(t, result, translationContext.LastResult())
+}
+
+func TestMachineTranslationUnidentified(t *testing.T) {
+	b := babel.NewMachineTranslationBackend(&fixedTranslator{detected: language.Und}, nil)
+	_, err := b.IdentifyLanguage(context.Background(), "???")
+	require.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package babel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMultipartUnsupported is returned by adapters whose provider takes a single string per message when a message
+// has several parts, rather than joining them behind the caller's back.
+var ErrMultipartUnsupported = errors.New("messages with several parts are not supported by this engine")
+
+// Role is the author of a Message.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Metadata keys set by Backend on the messages it creates.
+const (
+	// MetadataOperation holds the Operation the message was created for
+	MetadataOperation = "operation"
+	// MetadataTargetLanguage holds the BCP 47 tag of the language the conversation translates into
+	MetadataTargetLanguage = "targetLanguage"
+)
+
+// Part is one piece of a message's content.
+type Part struct {
+	Text string `json:"text"`
+}
+
+// Message is one turn of a conversation with a model, independent of any provider's API. Every adapter converts
+// messages into its provider's format itself.
+type Message struct {
+	Role  Role   `json:"role"`
+	Parts []Part `json:"parts"`
+	// Metadata annotates the message for decorators and tooling. Provider adapters never send it to a model, only
+	// the plugin protocol passes it on, see PluginConfig.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func SystemMessage(text string) Message {
+	return Message{Role: RoleSystem, Parts: []Part{{Text: text}}}
+}
+
+func UserMessage(text string) Message {
+	return Message{Role: RoleUser, Parts: []Part{{Text: text}}}
+}
+
+func AssistantMessage(text string) Message {
+	return Message{Role: RoleAssistant, Parts: []Part{{Text: text}}}
+}
+
+// Text returns the text of all parts joined together.
+func (m Message) Text() string {
+	if len(m.Parts) == 1 {
+		return m.Parts[0].Text
+	}
+	var b strings.Builder
+	for _, p := range m.Parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+// singleText returns the text of a message with at most one part, for providers that take plain string content.
+func singleText(m Message) (string, error) {
+	if len(m.Parts) > 1 {
+		return "", fmt.Errorf("%s message: %w", m.Role, ErrMultipartUnsupported)
+	}
+	return m.Text(), nil
+}
+
+// WithMetadata returns a copy of m with key set to value.
+func (m Message) WithMetadata(key, value string) Message {
+	metadata := make(map[string]string, len(m.Metadata)+1)
+	for k, v := range m.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	m.Metadata = metadata
+	return m
+}
@@ -0,0 +1,102 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+// conversation exercises every role, several parts in one message and metadata, which adapters must not send.
+var conversation = []babel.Message{
+	babel.SystemMessage("Translate into Spanish."),
+	{
+		Role:     babel.RoleUser,
+		Parts:    []babel.Part{{Text: "Hello. "}, {Text: "I like pizza."}},
+		Metadata: map[string]string{babel.MetadataOperation: "start", babel.MetadataTargetLanguage: "es"},
+	},
+	babel.AssistantMessage("Hola. Me gusta la pizza."),
+	babel.UserMessage("Make it more formal."),
+}
+
+// withoutMetadata is what a provider that understands content parts sees of messages.
+func withoutMetadata(messages []babel.Message) []babel.Message {
+	result := make([]babel.Message, len(messages))
+	for i, m := range messages {
+		result[i] = babel.Message{Role: m.Role, Parts: m.Parts}
+	}
+	return result
+}
+
+// plainConversation is conversation with a single part per message, the only kind providers taking plain string
+// content accept.
+var plainConversation = []babel.Message{
+	babel.SystemMessage("Translate into Spanish."),
+	{
+		Role:     babel.RoleUser,
+		Parts:    []babel.Part{{Text: "Hello. I like pizza."}},
+		Metadata: map[string]string{babel.MetadataOperation: "start", babel.MetadataTargetLanguage: "es"},
+	},
+	babel.AssistantMessage("Hola. Me gusta la pizza."),
+	babel.UserMessage("Make it more formal."),
+}
+
+func TestMessageText(t *testing.T) {
+	require.Equal(t, "Hello. I like pizza.", conversation[1].Text())
+	require.Equal(t, "Make it more formal.", conversation[3].Text())
+	require.Equal(t, "", babel.Message{Role: babel.RoleUser}.Text())
+}
+
+func TestMessageWithMetadata(t *testing.T) {
+	original := babel.UserMessage("Hello.").WithMetadata(babel.MetadataOperation, "start")
+	tagged := original.WithMetadata(babel.MetadataTargetLanguage, "ja")
+
+	require.Equal(t, map[string]string{babel.MetadataOperation: "start"}, original.Metadata)
+	require.Equal(t, map[string]string{babel.MetadataOperation: "start", babel.MetadataTargetLanguage: "ja"}, tagged.Metadata)
+}
+
+// recordingAISystem answers with a fixed reply, or echoes the last message without one, and keeps the messages
+// of every call.
+type recordingAISystem struct {
+	reply        string
+	capabilities babel.Capabilities
+	calls        [][]babel.Message
+}
+
+func (r *recordingAISystem) Capabilities() babel.Capabilities { return r.capabilities }
+
+func (r *recordingAISystem) Chat(_ context.Context, messages []babel.Message) (string, error) {
+	r.calls = append(r.calls, messages)
+	if r.reply == "" {
+		return messages[len(messages)-1].Text(), nil
+	}
+	return r.reply, nil
+}
+
+func TestBackendTagsMessages(t *testing.T) {
+	system := &recordingAISystem{reply: "ja"}
+	b := babel.NewBabel(system)
+
+	translationContext, _, err := b.NewTranslation(context.Background(), "Hello.", language.Japanese)
+	require.NoError(t, err)
+	_, err = translationContext.Improve(context.Background(), "Make it polite.")
+	require.NoError(t, err)
+	_, err = b.Preview(context.Background(), "Hello.", language.English, language.Japanese)
+	require.NoError(t, err)
+	_, err = b.IdentifyLanguage(context.Background(), "こんにちは。")
+	require.NoError(t, err)
+
+	require.Len(t, system.calls, 4)
+	for i, want := range []map[string]string{
+		{babel.MetadataOperation: string(babel.OperationStart), babel.MetadataTargetLanguage: "ja"},
+		{babel.MetadataOperation: string(babel.OperationImprove), babel.MetadataTargetLanguage: "ja"},
+		{babel.MetadataOperation: string(babel.OperationPreview), babel.MetadataTargetLanguage: "ja"},
+		{babel.MetadataOperation: string(babel.OperationIdentify)},
+	} {
+		messages := system.calls[i]
+		require.Nil(t, messages[0].Metadata, "system prompt of call %d", i)
+		require.Equal(t, want, messages[len(messages)-1].Metadata, "input of call %d", i)
+	}
+}
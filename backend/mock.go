@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"strings"
 	"time"
-
-	"github.com/openai/openai-go"
 )
 
 // MockAISystem is a simple mock that implements AISystem interface for testing. It returns a fixed result for all requests based on the expectations of the tests.
@@ -28,17 +26,26 @@ func NewMockAISystemWithDelay(delay time.Duration) *MockAISystem {
 	}
 }
 
-func (m *MockAISystem) Chat(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion) (string, error) {
+func (m *MockAISystem) Capabilities() Capabilities {
+	return Capabilities{SamplingParameters: []string{}}
+}
+
+// HealthCheck always succeeds, the mock has nothing to reach.
+func (m *MockAISystem) HealthCheck(context.Context) error {
+	return nil
+}
+
+func (m *MockAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
 	// Add artificial delay if configured
 	if m.Delay > 0 {
 		time.Sleep(m.Delay)
 	}
 
 	// identify the type of request based on the system message at the start
-	systemMessage := messages[0].OfSystem.Content.OfString.String()
+	systemMessage := messages[0].Text()
 	if strings.Contains(systemMessage, "Identify the language of the following text") {
 		// there should only be one message in this case
-		sampleForIdentification := messages[1].OfUser.Content.OfString.String()
+		sampleForIdentification := messages[1].Text()
 		if strings.Contains(sampleForIdentification, "Hello.") {
 			return "en-US", nil
 		}
@@ -0,0 +1,100 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+)
+
+// ModelInfo describes a model a backend can serve.
+type ModelInfo struct {
+	Name string `json:"name"`
+	// Capabilities of the backend serving the model. The context length is only known for the configured model,
+	// unless the provider reports it per model.
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// ModelLister is implemented by backends that can tell which models they are able to serve.
+type ModelLister interface {
+	// ListModels returns the models of the backend, nil when it cannot tell.
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// listModels lists the models of every system that can, sorted by name. A model served by several systems is
+// listed once with the capabilities all of them share. Failing systems are logged and left out, an error is only
+// returned when every system failed.
+func listModels(ctx context.Context, systems ...AISystem) ([]ModelInfo, error) {
+	byName := make(map[string]ModelInfo)
+	var errs []error
+	listed := false
+	for _, system := range systems {
+		lister, ok := system.(ModelLister)
+		if !ok {
+			continue
+		}
+		models, err := lister.ListModels(ctx)
+		if err != nil {
+			slog.Warn("listing models failed", "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		listed = true
+		for _, model := range models {
+			if known, ok := byName[model.Name]; ok {
+				model.Capabilities = known.Capabilities.Intersect(model.Capabilities)
+			}
+			byName[model.Name] = model
+		}
+	}
+	if !listed && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	result := make([]ModelInfo, 0, len(byName))
+	for _, model := range byName {
+		result = append(result, model)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// ListModels lists the models of every backend that may serve a call, including the comparison candidates.
+func (b *Backend) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	systems := []AISystem{b.backend}
+	if b.router != nil {
+		systems = b.router.systems()
+	}
+	for _, op := range Operations {
+		if system, ok := b.operations[op]; ok {
+			systems = append(systems, system)
+		}
+	}
+	for _, candidate := range b.candidates {
+		systems = append(systems, candidate.Backend)
+	}
+	return listModels(ctx, systems...)
+}
+
+// ListModels lists the models of the wrapped backend. Listing is not retried, it is cheap to ask again.
+func (r *RetryAISystem) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return listModels(ctx, r.backend)
+}
+
+// ListModels lists the models of every member.
+func (f *FailoverAISystem) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	systems := make([]AISystem, 0, len(f.members))
+	for _, m := range f.members {
+		systems = append(systems, m.Backend)
+	}
+	return listModels(ctx, systems...)
+}
+
+// ListModels lists the models of every endpoint, hosts that are down are left out.
+func (p *PoolAISystem) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	systems := make([]AISystem, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		systems = append(systems, e.Backend)
+	}
+	return listModels(ctx, systems...)
+}
@@ -0,0 +1,50 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// listingAISystem reports a fixed model list, or fails to list.
+type listingAISystem struct {
+	recordingAISystem
+	models []babel.ModelInfo
+	err    error
+}
+
+func (l *listingAISystem) ListModels(context.Context) ([]babel.ModelInfo, error) {
+	return l.models, l.err
+}
+
+func TestBackendListsModels(t *testing.T) {
+	streaming := babel.Capabilities{Streaming: true, ContextLength: 8192, SamplingParameters: babel.AllSamplingParameters}
+	plain := babel.Capabilities{SamplingParameters: []string{babel.ParamTemperature}}
+	local := &listingAISystem{models: []babel.ModelInfo{{Name: "small", Capabilities: streaming}, {Name: "large", Capabilities: streaming}}}
+	remote := &listingAISystem{models: []babel.ModelInfo{{Name: "small", Capabilities: plain}, {Name: "remote", Capabilities: plain}}}
+	broken := &listingAISystem{err: errors.New("connection refused")}
+
+	b := babel.NewBabel(babel.NewFailoverAISystem(babel.FailoverConfig{},
+		babel.FailoverMember{Name: "local", Backend: babel.NewRetryAISystem("local", local, babel.RetryConfig{})},
+		babel.FailoverMember{Name: "broken", Backend: broken},
+	))
+	b.SetOperationBackend(babel.OperationPreview, remote)
+	// systems that cannot list are skipped
+	b.SetOperationBackend(babel.OperationIdentify, &recordingAISystem{})
+
+	models, err := b.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []babel.ModelInfo{
+		{Name: "large", Capabilities: streaming},
+		{Name: "remote", Capabilities: plain},
+		// served by both, so only what both support, with the one known context length
+		{Name: "small", Capabilities: babel.Capabilities{ContextLength: 8192, SamplingParameters: []string{babel.ParamTemperature}}},
+	}, models)
+
+	// an error is only reported when nothing could be listed
+	_, err = babel.NewBabel(broken).ListModels(context.Background())
+	require.ErrorContains(t, err, "connection refused")
+}
@@ -0,0 +1,371 @@
+package babel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaConfig configures the native Ollama API backend.
+type OllamaConfig struct {
+	// BaseURL defaults to http://localhost:11434
+	BaseURL string
+	// Model defaults to aya-expanse:8b
+	Model string
+	// KeepAlive is how long the model stays loaded after a request, e.g. "30m", or "-1" to never unload it.
+	// Empty leaves the server default.
+	KeepAlive string
+	// NumCtx sets the context window in tokens, zero keeps the model's default
+	NumCtx int
+	// Options are sent as the request options, e.g. {"temperature": 0.2, "num_gpu": 1}. NumCtx takes precedence.
+	Options map[string]any
+	// Generation are the default generation options, requests may override them. They take precedence over Options.
+	Generation GenerationOptions
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// OllamaBackend talks to Ollama's native /api/chat, which unlike the OpenAI shim exposes keep_alive and the
+// model options, and manages the models installed on the server.
+type OllamaBackend struct {
+	config  OllamaConfig
+	options map[string]any
+}
+
+// NewOllamaBackend creates an Ollama backend, filling in defaults for unset configuration.
+func NewOllamaBackend(config OllamaConfig) *OllamaBackend {
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:11434"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Model == "" {
+		config.Model = "aya-expanse:8b"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	var options map[string]any
+	if len(config.Options) > 0 || config.NumCtx > 0 {
+		options = make(map[string]any, len(config.Options)+1)
+		for k, v := range config.Options {
+			options[k] = v
+		}
+		if config.NumCtx > 0 {
+			options["num_ctx"] = config.NumCtx
+		}
+	}
+	return &OllamaBackend{config: config, options: options}
+}
+
+// Capabilities reports the configured NumCtx as context length. Images depend on the model, which Ollama does
+// not tell without loading it, so they are not advertised.
+func (o *OllamaBackend) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming:          true,
+		StructuredOutput:   true,
+		ContextLength:      o.config.NumCtx,
+		SamplingParameters: AllSamplingParameters,
+	}
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func ollamaErrorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil {
+		return ""
+	}
+	return e.Error
+}
+
+// toOllamaMessages maps the history to Ollama's messages, which take a single string each, so messages with several
+// parts are rejected.
+func toOllamaMessages(messages []Message) ([]ollamaMessage, error) {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, m := range messages {
+		text, err := singleText(m)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ollamaMessage{Role: string(m.Role), Content: text})
+	}
+	return result, nil
+}
+
+func (o *OllamaBackend) newChatRequest(ctx context.Context, messages []ollamaMessage, stream bool) (*http.Request, error) {
+	generation := generationOptions(ctx, o.config.Generation)
+	return newJSONRequest(ctx, o.config.BaseURL+"/api/chat", ollamaChatRequest{
+		Model:     generation.modelOr(o.config.Model),
+		Messages:  messages,
+		Stream:    stream,
+		KeepAlive: o.config.KeepAlive,
+		Options:   o.requestOptions(generation),
+	})
+}
+
+// requestOptions adds the sampling parameters of generation to the configured options.
+func (o *OllamaBackend) requestOptions(generation GenerationOptions) map[string]any {
+	if len(generation.Parameters()) == 0 {
+		return o.options
+	}
+	options := make(map[string]any, len(o.options)+5)
+	for k, v := range o.options {
+		options[k] = v
+	}
+	if generation.Temperature != nil {
+		options["temperature"] = *generation.Temperature
+	}
+	if generation.TopP != nil {
+		options["top_p"] = *generation.TopP
+	}
+	if generation.MaxTokens != nil {
+		options["num_predict"] = *generation.MaxTokens
+	}
+	if generation.Seed != nil {
+		options["seed"] = *generation.Seed
+	}
+	if generation.Stop != nil {
+		options["stop"] = generation.Stop
+	}
+	return options
+}
+
+func (o *OllamaBackend) Chat(ctx context.Context, messages []Message) (string, error) {
+	ollamaMessages, err := toOllamaMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	req, err := o.newChatRequest(ctx, ollamaMessages, false)
+	if err != nil {
+		return "", err
+	}
+
+	var res ollamaChatResponse
+	if err := doJSON(o.config.HTTPClient, req, "ollama", ollamaErrorMessage, &res); err != nil {
+		return "", err
+	}
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Usage = Usage{PromptTokens: res.PromptEvalCount, CompletionTokens: res.EvalCount}
+	}
+	return res.Message.Content, nil
+}
+
+// ChatStream streams the completion, calling onDelta with every piece of text as it arrives, and returns the whole
+// completion once the stream ends.
+func (o *OllamaBackend) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	ollamaMessages, err := toOllamaMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	req, err := o.newChatRequest(ctx, ollamaMessages, true)
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	err = o.stream(req, func(line []byte) (bool, error) {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return false, fmt.Errorf("ollama: decoding stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return false, &APIError{Provider: "ollama", StatusCode: http.StatusInternalServerError, Message: chunk.Error}
+		}
+		if chunk.Message.Content != "" {
+			text.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				if err := onDelta(chunk.Message.Content); err != nil {
+					return false, err
+				}
+			}
+		}
+		if chunk.Done {
+			if info := callInfoFromContext(ctx); info != nil {
+				info.Usage = Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			}
+		}
+		return chunk.Done, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// stream sends req and hands every line of the newline delimited JSON response to handle until it reports done.
+func (o *OllamaBackend) stream(req *http.Request, handle func(line []byte) (bool, error)) error {
+	res, err := o.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if err := checkResponse(res, "ollama", ollamaErrorMessage); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		done, err := handle(line)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("ollama: stream ended before done")
+}
+
+// Models lists the names of the models installed on the server.
+func (o *OllamaBackend) Models(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.config.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := doJSON(o.config.HTTPClient, req, "ollama", ollamaErrorMessage, &res); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(res.Models))
+	for _, m := range res.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// ListModels lists the installed models. NumCtx applies to all of them.
+func (o *OllamaBackend) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	names, err := o.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]ModelInfo, 0, len(names))
+	for _, name := range names {
+		models = append(models, ModelInfo{Name: name, Capabilities: o.Capabilities()})
+	}
+	return models, nil
+}
+
+// HealthCheck lists the installed models, which is cheap and does not load a model.
+func (o *OllamaBackend) HealthCheck(ctx context.Context) error {
+	_, err := o.Models(ctx)
+	return err
+}
+
+// EnsureModel checks that the configured model is installed, pulling it when pull is set. A model without a tag
+// matches its :latest version, like the Ollama CLI.
+func (o *OllamaBackend) EnsureModel(ctx context.Context, pull bool) error {
+	models, err := o.Models(ctx)
+	if err != nil {
+		return err
+	}
+	want := o.config.Model
+	if !strings.Contains(want, ":") {
+		want += ":latest"
+	}
+	for _, name := range models {
+		if name == want {
+			return nil
+		}
+	}
+	if !pull {
+		return fmt.Errorf("ollama: model %q is not installed, pull it or enable pulling", o.config.Model)
+	}
+	return o.Pull(ctx)
+}
+
+// Pull downloads the configured model, logging progress as it goes.
+func (o *OllamaBackend) Pull(ctx context.Context) error {
+	req, err := newJSONRequest(ctx, o.config.BaseURL+"/api/pull", map[string]any{
+		"model":  o.config.Model,
+		"stream": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("pulling ollama model", "model", o.config.Model)
+	lastStatus, lastPercent := "", -1
+	return o.stream(req, func(line []byte) (bool, error) {
+		var progress struct {
+			Status    string `json:"status"`
+			Total     int64  `json:"total"`
+			Completed int64  `json:"completed"`
+			Error     string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &progress); err != nil {
+			return false, fmt.Errorf("ollama: decoding pull progress: %w", err)
+		}
+		if progress.Error != "" {
+			return false, fmt.Errorf("ollama: pulling %s: %s", o.config.Model, progress.Error)
+		}
+
+		// log every status change, and download progress in steps of ten percent
+		percent := -1
+		if progress.Total > 0 {
+			percent = int(progress.Completed * 100 / progress.Total)
+		}
+		if progress.Status != lastStatus || percent/10 > lastPercent/10 {
+			if percent >= 0 {
+				slog.Info("pulling ollama model", "model", o.config.Model, "status", progress.Status, "percent", percent)
+			} else {
+				slog.Info("pulling ollama model", "model", o.config.Model, "status", progress.Status)
+			}
+			lastStatus, lastPercent = progress.Status, percent
+		}
+		return progress.Status == "success", nil
+	})
+}
+
+// WarmUp loads the model into memory without generating anything, so the first translation does not pay for it.
+func (o *OllamaBackend) WarmUp(ctx context.Context) error {
+	started := time.Now()
+	req, err := o.newChatRequest(ctx, []ollamaMessage{}, false)
+	if err != nil {
+		return err
+	}
+	if err := doJSON(o.config.HTTPClient, req, "ollama", ollamaErrorMessage, nil); err != nil {
+		return err
+	}
+	slog.Info("ollama model loaded", "model", o.config.Model, "took", time.Since(started))
+	return nil
+}
@@ -0,0 +1,272 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type ollamaChatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive"`
+	Options   map[string]any `json:"options"`
+}
+
+// messages maps the request back to the history it was made from.
+func (r ollamaChatRequest) messages() []babel.Message {
+	var messages []babel.Message
+	for _, m := range r.Messages {
+		messages = append(messages, babel.Message{Role: babel.Role(m.Role), Parts: []babel.Part{{Text: m.Content}}})
+	}
+	return messages
+}
+
+// fakeOllama serves the native Ollama API with a set of installed models, recording chat and pull requests.
+type fakeOllama struct {
+	mu        sync.Mutex
+	installed []string
+	chats     []ollamaChatRequest
+	pulls     []string
+	reply     string
+}
+
+func newFakeOllama(t *testing.T, reply string, installed ...string) (*fakeOllama, *httptest.Server) {
+	t.Helper()
+	f := &fakeOllama{installed: installed, reply: reply}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/tags":
+			var models []map[string]string
+			for _, name := range f.installed {
+				models = append(models, map[string]string{"name": name})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"models": models})
+		case "/api/pull":
+			var req struct {
+				Model string `json:"model"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			f.pulls = append(f.pulls, req.Model)
+			for _, line := range []string{
+				`{"status":"pulling manifest"}`,
+				`{"status":"pulling abc","total":100,"completed":0}`,
+				`{"status":"pulling abc","total":100,"completed":55}`,
+				`{"status":"pulling abc","total":100,"completed":100}`,
+				`{"status":"verifying sha256 digest"}`,
+				`{"status":"success"}`,
+			} {
+				_, _ = fmt.Fprintln(w, line)
+			}
+			f.installed = append(f.installed, req.Model)
+		case "/api/chat":
+			var req ollamaChatRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			f.chats = append(f.chats, req)
+			if len(req.Messages) == 0 {
+				_, _ = fmt.Fprintf(w, `{"model":%q,"message":{"role":"assistant","content":""},"done_reason":"load","done":true}`, req.Model)
+				return
+			}
+			if !req.Stream {
+				_, _ = fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":true,"prompt_eval_count":11,"eval_count":6}`, f.reply)
+				return
+			}
+			for _, word := range strings.SplitAfter(f.reply, " ") {
+				_, _ = fmt.Fprintf(w, "{\"message\":{\"role\":\"assistant\",\"content\":%q},\"done\":false}\n", word)
+			}
+			_, _ = fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":11,"eval_count":6}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func TestOllamaChat(t *testing.T) {
+	f, server := newFakeOllama(t, "Hola. Me gusta la pizza.")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{
+		BaseURL:   server.URL,
+		Model:     "test-model",
+		KeepAlive: "30m",
+		NumCtx:    8192,
+		Options:   map[string]any{"temperature": 0.2, "num_ctx": 2048},
+	})
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	_, result, err := babel.NewBabel(o).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, babel.Usage{PromptTokens: 11, CompletionTokens: 6}, info.Usage)
+
+	req := f.chats[0]
+	require.Equal(t, "test-model", req.Model)
+	require.False(t, req.Stream)
+	require.Equal(t, "30m", req.KeepAlive)
+	require.Equal(t, map[string]any{"temperature": 0.2, "num_ctx": float64(8192)}, req.Options)
+	require.Len(t, req.Messages, 2)
+	require.Equal(t, "system", req.Messages[0].Role)
+	require.Equal(t, "user", req.Messages[1].Role)
+	require.Equal(t, "Hello. I like pizza.", req.Messages[1].Content)
+}
+
+func TestOllamaRoundTrip(t *testing.T) {
+	f, server := newFakeOllama(t, "ok")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL})
+
+	_, err := o.Chat(context.Background(), plainConversation)
+	require.NoError(t, err)
+	require.Equal(t, withoutMetadata(plainConversation), f.chats[0].messages())
+}
+
+func TestOllamaRejectsMultipartMessages(t *testing.T) {
+	f, server := newFakeOllama(t, "ok")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL})
+
+	_, err := o.Chat(context.Background(), conversation)
+	require.ErrorIs(t, err, babel.ErrMultipartUnsupported)
+	_, err = o.ChatStream(context.Background(), conversation, func(string) error { return nil })
+	require.ErrorIs(t, err, babel.ErrMultipartUnsupported)
+	require.Empty(t, f.chats)
+}
+
+func TestOllamaStreaming(t *testing.T) {
+	_, server := newFakeOllama(t, "Hallo. Ich mag Pizza.")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL})
+
+	var deltas []string
+	ctx, info := babel.WithCallInfo(context.Background())
+	result, err := o.ChatStream(ctx, []babel.Message{babel.UserMessage("Hello. I like pizza.")}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hallo. Ich mag Pizza.", result)
+	require.Equal(t, []string{"Hallo. ", "Ich ", "mag ", "Pizza."}, deltas)
+	require.Equal(t, babel.Usage{PromptTokens: 11, CompletionTokens: 6}, info.Usage)
+
+	var _ babel.StreamingAISystem = o
+}
+
+func TestOllamaEnsureModel(t *testing.T) {
+	f, server := newFakeOllama(t, "", "aya-expanse:8b", "llama3:latest")
+
+	// an untagged model matches :latest
+	require.NoError(t, babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL, Model: "llama3"}).EnsureModel(context.Background(), false))
+	require.NoError(t, babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL}).EnsureModel(context.Background(), false))
+
+	missing := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL, Model: "qwen2.5:1.5b"})
+	err := missing.EnsureModel(context.Background(), false)
+	require.ErrorContains(t, err, "not installed")
+	require.Empty(t, f.pulls)
+
+	require.NoError(t, missing.EnsureModel(context.Background(), true))
+	require.Equal(t, []string{"qwen2.5:1.5b"}, f.pulls)
+	require.NoError(t, missing.HealthCheck(context.Background()))
+}
+
+func TestOllamaWarmUp(t *testing.T) {
+	f, server := newFakeOllama(t, "")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL, Model: "test-model", KeepAlive: "-1"})
+
+	require.NoError(t, o.WarmUp(context.Background()))
+	require.Len(t, f.chats, 1)
+	require.Empty(t, f.chats[0].Messages)
+	require.Equal(t, "-1", f.chats[0].KeepAlive)
+}
+
+func TestOllamaGenerationOptions(t *testing.T) {
+	f, server := newFakeOllama(t, "ok")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{
+		BaseURL:    server.URL,
+		Model:      "test-model",
+		Options:    map[string]any{"temperature": 0.8, "num_gpu": 1},
+		Generation: babel.GenerationOptions{Seed: integer(3)},
+	})
+
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{
+		Model:       "large-model",
+		Temperature: float(0.1),
+		TopP:        float(0.9),
+		MaxTokens:   integer(200),
+		Stop:        []string{"END"},
+	})
+	_, err := o.Chat(ctx, []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Equal(t, "large-model", f.chats[0].Model)
+	require.Equal(t, map[string]any{
+		"temperature": 0.1,
+		"top_p":       0.9,
+		"num_predict": float64(200),
+		"seed":        float64(3),
+		"stop":        []any{"END"},
+		"num_gpu":     float64(1),
+	}, f.chats[0].Options)
+
+	// the configured options are left alone for calls without generation options
+	_, err = babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL, Options: map[string]any{"temperature": 0.8}}).
+		Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"temperature": 0.8}, f.chats[1].Options)
+}
+
+func TestOllamaListModels(t *testing.T) {
+	_, server := newFakeOllama(t, "", "aya-expanse:8b", "llama3:latest")
+	o := babel.NewOllamaBackend(babel.OllamaConfig{BaseURL: server.URL, NumCtx: 8192})
+
+	models, err := o.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []babel.ModelInfo{
+		{Name: "aya-expanse:8b", Capabilities: o.Capabilities()},
+		{Name: "llama3:latest", Capabilities: o.Capabilities()},
+	}, models)
+	require.Equal(t, 8192, models[0].Capabilities.ContextLength)
+}
+
+func TestOllamaEngineBoundsPull(t *testing.T) {
+	stalled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			_, _ = w.Write([]byte(`{"models":[]}`))
+			return
+		}
+		// the pull never makes progress
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(stalled)
+
+	start := time.Now()
+	_, err := babel.NewAISystem(context.Background(), "ollama", "", lookupMap(map[string]string{
+		"OLLAMA_BASE_URL":     server.URL,
+		"OLLAMA_PULL":         "true",
+		"OLLAMA_PULL_TIMEOUT": "50ms",
+	}))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = babel.NewAISystem(ctx, "ollama", "", lookupMap(map[string]string{"OLLAMA_BASE_URL": server.URL, "OLLAMA_PULL": "true"}))
+	require.ErrorIs(t, err, context.Canceled, "the caller's context bounds the pull too")
+}
@@ -3,20 +3,44 @@ package babel
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/azure"
 	"github.com/openai/openai-go/option"
 )
 
 type OpenAIBackend struct {
-	client openai.Client
-	model  string
+	client        openai.Client
+	model         string
+	contextLength int
+	options       GenerationOptions
+}
+
+// OpenAIConfig configures an OpenAI compatible chat completions endpoint, or an Azure OpenAI deployment.
+type OpenAIConfig struct {
+	// BaseURL of the API including any path prefix, e.g. https://gateway.example.com/llm/v1. Defaults to
+	// http://localhost:11434/v1. In Azure mode it is the resource endpoint, e.g. https://my-resource.openai.azure.com
+	BaseURL string
+	APIKey  string
+	// Model is the model name, or the deployment name in Azure mode. Defaults to aya-expanse:8b
+	Model string
+	// Azure talks to an Azure OpenAI deployment, sending the key in the api-key header
+	Azure bool
+	// APIVersion is the api-version query parameter required by Azure, defaults to 2024-10-21
+	APIVersion string
+	// ContextLength is the model's context window in tokens, zero when unknown
+	ContextLength int
+	// Options are the default generation options, requests may override them
+	Options  GenerationOptions
+	Endpoint EndpointConfig
 }
 
 func NewOpenAIDefaultLocalBackend() *OpenAIBackend {
 	return NewOpenAILocalBackend("", 0, "", "")
 }
 
+// NewOpenAILocalBackend talks plain HTTP to an OpenAI compatible server such as Ollama.
 func NewOpenAILocalBackend(host string, port int, apiKey string, model string) *OpenAIBackend {
 	if host == "" {
 		host = "localhost"
@@ -25,30 +49,167 @@ func NewOpenAILocalBackend(host string, port int, apiKey string, model string) *
 		port = 11434
 	}
 
-	if model == "" {
-		model = "aya-expanse:8b"
+	return newOpenAIBackend(OpenAIConfig{
+		BaseURL: fmt.Sprintf("http://%s:%d/v1", host, port),
+		APIKey:  apiKey,
+		Model:   model,
+	}, http.DefaultClient)
+}
+
+// NewOpenAIBackend creates a backend from a full endpoint configuration, filling in defaults for unset fields.
+// It fails when the TLS or proxy settings cannot be applied.
+func NewOpenAIBackend(config OpenAIConfig) (*OpenAIBackend, error) {
+	if config.Azure && config.BaseURL == "" {
+		return nil, fmt.Errorf("azure: base URL of the resource is required")
+	}
+	httpClient, err := config.Endpoint.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("openai: %w", err)
+	}
+	return newOpenAIBackend(config, httpClient), nil
+}
+
+func newOpenAIBackend(config OpenAIConfig, httpClient *http.Client) *OpenAIBackend {
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:11434/v1"
+	}
+	if config.Model == "" {
+		config.Model = "aya-expanse:8b"
+	}
+
+	// RetryAISystem retries, with the SDK retrying too every attempt would turn into three requests
+	options := []option.RequestOption{option.WithHTTPClient(httpClient), option.WithMaxRetries(0)}
+	if config.Azure {
+		if config.APIVersion == "" {
+			config.APIVersion = "2024-10-21"
+		}
+		options = append(options,
+			azure.WithEndpoint(config.BaseURL, config.APIVersion),
+			azure.WithAPIKey(config.APIKey),
+		)
+	} else {
+		options = append(options,
+			option.WithBaseURL(config.BaseURL),
+			option.WithAPIKey(config.APIKey),
+		)
 	}
 
 	return &OpenAIBackend{
-		client: openai.NewClient(
-			option.WithBaseURL(fmt.Sprintf("http://%s:%d/v1", host, port)),
-			option.WithAPIKey(apiKey),
-		),
-		model: model,
-	}
-}
-
-func (o *OpenAIBackend) Chat(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion) (string, error) {
-	chatCompletion, err := o.client.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model:    o.model,
-			Messages: messages,
-		},
-	)
+		client:        openai.NewClient(options...),
+		model:         config.Model,
+		contextLength: config.ContextLength,
+		options:       config.Options,
+	}
+}
+
+func (o *OpenAIBackend) Capabilities() Capabilities {
+	// Messages only carry text parts, so images are not offered even though the API takes them
+	return Capabilities{
+		StructuredOutput:   true,
+		ContextLength:      o.contextLength,
+		SamplingParameters: AllSamplingParameters,
+	}
+}
+
+func (o *OpenAIBackend) Chat(ctx context.Context, messages []Message) (string, error) {
+	options := generationOptions(ctx, o.options)
+	params := openai.ChatCompletionNewParams{
+		Model:    options.modelOr(o.model),
+		Messages: toOpenAIMessages(messages),
+	}
+	if options.Temperature != nil {
+		params.Temperature = openai.Float(*options.Temperature)
+	}
+	if options.TopP != nil {
+		params.TopP = openai.Float(*options.TopP)
+	}
+	if options.MaxTokens != nil {
+		params.MaxTokens = openai.Int(int64(*options.MaxTokens))
+	}
+	if options.Seed != nil {
+		params.Seed = openai.Int(int64(*options.Seed))
+	}
+	if options.Stop != nil {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: options.Stop}
+	}
+
+	chatCompletion, err := o.client.Chat.Completions.New(ctx, params)
 	if err != nil {
 		return "", err
 	}
 
+	if info := callInfoFromContext(ctx); info != nil {
+		info.Usage = Usage{
+			PromptTokens:     int(chatCompletion.Usage.PromptTokens),
+			CompletionTokens: int(chatCompletion.Usage.CompletionTokens),
+		}
+	}
+
 	return chatCompletion.Choices[0].Message.Content, nil
 }
+
+// HealthCheck lists the models served by the endpoint, which is cheap and does not load a model.
+func (o *OpenAIBackend) HealthCheck(ctx context.Context) error {
+	_, err := o.client.Models.List(ctx)
+	return err
+}
+
+// ListModels lists the models served by the endpoint. Only the configured model has a known context length.
+func (o *OpenAIBackend) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	iter := o.client.Models.ListAutoPaging(ctx)
+	var models []ModelInfo
+	for iter.Next() {
+		capabilities := o.Capabilities()
+		if iter.Current().ID != o.model {
+			capabilities.ContextLength = 0
+		}
+		models = append(models, ModelInfo{Name: iter.Current().ID, Capabilities: capabilities})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// toOpenAIMessages maps the history to OpenAI chat messages. A single part is sent as plain string content, which
+// every compatible server understands, several parts as an array of text parts.
+func toOpenAIMessages(messages []Message) []openai.ChatCompletionMessageParamUnion {
+	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages))
+	for _, m := range messages {
+		switch m.Role {
+		case RoleSystem:
+			if len(m.Parts) == 1 {
+				result = append(result, openai.SystemMessage(m.Parts[0].Text))
+				continue
+			}
+			parts := make([]openai.ChatCompletionContentPartTextParam, 0, len(m.Parts))
+			for _, p := range m.Parts {
+				parts = append(parts, openai.ChatCompletionContentPartTextParam{Text: p.Text})
+			}
+			result = append(result, openai.SystemMessage(parts))
+		case RoleUser:
+			if len(m.Parts) == 1 {
+				result = append(result, openai.UserMessage(m.Parts[0].Text))
+				continue
+			}
+			parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(m.Parts))
+			for _, p := range m.Parts {
+				parts = append(parts, openai.TextContentPart(p.Text))
+			}
+			result = append(result, openai.UserMessage(parts))
+		case RoleAssistant:
+			if len(m.Parts) == 1 {
+				result = append(result, openai.AssistantMessage(m.Parts[0].Text))
+				continue
+			}
+			parts := make([]openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion, 0, len(m.Parts))
+			for _, p := range m.Parts {
+				parts = append(parts, openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion{
+					OfText: &openai.ChatCompletionContentPartTextParam{Text: p.Text},
+				})
+			}
+			result = append(result, openai.AssistantMessage(parts))
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type openAIRequest struct {
+	Model       string   `json:"model"`
+	Temperature *float64 `json:"temperature"`
+	TopP        *float64 `json:"top_p"`
+	MaxTokens   *int     `json:"max_tokens"`
+	Seed        *int     `json:"seed"`
+	Stop        []string `json:"stop"`
+	Messages    []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+// messages maps the request back to the history it was made from, content is either a string or text parts.
+func (r openAIRequest) messages(t *testing.T) []babel.Message {
+	t.Helper()
+	var messages []babel.Message
+	for _, m := range r.Messages {
+		var text string
+		if json.Unmarshal(m.Content, &text) == nil {
+			messages = append(messages, babel.Message{Role: babel.Role(m.Role), Parts: []babel.Part{{Text: text}}})
+			continue
+		}
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		require.NoError(t, json.Unmarshal(m.Content, &parts))
+		message := babel.Message{Role: babel.Role(m.Role)}
+		for _, p := range parts {
+			require.Equal(t, "text", p.Type)
+			message.Parts = append(message.Parts, babel.Part{Text: p.Text})
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func TestOpenAIRoundTrip(t *testing.T) {
+	var requests []openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletion))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, APIKey: "test-key", Model: "test-model"})
+	require.NoError(t, err)
+
+	_, err = o.Chat(context.Background(), conversation)
+	require.NoError(t, err)
+	require.Equal(t, withoutMetadata(conversation), requests[0].messages(t))
+
+	// a single part stays plain string content, which every compatible server understands
+	require.JSONEq(t, `"Translate into Spanish."`, string(requests[0].Messages[0].Content))
+}
+
+func TestOpenAIGenerationOptions(t *testing.T) {
+	var requests []openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(chatCompletion))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{
+		BaseURL: server.URL,
+		Model:   "test-model",
+		Options: babel.GenerationOptions{Temperature: float(0.7), Seed: integer(42)},
+	})
+	require.NoError(t, err)
+
+	// the request overrides the configured temperature and keeps the seed
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{
+		Model:       "large-model",
+		Temperature: float(0),
+		TopP:        float(0.9),
+		MaxTokens:   integer(256),
+		Stop:        []string{"\n\n"},
+	})
+	translationContext, _, err := babel.NewBabel(o).NewTranslation(ctx, "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	first := requests[0]
+	require.Equal(t, "large-model", first.Model)
+	require.Equal(t, float(0), first.Temperature)
+	require.Equal(t, float(0.9), first.TopP)
+	require.Equal(t, integer(256), first.MaxTokens)
+	require.Equal(t, integer(42), first.Seed)
+	require.Equal(t, []string{"\n\n"}, first.Stop)
+
+	// improvements keep the options the translation started with, options given for the call win
+	_, err = translationContext.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+	require.Equal(t, "large-model", requests[1].Model)
+	require.Equal(t, float(0), requests[1].Temperature)
+
+	_, err = translationContext.Improve(babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{Temperature: float(0.3)}), "Shorter")
+	require.NoError(t, err)
+	require.Equal(t, "large-model", requests[2].Model)
+	require.Equal(t, float(0.3), requests[2].Temperature)
+
+	// nothing configured and nothing requested leaves the provider defaults
+	plain, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, Model: "test-model"})
+	require.NoError(t, err)
+	_, err = plain.Chat(context.Background(), []babel.Message{babel.UserMessage("hi")})
+	require.NoError(t, err)
+	last := requests[3]
+	require.Equal(t, "test-model", last.Model)
+	require.Nil(t, last.Temperature)
+	require.Nil(t, last.Seed)
+	require.Nil(t, last.Stop)
+}
+
+func TestOpenAIListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"test-model","object":"model","owned_by":"me"},{"id":"other-model","object":"model","owned_by":"me"}]}`))
+	}))
+	defer server.Close()
+
+	o, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL, Model: "test-model", ContextLength: 32768})
+	require.NoError(t, err)
+	models, err := o.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	require.Equal(t, "test-model", models[0].Name)
+	require.Equal(t, 32768, models[0].Capabilities.ContextLength)
+	// the configured context length says nothing about other models
+	require.Equal(t, "other-model", models[1].Name)
+	require.Equal(t, 0, models[1].Capabilities.ContextLength)
+	require.Equal(t, babel.AllSamplingParameters, models[1].Capabilities.SamplingParameters)
+}
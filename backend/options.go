@@ -0,0 +1,146 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidGenerationOptions is returned for generation options outside the GenerationLimits or not supported by
+// the backend.
+var ErrInvalidGenerationOptions = errors.New("invalid generation options")
+
+// GenerationOptions tune how a model generates a completion. Unset fields keep the backend's configured value,
+// or the provider's default when the backend configures none.
+type GenerationOptions struct {
+	// Model replaces the configured model
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	MaxTokens   *int     `json:"maxTokens,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// Merge returns o with every field set in override replaced.
+func (o GenerationOptions) Merge(override GenerationOptions) GenerationOptions {
+	if override.Model != "" {
+		o.Model = override.Model
+	}
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		o.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	return o
+}
+
+// Parameters lists the sampling parameters set in o, named like AllSamplingParameters.
+func (o GenerationOptions) Parameters() []string {
+	var params []string
+	if o.Temperature != nil {
+		params = append(params, ParamTemperature)
+	}
+	if o.TopP != nil {
+		params = append(params, ParamTopP)
+	}
+	if o.MaxTokens != nil {
+		params = append(params, ParamMaxTokens)
+	}
+	if o.Seed != nil {
+		params = append(params, ParamSeed)
+	}
+	if o.Stop != nil {
+		params = append(params, ParamStop)
+	}
+	return params
+}
+
+// modelOr returns the model of o, or model when o does not replace it.
+func (o GenerationOptions) modelOr(model string) string {
+	if o.Model != "" {
+		return o.Model
+	}
+	return model
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions returns a context whose calls use options, merged over any options ctx already carries.
+// Translation contexts keep the options they were started with for their improvements.
+func WithGenerationOptions(ctx context.Context, options GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsKey{}, generationOptions(ctx, GenerationOptions{}).Merge(options))
+}
+
+// generationOptions returns defaults merged with the options carried by ctx.
+func generationOptions(ctx context.Context, defaults GenerationOptions) GenerationOptions {
+	options, _ := ctx.Value(generationOptionsKey{}).(GenerationOptions)
+	return defaults.Merge(options)
+}
+
+// GenerationLimits bound the generation options a client may ask for.
+type GenerationLimits struct {
+	// Models a request may pick, requests cannot change the model when empty
+	Models []string
+	// MaxTemperature defaults to 2
+	MaxTemperature float64
+	// MaxTokens caps max_tokens, unbounded when zero
+	MaxTokens int
+	// MaxStop is the number of stop sequences allowed, defaults to 4
+	MaxStop int
+}
+
+// Check reports every option outside the limits or not supported according to capabilities.
+func (l GenerationLimits) Check(options GenerationOptions, capabilities Capabilities) error {
+	if l.MaxTemperature <= 0 {
+		l.MaxTemperature = 2
+	}
+	if l.MaxStop <= 0 {
+		l.MaxStop = 4
+	}
+
+	var problems []string
+	if options.Model != "" && !slices.Contains(l.Models, options.Model) {
+		problems = append(problems, fmt.Sprintf("model %q is not allowed", options.Model))
+	}
+	for _, param := range options.Parameters() {
+		if !capabilities.SupportsSampling(param) {
+			problems = append(problems, fmt.Sprintf("%s is not supported by this engine", param))
+		}
+	}
+	if t := options.Temperature; t != nil && (*t < 0 || *t > l.MaxTemperature) {
+		problems = append(problems, fmt.Sprintf("temperature must be between 0 and %g", l.MaxTemperature))
+	}
+	if p := options.TopP; p != nil && (*p <= 0 || *p > 1) {
+		problems = append(problems, "top_p must be above 0 and at most 1")
+	}
+	if n := options.MaxTokens; n != nil && (*n < 1 || (l.MaxTokens > 0 && *n > l.MaxTokens)) {
+		if l.MaxTokens > 0 {
+			problems = append(problems, fmt.Sprintf("max_tokens must be between 1 and %d", l.MaxTokens))
+		} else {
+			problems = append(problems, "max_tokens must be at least 1")
+		}
+	}
+	if len(options.Stop) > l.MaxStop {
+		problems = append(problems, fmt.Sprintf("at most %d stop sequences are allowed", l.MaxStop))
+	}
+	if slices.Contains(options.Stop, "") {
+		problems = append(problems, "stop sequences must not be empty")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidGenerationOptions, strings.Join(problems, ", "))
+	}
+	return nil
+}
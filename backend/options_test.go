@@ -0,0 +1,56 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func float(v float64) *float64 { return &v }
+
+func integer(v int) *int { return &v }
+
+func TestGenerationOptionsMerge(t *testing.T) {
+	defaults := babel.GenerationOptions{Model: "small", Temperature: float(0.7), Stop: []string{"\n\n"}}
+	merged := defaults.Merge(babel.GenerationOptions{Temperature: float(0), MaxTokens: integer(100)})
+
+	// an explicit zero temperature replaces the default
+	require.Equal(t, babel.GenerationOptions{Model: "small", Temperature: float(0), MaxTokens: integer(100), Stop: []string{"\n\n"}}, merged)
+	require.Equal(t, []string{babel.ParamTemperature, babel.ParamMaxTokens, babel.ParamStop}, merged.Parameters())
+	require.Empty(t, babel.GenerationOptions{Model: "small"}.Parameters())
+}
+
+func TestGenerationLimitsCheck(t *testing.T) {
+	all := babel.Capabilities{SamplingParameters: babel.AllSamplingParameters}
+	limits := babel.GenerationLimits{Models: []string{"small", "large"}, MaxTokens: 1000}
+
+	for name, tc := range map[string]struct {
+		options      babel.GenerationOptions
+		capabilities babel.Capabilities
+		problem      string
+	}{
+		"within limits":     {options: babel.GenerationOptions{Model: "large", Temperature: float(0), TopP: float(1), MaxTokens: integer(1000), Seed: integer(-3), Stop: []string{"END"}}, capabilities: all},
+		"unknown model":     {options: babel.GenerationOptions{Model: "huge"}, capabilities: all, problem: `model "huge" is not allowed`},
+		"too hot":           {options: babel.GenerationOptions{Temperature: float(2.5)}, capabilities: all, problem: "temperature must be between 0 and 2"},
+		"top_p zero":        {options: babel.GenerationOptions{TopP: float(0)}, capabilities: all, problem: "top_p must be above 0 and at most 1"},
+		"too long":          {options: babel.GenerationOptions{MaxTokens: integer(1001)}, capabilities: all, problem: "max_tokens must be between 1 and 1000"},
+		"too many stops":    {options: babel.GenerationOptions{Stop: []string{"a", "b", "c", "d", "e"}}, capabilities: all, problem: "at most 4 stop sequences are allowed"},
+		"empty stop":        {options: babel.GenerationOptions{Stop: []string{""}}, capabilities: all, problem: "stop sequences must not be empty"},
+		"unsupported param": {options: babel.GenerationOptions{Seed: integer(1)}, capabilities: babel.Capabilities{SamplingParameters: []string{babel.ParamTemperature}}, problem: "seed is not supported by this engine"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := limits.Check(tc.options, tc.capabilities)
+			if tc.problem == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, babel.ErrInvalidGenerationOptions))
+			require.ErrorContains(t, err, tc.problem)
+		})
+	}
+
+	// without an allowlist the model cannot be changed at all
+	require.Error(t, babel.GenerationLimits{}.Check(babel.GenerationOptions{Model: "small"}, all))
+}
@@ -0,0 +1,348 @@
+package babel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PluginConfig configures an executable serving completions over the plugin protocol.
+//
+// The process is started on the first call and reused for every call after that. Requests are written to its
+// stdin and responses read from its stdout, one JSON object per line, one request at a time:
+//
+//	{"id":"1","model":"llama3","messages":[{"role":"system","content":"..."},{"role":"user","content":"...","metadata":{"operation":"start","targetLanguage":"es"}}]}
+//	{"id":"1","completion":"...","usage":{"promptTokens":12,"completionTokens":5}}
+//	{"id":"1","error":"model not loaded"}
+//
+// Sampling parameters the plugin declares in SamplingParameters are passed as options, e.g.
+//
+//	{"id":"2","messages":[...],"options":{"temperature":0.2,"maxTokens":512,"stop":["\n\n"]}}
+//
+// Roles are system, user and assistant. The content of a message is a single string, messages with several parts are
+// rejected with ErrMultipartUnsupported. Messages carry their Metadata, which says which operation they belong to and
+// the language being translated into; plugins are free to ignore it. The response must echo the id, usage is
+// optional. Anything the process writes to stderr is logged. A process that exits, for example a script answering a
+// single request, is started again for the next call.
+type PluginConfig struct {
+	// Name is used in logs and errors, defaults to the base name of Command
+	Name    string
+	Command string
+	Args    []string
+	// Env is added to the environment inherited from this process
+	Env []string
+	// Dir is the working directory, defaults to the current one
+	Dir string
+	// Model is passed through in every request, empty when the plugin does not need it
+	Model string
+	// ContextLength is the model's context window in tokens, zero when unknown
+	ContextLength int
+	// SamplingParameters the plugin honours, see AllSamplingParameters. Requests setting others are rejected.
+	SamplingParameters []string
+	// Options are the default generation options, requests may override them
+	Options GenerationOptions
+	// Timeout bounds a single request, defaults to 2m. The process is killed when it expires, as it can no longer
+	// be trusted to answer the next request in order.
+	Timeout time.Duration
+}
+
+// PluginAISystem is an AISystem backed by an external executable, for example a llama.cpp wrapper or a script.
+type PluginAISystem struct {
+	config PluginConfig
+
+	mu     sync.Mutex
+	proc   *pluginProcess
+	nextID int
+}
+
+type pluginProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+	done   chan struct{}
+}
+
+type pluginRequest struct {
+	ID       string          `json:"id"`
+	Model    string          `json:"model,omitempty"`
+	Messages []pluginMessage `json:"messages"`
+	Options  *pluginOptions  `json:"options,omitempty"`
+}
+
+// pluginOptions are the sampling parameters of GenerationOptions, the model is sent on its own.
+type pluginOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	MaxTokens   *int     `json:"maxTokens,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type pluginMessage struct {
+	Role     string            `json:"role"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type pluginResponse struct {
+	ID         string `json:"id"`
+	Completion string `json:"completion"`
+	Error      string `json:"error"`
+	Usage      *Usage `json:"usage"`
+}
+
+// NewPluginAISystem creates a plugin backend. The executable is not started until the first call.
+func NewPluginAISystem(config PluginConfig) *PluginAISystem {
+	if config.Name == "" {
+		config.Name = filepath.Base(config.Command)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Minute
+	}
+	return &PluginAISystem{config: config}
+}
+
+// Capabilities reports the configured context length and sampling parameters, the plugin cannot be asked.
+func (p *PluginAISystem) Capabilities() Capabilities {
+	params := p.config.SamplingParameters
+	if params == nil {
+		params = []string{}
+	}
+	return Capabilities{ContextLength: p.config.ContextLength, SamplingParameters: params}
+}
+
+// ListModels returns the configured model, nil when the plugin is not given one.
+func (p *PluginAISystem) ListModels(context.Context) ([]ModelInfo, error) {
+	if p.config.Model == "" {
+		return nil, nil
+	}
+	return []ModelInfo{{Name: p.config.Model, Capabilities: p.Capabilities()}}, nil
+}
+
+// HealthCheck checks that the executable can be found. The plugin itself is not asked, it could only answer with a
+// completion.
+func (p *PluginAISystem) HealthCheck(context.Context) error {
+	command := p.config.Command
+	if p.config.Dir != "" && !filepath.IsAbs(command) && strings.ContainsRune(command, filepath.Separator) {
+		command = filepath.Join(p.config.Dir, command)
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("plugin %s: %w", p.config.Name, err)
+	}
+	return nil
+}
+
+func (p *PluginAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	pluginMessages := make([]pluginMessage, 0, len(messages))
+	for _, m := range messages {
+		text, err := singleText(m)
+		if err != nil {
+			return "", fmt.Errorf("plugin %s: %w", p.config.Name, err)
+		}
+		pluginMessages = append(pluginMessages, pluginMessage{Role: string(m.Role), Content: text, Metadata: m.Metadata})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextID++
+	options := generationOptions(ctx, p.config.Options)
+	req := pluginRequest{ID: strconv.Itoa(p.nextID), Model: options.modelOr(p.config.Model), Messages: pluginMessages}
+	if len(options.Parameters()) > 0 {
+		req.Options = &pluginOptions{
+			Temperature: options.Temperature,
+			TopP:        options.TopP,
+			MaxTokens:   options.MaxTokens,
+			Seed:        options.Seed,
+			Stop:        options.Stop,
+		}
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := p.exchange(ctx, line)
+	if errors.Is(err, errPluginExited) {
+		// a single-shot plugin may have exited after its last answer without us noticing yet
+		res, err = p.exchange(ctx, line)
+	}
+	if err != nil {
+		return "", err
+	}
+	if res.ID != req.ID {
+		p.stop()
+		return "", fmt.Errorf("plugin %s: response for request %q, expected %q", p.config.Name, res.ID, req.ID)
+	}
+	if res.Error != "" {
+		return "", fmt.Errorf("plugin %s: %s", p.config.Name, res.Error)
+	}
+	if info := callInfoFromContext(ctx); info != nil && res.Usage != nil {
+		info.Usage = *res.Usage
+	}
+	return res.Completion, nil
+}
+
+// errPluginExited is returned by exchange when a reused process went away before reading the request.
+var errPluginExited = errors.New("plugin process exited")
+
+// exchange writes one request line and waits for the response line. Any failure leaves the process stopped.
+// It must be called with the lock held.
+func (p *PluginAISystem) exchange(ctx context.Context, line []byte) (pluginResponse, error) {
+	var res pluginResponse
+	reused := p.proc != nil
+	proc, err := p.process()
+	if err != nil {
+		return res, err
+	}
+
+	if _, err := proc.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		if reused {
+			return res, errPluginExited
+		}
+		return res, fmt.Errorf("plugin %s: writing request: %w", p.config.Name, err)
+	}
+
+	type result struct {
+		line []byte
+		err  error
+	}
+	read := make(chan result, 1)
+	go func() {
+		if proc.stdout.Scan() {
+			read <- result{line: append([]byte(nil), proc.stdout.Bytes()...)}
+			return
+		}
+		err := proc.stdout.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		read <- result{err: err}
+	}()
+
+	timer := time.NewTimer(p.config.Timeout)
+	defer timer.Stop()
+	var r result
+	select {
+	case r = <-read:
+	case <-timer.C:
+		p.stop()
+		return res, fmt.Errorf("plugin %s: no response within %s: %w", p.config.Name, p.config.Timeout, context.DeadlineExceeded)
+	case <-ctx.Done():
+		p.stop()
+		return res, ctx.Err()
+	}
+	if r.err != nil {
+		p.stop()
+		// Wait closes stdout once the process is gone, so an exit may surface as a closed pipe instead of EOF
+		if reused && (errors.Is(r.err, io.ErrUnexpectedEOF) || errors.Is(r.err, os.ErrClosed)) {
+			return res, errPluginExited
+		}
+		return res, fmt.Errorf("plugin %s: reading response: %w", p.config.Name, r.err)
+	}
+
+	if err := json.Unmarshal(r.line, &res); err != nil {
+		p.stop()
+		return res, fmt.Errorf("plugin %s: decoding response: %w", p.config.Name, err)
+	}
+	return res, nil
+}
+
+// process returns the running process, starting a new one when there is none or the last one exited.
+// It must be called with the lock held.
+func (p *PluginAISystem) process() (*pluginProcess, error) {
+	if p.proc != nil {
+		select {
+		case <-p.proc.done:
+			p.proc = nil
+		default:
+			return p.proc, nil
+		}
+	}
+
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Dir = p.config.Dir
+	cmd.Env = append(os.Environ(), p.config.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &stderrLogger{plugin: p.config.Name}
+	// a child the plugin left behind may hold stderr open, do not wait for it forever
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: starting: %w", p.config.Name, err)
+	}
+	slog.Info("plugin started", "plugin", p.config.Name, "pid", cmd.Process.Pid)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	proc := &pluginProcess{cmd: cmd, stdin: stdin, stdout: scanner, done: make(chan struct{})}
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("plugin exited", "plugin", p.config.Name, "pid", cmd.Process.Pid, "error", err)
+		} else {
+			slog.Info("plugin exited", "plugin", p.config.Name, "pid", cmd.Process.Pid)
+		}
+		close(proc.done)
+	}()
+
+	p.proc = proc
+	return proc, nil
+}
+
+// stop kills the current process. It must be called with the lock held.
+func (p *PluginAISystem) stop() {
+	if p.proc == nil {
+		return
+	}
+	_ = p.proc.stdin.Close()
+	_ = p.proc.cmd.Process.Kill()
+	<-p.proc.done
+	p.proc = nil
+}
+
+// Close stops the plugin process, if one is running.
+func (p *PluginAISystem) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	return nil
+}
+
+// stderrLogger logs every complete line written to it.
+type stderrLogger struct {
+	plugin  string
+	pending []byte
+}
+
+func (l *stderrLogger) Write(b []byte) (int, error) {
+	l.pending = append(l.pending, b...)
+	for {
+		i := bytes.IndexByte(l.pending, '\n')
+		if i < 0 {
+			break
+		}
+		slog.Info("plugin stderr", "plugin", l.plugin, "line", string(bytes.TrimRight(l.pending[:i], "\r")))
+		l.pending = l.pending[i+1:]
+	}
+	return len(b), nil
+}
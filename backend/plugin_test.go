@@ -0,0 +1,224 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+type pluginMessage struct {
+	Role     string            `json:"role"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// TestPluginHelperProcess is not a real test, it is the plugin executable launched by the plugin tests.
+// It answers with its pid and the last message, and misbehaves on request.
+func TestPluginHelperProcess(t *testing.T) {
+	if os.Getenv("BABEL_PLUGIN_HELPER") != "1" {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "helper ready")
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var req struct {
+			ID       string          `json:"id"`
+			Model    string          `json:"model"`
+			Messages []pluginMessage `json:"messages"`
+			Options  json.RawMessage `json:"options"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		last := req.Messages[len(req.Messages)-1].Content
+		switch {
+		case last == "sleep":
+			time.Sleep(time.Minute)
+		case last == "fail":
+			_ = json.NewEncoder(os.Stdout).Encode(map[string]string{"id": req.ID, "error": "cannot do that"})
+			continue
+		case last == "crash":
+			os.Exit(1)
+		case last == "options":
+			_ = json.NewEncoder(os.Stdout).Encode(map[string]string{"id": req.ID, "completion": req.Model + " " + string(req.Options)})
+			continue
+		case last == "echo":
+			messages, _ := json.Marshal(req.Messages)
+			_ = json.NewEncoder(os.Stdout).Encode(map[string]string{"id": req.ID, "completion": string(messages)})
+			continue
+		}
+		_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+			"id":         req.ID,
+			"completion": fmt.Sprintf("%d %s %s", os.Getpid(), req.Model, last),
+			"usage":      map[string]int{"promptTokens": len(req.Messages), "completionTokens": 1},
+		})
+		if last == "once" {
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+func newTestPlugin(t *testing.T, timeout time.Duration) *babel.PluginAISystem {
+	t.Helper()
+	p := babel.NewPluginAISystem(babel.PluginConfig{
+		Name:    "helper",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestPluginHelperProcess$"},
+		Env:     []string{"BABEL_PLUGIN_HELPER=1"},
+		Model:   "test-model",
+		Timeout: timeout,
+	})
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func pluginChat(t *testing.T, p *babel.PluginAISystem, message string) (pid, model, text string, err error) {
+	t.Helper()
+	result, err := p.Chat(context.Background(), []babel.Message{babel.UserMessage(message)})
+	if err != nil {
+		return "", "", "", err
+	}
+	parts := strings.SplitN(result, " ", 3)
+	require.Len(t, parts, 3)
+	return parts[0], parts[1], parts[2], nil
+}
+
+func TestPluginRoundTrip(t *testing.T) {
+	p := newTestPlugin(t, 10*time.Second)
+
+	// plugins get the plain text of every message along with its metadata
+	messages := append(plainConversation[:len(plainConversation):len(plainConversation)], babel.UserMessage("echo"))
+	result, err := p.Chat(context.Background(), messages)
+	require.NoError(t, err)
+
+	var received []pluginMessage
+	require.NoError(t, json.Unmarshal([]byte(result), &received))
+	var roundTripped []babel.Message
+	for _, m := range received {
+		roundTripped = append(roundTripped, babel.Message{Role: babel.Role(m.Role), Parts: []babel.Part{{Text: m.Content}}, Metadata: m.Metadata})
+	}
+	require.Equal(t, messages, roundTripped)
+}
+
+func TestPluginRejectsMultipartMessages(t *testing.T) {
+	p := newTestPlugin(t, 10*time.Second)
+
+	_, err := p.Chat(context.Background(), conversation)
+	require.ErrorIs(t, err, babel.ErrMultipartUnsupported)
+}
+
+func TestPluginGenerationOptions(t *testing.T) {
+	p := babel.NewPluginAISystem(babel.PluginConfig{
+		Command:            os.Args[0],
+		Args:               []string{"-test.run=^TestPluginHelperProcess$"},
+		Env:                []string{"BABEL_PLUGIN_HELPER=1"},
+		Model:              "test-model",
+		SamplingParameters: []string{babel.ParamTemperature, babel.ParamMaxTokens},
+		Options:            babel.GenerationOptions{MaxTokens: integer(100)},
+	})
+	t.Cleanup(func() { _ = p.Close() })
+	require.Equal(t, []string{babel.ParamTemperature, babel.ParamMaxTokens}, p.Capabilities().SamplingParameters)
+
+	ctx := babel.WithGenerationOptions(context.Background(), babel.GenerationOptions{Model: "other-model", Temperature: float(0)})
+	result, err := p.Chat(ctx, []babel.Message{babel.UserMessage("options")})
+	require.NoError(t, err)
+	require.Equal(t, `other-model {"temperature":0,"maxTokens":100}`, result)
+
+	// plugins without options do not get the field
+	result, err = newTestPlugin(t, 10*time.Second).Chat(context.Background(), []babel.Message{babel.UserMessage("options")})
+	require.NoError(t, err)
+	require.Equal(t, "test-model ", result)
+}
+
+func TestPluginReusesProcess(t *testing.T) {
+	p := newTestPlugin(t, 10*time.Second)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	result, err := p.Chat(ctx, []babel.Message{babel.SystemMessage("rules"), babel.UserMessage("hello")})
+	require.NoError(t, err)
+	require.Equal(t, babel.Usage{PromptTokens: 2, CompletionTokens: 1}, info.Usage)
+	firstPid := strings.Fields(result)[0]
+
+	pid, model, text, err := pluginChat(t, p, "again")
+	require.NoError(t, err)
+	require.Equal(t, firstPid, pid)
+	require.Equal(t, "test-model", model)
+	require.Equal(t, "again", text)
+
+	// an error answer keeps the process
+	_, _, _, err = pluginChat(t, p, "fail")
+	require.ErrorContains(t, err, "plugin helper: cannot do that")
+	pid, _, _, err = pluginChat(t, p, "still there")
+	require.NoError(t, err)
+	require.Equal(t, firstPid, pid)
+}
+
+func TestPluginTimeoutRestartsProcess(t *testing.T) {
+	p := newTestPlugin(t, 500*time.Millisecond)
+
+	firstPid, _, _, err := pluginChat(t, p, "hello")
+	require.NoError(t, err)
+
+	_, _, _, err = pluginChat(t, p, "sleep")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	pid, _, text, err := pluginChat(t, p, "hello")
+	require.NoError(t, err)
+	require.NotEqual(t, firstPid, pid)
+	require.Equal(t, "hello", text)
+}
+
+func TestPluginRestartsExitedProcess(t *testing.T) {
+	p := newTestPlugin(t, 10*time.Second)
+
+	firstPid, _, _, err := pluginChat(t, p, "once")
+	require.NoError(t, err)
+	pid, _, _, err := pluginChat(t, p, "hello")
+	require.NoError(t, err)
+	require.NotEqual(t, firstPid, pid)
+
+	_, _, _, err = pluginChat(t, p, "crash")
+	require.Error(t, err)
+	_, _, _, err = pluginChat(t, p, "hello")
+	require.NoError(t, err)
+}
+
+func TestPluginLogsStderr(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	p := newTestPlugin(t, 10*time.Second)
+	_, _, err := babel.NewBabel(p).NewTranslation(context.Background(), "Hello", language.Spanish)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	require.Contains(t, logs.String(), `msg="plugin stderr" plugin=helper line="helper ready"`)
+}
+
+func TestPluginMissingExecutable(t *testing.T) {
+	p := babel.NewPluginAISystem(babel.PluginConfig{Command: "/does/not/exist"})
+	_, err := p.Chat(context.Background(), []babel.Message{babel.UserMessage("hello")})
+	require.ErrorContains(t, err, "plugin exist: starting")
+}
+
+func TestPluginHealthCheck(t *testing.T) {
+	p := newTestPlugin(t, 10*time.Second)
+	require.NoError(t, p.HealthCheck(context.Background()))
+
+	missing := babel.NewPluginAISystem(babel.PluginConfig{Command: "/does/not/exist"})
+	require.ErrorContains(t, missing.HealthCheck(context.Background()), "plugin exist")
+}
@@ -0,0 +1,346 @@
+package babel
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecker is implemented by backends that can answer a cheap health probe without running a completion.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type affinityKey struct{}
+
+// WithAffinityKey marks calls made with the returned context as belonging together, so that pools keeping
+// affinity send them to the same endpoint. Translation contexts set this for their own calls.
+func WithAffinityKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+func affinityFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(affinityKey{}).(string)
+	return key
+}
+
+// newAffinityKey returns a random key for a new translation context.
+func newAffinityKey() string {
+	return rand.Text()
+}
+
+// PoolPolicy decides how PoolAISystem spreads requests over its endpoints.
+type PoolPolicy string
+
+const (
+	PoolRoundRobin       PoolPolicy = "round-robin"
+	PoolLeastOutstanding PoolPolicy = "least-outstanding"
+)
+
+// PoolEndpoint is a single host taking part in a PoolAISystem. Backends implementing HealthChecker are probed
+// with it, others with a minimal completion.
+type PoolEndpoint struct {
+	Name    string
+	Backend AISystem
+}
+
+// PoolConfig controls request distribution and health checking in PoolAISystem.
+type PoolConfig struct {
+	// Policy defaults to PoolLeastOutstanding
+	Policy PoolPolicy
+	// Affinity keeps all calls of a translation context on the same endpoint while it stays healthy
+	Affinity bool
+	// FailureThreshold is the number of consecutive failures that ejects an endpoint, default 3
+	FailureThreshold int
+	// HealthInterval is the time between health probes, default 10s. Negative disables active health checks.
+	HealthInterval time.Duration
+	// HealthTimeout bounds a single health probe, default 5s
+	HealthTimeout time.Duration
+}
+
+type poolEndpoint struct {
+	PoolEndpoint
+
+	outstanding atomic.Int64
+	mu          sync.Mutex
+	failures    int
+	ejected     bool
+}
+
+// PoolAISystem spreads requests across several equivalent endpoints, e.g. a fleet of Ollama hosts serving the same
+// model. Failing endpoints are ejected and re-admitted once their health probes succeed again. Client errors are
+// returned as they are, without trying another endpoint or counting against this one.
+type PoolAISystem struct {
+	endpoints []*poolEndpoint
+	config    PoolConfig
+	next      atomic.Uint64
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+// NewPoolAISystem builds a pool over endpoints and starts its health checker. Call Close to stop it.
+func NewPoolAISystem(config PoolConfig, endpoints ...PoolEndpoint) *PoolAISystem {
+	if config.Policy == "" {
+		config.Policy = PoolLeastOutstanding
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.HealthInterval == 0 {
+		config.HealthInterval = 10 * time.Second
+	}
+	if config.HealthTimeout <= 0 {
+		config.HealthTimeout = 5 * time.Second
+	}
+
+	p := &PoolAISystem{
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, e := range endpoints {
+		p.endpoints = append(p.endpoints, &poolEndpoint{PoolEndpoint: e})
+	}
+
+	if config.HealthInterval > 0 {
+		go p.healthLoop()
+	} else {
+		close(p.done)
+	}
+	return p
+}
+
+func (p *PoolAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return p.chat(ctx, messages, nil)
+}
+
+// ChatStream streams from the picked endpoint. Once text was delivered no other endpoint is tried.
+func (p *PoolAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return p.chat(ctx, messages, onDelta)
+}
+
+// Capabilities is what every endpoint supports, as any of them may end up serving a call.
+func (p *PoolAISystem) Capabilities() Capabilities {
+	systems := make([]AISystem, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		systems = append(systems, e.Backend)
+	}
+	return capabilitiesOf(systems...)
+}
+
+func (p *PoolAISystem) chat(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	tried := make(map[*poolEndpoint]bool)
+	var errs []error
+	for len(tried) < len(p.endpoints) {
+		e := p.pick(ctx, tried)
+		if e == nil {
+			break
+		}
+		tried[e] = true
+
+		var started bool
+		e.outstanding.Add(1)
+		result, err := chatStream(ctx, e.Backend, messages, trackDelta(onDelta, &started))
+		e.outstanding.Add(-1)
+		if err == nil {
+			e.recordSuccess()
+			if info := callInfoFromContext(ctx); info != nil {
+				info.Endpoint = e.Name
+			}
+			return result, nil
+		}
+
+		if ctx.Err() != nil {
+			return "", err
+		}
+		// a bad request would fail on every endpoint alike, and says nothing about the health of this one
+		if !failsOver(err) {
+			return "", fmt.Errorf("%s: %w", e.Name, err)
+		}
+		if started {
+			e.recordFailure(p.config.FailureThreshold)
+			return "", fmt.Errorf("%s: %w", e.Name, err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e.Name, err))
+		if e.recordFailure(p.config.FailureThreshold) {
+			slog.Warn("pool endpoint ejected", "endpoint", e.Name, "error", err)
+		} else {
+			slog.Warn("pool endpoint failed, trying another", "endpoint", e.Name, "error", err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return "", ErrNoBackends
+	}
+	return "", fmt.Errorf("all pool endpoints failed: %w", errors.Join(errs...))
+}
+
+// pick selects the next endpoint not yet tried, preferring admitted endpoints over ejected ones.
+func (p *PoolAISystem) pick(ctx context.Context, tried map[*poolEndpoint]bool) *poolEndpoint {
+	var admitted, ejected []*poolEndpoint
+	for _, e := range p.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.isEjected() {
+			ejected = append(ejected, e)
+		} else {
+			admitted = append(admitted, e)
+		}
+	}
+	candidates := admitted
+	if len(candidates) == 0 {
+		// everything is ejected, a request is still better than failing outright
+		candidates = ejected
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if key := affinityFromContext(ctx); p.config.Affinity && key != "" {
+		return rendezvous(key, candidates)
+	}
+
+	offset := int(p.next.Add(1) - 1)
+	if p.config.Policy == PoolRoundRobin {
+		return candidates[offset%len(candidates)]
+	}
+
+	// least outstanding requests, ties are broken round-robin
+	var best *poolEndpoint
+	for i := range candidates {
+		e := candidates[(offset+i)%len(candidates)]
+		if best == nil || e.outstanding.Load() < best.outstanding.Load() {
+			best = e
+		}
+	}
+	return best
+}
+
+// rendezvous picks the endpoint with the highest hash for key, so a key only moves when its endpoint goes away.
+func rendezvous(key string, candidates []*poolEndpoint) *poolEndpoint {
+	var best *poolEndpoint
+	var bestScore uint64
+	for _, e := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(e.Name))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best
+}
+
+func (p *PoolAISystem) healthLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.config.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkHealth()
+		}
+	}
+}
+
+// checkHealth probes every endpoint once, ejecting failing ones and re-admitting recovered ones.
+func (p *PoolAISystem) checkHealth() {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.HealthTimeout)
+			defer cancel()
+			err := probe(ctx, e.Backend)
+			if err == nil {
+				if e.recordSuccess() {
+					slog.Info("pool endpoint re-admitted", "endpoint", e.Name)
+				}
+				return
+			}
+			if e.recordFailure(p.config.FailureThreshold) {
+				slog.Warn("pool endpoint ejected by health check", "endpoint", e.Name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// healthCheck runs the health check of system, systems without one are taken as healthy. Readiness probes use it,
+// they must never generate tokens.
+func healthCheck(ctx context.Context, system any) error {
+	if checker, ok := system.(HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// probe runs a health check on system, falling back to a minimal completion. Only the pool uses it, to take hosts
+// that cannot be checked cheaply out of rotation.
+func probe(ctx context.Context, system AISystem) error {
+	if checker, ok := system.(HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	_, err := system.Chat(ctx, []Message{
+		SystemMessage("Reply with OK."),
+		UserMessage("ping"),
+	})
+	return err
+}
+
+// Close stops the background health checker.
+func (p *PoolAISystem) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+	return nil
+}
+
+// HealthCheck answers from the background health checks: the pool is healthy while any endpoint is admitted.
+func (p *PoolAISystem) HealthCheck(context.Context) error {
+	for _, e := range p.endpoints {
+		if !e.isEjected() {
+			return nil
+		}
+	}
+	return fmt.Errorf("all %d endpoints are ejected", len(p.endpoints))
+}
+
+func (e *poolEndpoint) isEjected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ejected
+}
+
+// recordSuccess resets the failure count and reports whether the endpoint was re-admitted.
+func (e *poolEndpoint) recordSuccess() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	readmitted := e.ejected
+	e.failures = 0
+	e.ejected = false
+	return readmitted
+}
+
+// recordFailure counts a failure and reports whether it ejected the endpoint.
+func (e *poolEndpoint) recordFailure(threshold int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if !e.ejected && e.failures >= threshold {
+		e.ejected = true
+		return true
+	}
+	return false
+}
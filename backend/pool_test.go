@@ -0,0 +1,149 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func newTestPool(t *testing.T, config babel.PoolConfig, hosts ...*flakyAISystem) *babel.PoolAISystem {
+	t.Helper()
+	var endpoints []babel.PoolEndpoint
+	for i, h := range hosts {
+		endpoints = append(endpoints, babel.PoolEndpoint{Name: string(rune('a' + i)), Backend: h})
+	}
+	pool := babel.NewPoolAISystem(config, endpoints...)
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	a := &flakyAISystem{mock: babel.NewMockAISystem()}
+	b := &flakyAISystem{mock: babel.NewMockAISystem()}
+	pool := newTestPool(t, babel.PoolConfig{Policy: babel.PoolRoundRobin, HealthInterval: -1}, a, b)
+
+	backend := babel.NewBabel(pool)
+	for i := 0; i < 10; i++ {
+		_, err := backend.IdentifyLanguage(context.Background(), "Hello.")
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 5, a.calls.Load())
+	require.EqualValues(t, 5, b.calls.Load())
+}
+
+func TestPoolLeastOutstanding(t *testing.T) {
+	a := &flakyAISystem{mock: babel.NewMockAISystemWithDelay(200 * time.Millisecond)}
+	b := &flakyAISystem{mock: babel.NewMockAISystem()}
+	pool := newTestPool(t, babel.PoolConfig{Policy: babel.PoolLeastOutstanding, HealthInterval: -1}, a, b)
+	backend := babel.NewBabel(pool)
+
+	// keep one request outstanding on whichever endpoint gets it first
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = backend.IdentifyLanguage(context.Background(), "Hello.")
+	}()
+	require.Eventually(t, func() bool { return a.calls.Load()+b.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	busy, idle := a, b
+	if b.calls.Load() == 1 {
+		busy, idle = b, a
+	}
+	for i := 0; i < 3; i++ {
+		_, err := backend.IdentifyLanguage(context.Background(), "Hello.")
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 1, busy.calls.Load())
+	require.EqualValues(t, 3, idle.calls.Load())
+	<-done
+}
+
+func TestPoolEjectsAndReadmits(t *testing.T) {
+	a := &flakyAISystem{mock: babel.NewMockAISystem()}
+	b := &flakyAISystem{mock: babel.NewMockAISystem()}
+	a.down.Store(true)
+	pool := newTestPool(t, babel.PoolConfig{
+		Policy:           babel.PoolRoundRobin,
+		FailureThreshold: 1,
+		HealthInterval:   20 * time.Millisecond,
+	}, a, b)
+	backend := babel.NewBabel(pool)
+
+	// requests fail over to the healthy endpoint
+	for i := 0; i < 4; i++ {
+		_, err := backend.IdentifyLanguage(context.Background(), "Hello.")
+		require.NoError(t, err)
+	}
+
+	// a is ejected and only receives health probes until it recovers
+	a.down.Store(false)
+	require.Eventually(t, func() bool {
+		before := a.calls.Load()
+		for i := 0; i < 4; i++ {
+			_, _ = backend.IdentifyLanguage(context.Background(), "Hello.")
+		}
+		return a.calls.Load()-before >= 2
+	}, time.Second, 30*time.Millisecond)
+}
+
+func TestPoolAllEndpointsDown(t *testing.T) {
+	a := &flakyAISystem{mock: babel.NewMockAISystem()}
+	a.down.Store(true)
+	pool := newTestPool(t, babel.PoolConfig{HealthInterval: -1}, a)
+
+	_, err := babel.NewBabel(pool).IdentifyLanguage(context.Background(), "Hello.")
+	require.ErrorContains(t, err, "all pool endpoints failed")
+}
+
+func TestPoolAffinityKeepsContextOnHost(t *testing.T) {
+	hosts := []*flakyAISystem{
+		{mock: babel.NewMockAISystem()},
+		{mock: babel.NewMockAISystem()},
+		{mock: babel.NewMockAISystem()},
+	}
+	pool := newTestPool(t, babel.PoolConfig{Policy: babel.PoolRoundRobin, Affinity: true, HealthInterval: -1}, hosts...)
+	backend := babel.NewBabel(pool)
+	ctx := context.Background()
+
+	ctx, info := babel.WithCallInfo(ctx)
+	translationContext, _, err := backend.NewTranslation(ctx, "Hello. I like pizza.", language.Japanese)
+	require.NoError(t, err)
+	host := info.Endpoint
+	require.NotEmpty(t, host)
+
+	for _, feedback := range []string{"Make it more formal", "Add details", "Make it casual"} {
+		ctx, info := babel.WithCallInfo(context.Background())
+		_, err := translationContext.Improve(ctx, feedback)
+		require.NoError(t, err)
+		require.Equal(t, host, info.Endpoint)
+	}
+}
+
+func TestPoolReturnsClientErrors(t *testing.T) {
+	badRequest := &babel.APIError{Provider: "ollama", StatusCode: http.StatusBadRequest, Message: "context length exceeded"}
+	a := &scriptedAISystem{errs: []error{badRequest, badRequest}}
+	b := &scriptedAISystem{}
+	pool := babel.NewPoolAISystem(babel.PoolConfig{Policy: babel.PoolRoundRobin, FailureThreshold: 1, HealthInterval: -1},
+		babel.PoolEndpoint{Name: "a", Backend: a},
+		babel.PoolEndpoint{Name: "b", Backend: b},
+	)
+	t.Cleanup(func() { _ = pool.Close() })
+	messages := []babel.Message{babel.UserMessage("hello")}
+
+	_, err := pool.Chat(context.Background(), messages)
+	require.ErrorIs(t, err, badRequest)
+	require.Zero(t, b.callCount(), "the request would fail on any endpoint")
+
+	// round robin moves on to b and back to a, which was not ejected by the bad request
+	_, err = pool.Chat(context.Background(), messages)
+	require.NoError(t, err)
+	_, err = pool.Chat(context.Background(), messages)
+	require.ErrorIs(t, err, badRequest)
+	require.Equal(t, 2, a.callCount())
+	require.Equal(t, 1, b.callCount())
+}
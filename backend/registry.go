@@ -0,0 +1,265 @@
+package babel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SettingType is the kind of value a Setting holds. Values are checked against it before an engine is built.
+type SettingType string
+
+const (
+	SettingString   SettingType = "string"
+	SettingInt      SettingType = "int"
+	SettingFloat    SettingType = "float"
+	SettingBool     SettingType = "bool"
+	SettingDuration SettingType = "duration"
+	SettingURL      SettingType = "url"
+	SettingJSON     SettingType = "json"
+)
+
+// Setting describes one environment variable an engine is configured with.
+type Setting struct {
+	// Name of the variable, e.g. OPENAI_API_KEY
+	Name string
+	// Type defaults to SettingString
+	Type        SettingType
+	Description string
+	Default     string
+	Required    bool
+	// Values restricts the setting to a fixed set when not empty
+	Values []string
+}
+
+// Settings are the validated values of an engine's settings, with defaults applied. The typed getters return
+// the zero value for unset settings.
+type Settings struct {
+	values map[string]string
+}
+
+func (s Settings) String(name string) string {
+	return s.values[name]
+}
+
+func (s Settings) Int(name string) int {
+	v, _ := strconv.Atoi(s.values[name])
+	return v
+}
+
+func (s Settings) Float(name string) float64 {
+	v, _ := strconv.ParseFloat(s.values[name], 64)
+	return v
+}
+
+func (s Settings) Bool(name string) bool {
+	v, _ := strconv.ParseBool(s.values[name])
+	return v
+}
+
+func (s Settings) Duration(name string) time.Duration {
+	v, _ := time.ParseDuration(s.values[name])
+	return v
+}
+
+// JSON decodes the setting into v, leaving v untouched when the setting is unset.
+func (s Settings) JSON(name string, v any) error {
+	raw := s.values[name]
+	if raw == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Engine is a backend that can be selected by name, e.g. ENGINE=openai. Exactly one of New and NewTranslator
+// is set: chat engines serve every operation, machine translation engines only translate. The context passed to New
+// bounds the work done up front, such as pulling a model.
+type Engine struct {
+	Name        string
+	Description string
+	Settings    []Setting
+	// ModelSetting names the setting replaced by the model of an engine/model spec, e.g. OPENAI_MODEL. Specs
+	// naming a model are rejected for engines without one.
+	ModelSetting  string
+	New           func(ctx context.Context, settings Settings) (AISystem, error)
+	NewTranslator func(settings Settings) (MachineTranslator, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Engine)
+)
+
+// RegisterEngine makes an engine available by name. Packages providing engines call it from init, so that a
+// blank import of the package is enough to enable them. It panics when the name is taken or the engine does
+// not have exactly one factory.
+func RegisterEngine(engine Engine) {
+	if engine.Name == "" || strings.ContainsAny(engine.Name, ",/;=") {
+		panic(fmt.Sprintf("babel: invalid engine name %q", engine.Name))
+	}
+	if (engine.New == nil) == (engine.NewTranslator == nil) {
+		panic(fmt.Sprintf("babel: engine %q must set exactly one of New and NewTranslator", engine.Name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[engine.Name]; ok {
+		panic(fmt.Sprintf("babel: engine %q registered twice", engine.Name))
+	}
+	registry[engine.Name] = engine
+}
+
+// Engines returns every registered engine sorted by name.
+func Engines() []Engine {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	engines := make([]Engine, 0, len(registry))
+	for _, engine := range registry {
+		engines = append(engines, engine)
+	}
+	sort.Slice(engines, func(i, j int) bool { return engines[i].Name < engines[j].Name })
+	return engines
+}
+
+// LookupEngine returns the engine registered under name.
+func LookupEngine(name string) (Engine, error) {
+	registryMu.RLock()
+	engine, ok := registry[name]
+	registryMu.RUnlock()
+	if ok {
+		return engine, nil
+	}
+	var names []string
+	for _, e := range Engines() {
+		names = append(names, e.Name)
+	}
+	if name == "" {
+		return Engine{}, fmt.Errorf("no engine set, must be one of %s", strings.Join(names, ", "))
+	}
+	return Engine{}, fmt.Errorf("unknown engine %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// LoadSettings reads the engine's settings with lookup, usually os.LookupEnv, and reports every missing or invalid
+// value at once. A non-empty model replaces the value of the ModelSetting.
+func (e Engine) LoadSettings(model string, lookup func(string) (string, bool)) (Settings, error) {
+	values := make(map[string]string, len(e.Settings))
+	var errs []error
+	if model != "" && e.ModelSetting == "" {
+		errs = append(errs, fmt.Errorf("engine %s does not take a model", e.Name))
+	}
+	for _, setting := range e.Settings {
+		value, ok := lookup(setting.Name)
+		if setting.Name == e.ModelSetting && model != "" {
+			value, ok = model, true
+		}
+		if !ok || value == "" {
+			if setting.Required {
+				errs = append(errs, fmt.Errorf("%s not set", setting.Name))
+				continue
+			}
+			value = setting.Default
+		}
+		if value != "" {
+			if err := setting.check(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", setting.Name, err))
+				continue
+			}
+		}
+		values[setting.Name] = value
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Settings{}, err
+	}
+	return Settings{values: values}, nil
+}
+
+func (s Setting) check(value string) error {
+	if len(s.Values) > 0 && !slices.Contains(s.Values, value) {
+		return fmt.Errorf("%q must be one of %s", value, strings.Join(s.Values, ", "))
+	}
+	switch s.Type {
+	case SettingInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+	case SettingFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case SettingBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case SettingDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%q is not a duration like 30s or 5m", value)
+		}
+	case SettingURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute URL", value)
+		}
+	case SettingJSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("%q is not valid JSON", value)
+		}
+	}
+	return nil
+}
+
+// loadEngine looks up a chat engine, or a machine translation engine when translator is set, and loads its settings.
+func loadEngine(name, model string, translator bool, lookup func(string) (string, bool)) (Engine, Settings, error) {
+	engine, err := LookupEngine(name)
+	if err != nil {
+		return Engine{}, Settings{}, err
+	}
+	if translator && engine.NewTranslator == nil {
+		return Engine{}, Settings{}, fmt.Errorf("engine %s is not a machine translation engine", name)
+	}
+	if !translator && engine.New == nil {
+		return Engine{}, Settings{}, fmt.Errorf("engine %s is a machine translation engine and cannot be used here", name)
+	}
+	settings, err := engine.LoadSettings(model, lookup)
+	return engine, settings, err
+}
+
+// ValidateAISystem checks that name is a registered chat engine whose settings are all valid, without building it.
+func ValidateAISystem(name, model string, lookup func(string) (string, bool)) error {
+	_, _, err := loadEngine(name, model, false, lookup)
+	return err
+}
+
+// ValidateMachineTranslator checks that name is a registered machine translation engine whose settings are all
+// valid, without building it.
+func ValidateMachineTranslator(name string, lookup func(string) (string, bool)) error {
+	_, _, err := loadEngine(name, "", true, lookup)
+	return err
+}
+
+// NewAISystem builds the chat engine registered under name, reading its settings with lookup. A non-empty model
+// overrides the engine's model setting. ctx bounds building the engine, not its use.
+func NewAISystem(ctx context.Context, name, model string, lookup func(string) (string, bool)) (AISystem, error) {
+	engine, settings, err := loadEngine(name, model, false, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return engine.New(ctx, settings)
+}
+
+// NewMachineTranslator builds the machine translation engine registered under name, reading its settings with lookup.
+func NewMachineTranslator(name string, lookup func(string) (string, bool)) (MachineTranslator, error) {
+	engine, settings, err := loadEngine(name, "", true, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return engine.NewTranslator(settings)
+}
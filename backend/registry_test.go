@@ -0,0 +1,150 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+// lookupMap returns a lookup function reading from values instead of the environment.
+func lookupMap(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+// echoSystem answers with the settings it was built with.
+type echoSystem struct {
+	model   string
+	timeout time.Duration
+}
+
+func (e echoSystem) Capabilities() babel.Capabilities { return babel.Capabilities{} }
+
+func (e echoSystem) Chat(context.Context, []babel.Message) (string, error) {
+	return e.model + " " + e.timeout.String(), nil
+}
+
+func init() {
+	babel.RegisterEngine(babel.Engine{
+		Name:        "registry-test",
+		Description: "registered by the registry tests",
+		Settings: []babel.Setting{
+			{Name: "TEST_KEY", Required: true},
+			{Name: "TEST_MODEL", Default: "small"},
+			{Name: "TEST_TIMEOUT", Type: babel.SettingDuration, Default: "5s"},
+			{Name: "TEST_MODE", Values: []string{"fast", "slow"}},
+			{Name: "TEST_URL", Type: babel.SettingURL},
+			{Name: "TEST_TEMPERATURE", Type: babel.SettingFloat},
+		},
+		ModelSetting: "TEST_MODEL",
+		New: func(_ context.Context, s babel.Settings) (babel.AISystem, error) {
+			return echoSystem{model: s.String("TEST_MODEL"), timeout: s.Duration("TEST_TIMEOUT")}, nil
+		},
+	})
+}
+
+func TestRegistryBuildsEngine(t *testing.T) {
+	system, err := babel.NewAISystem(context.Background(), "registry-test", "", lookupMap(map[string]string{"TEST_KEY": "k"}))
+	require.NoError(t, err)
+	result, err := system.Chat(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "small 5s", result)
+
+	// the model of an engine/model spec replaces the model setting
+	system, err = babel.NewAISystem(context.Background(), "registry-test", "large", lookupMap(map[string]string{"TEST_KEY": "k", "TEST_MODEL": "medium", "TEST_TIMEOUT": "1m"}))
+	require.NoError(t, err)
+	result, err = system.Chat(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "large 1m0s", result)
+}
+
+func TestRegistryReportsEveryProblem(t *testing.T) {
+	err := babel.ValidateAISystem("registry-test", "", lookupMap(map[string]string{
+		"TEST_TIMEOUT":     "soon",
+		"TEST_MODE":        "medium",
+		"TEST_URL":         "localhost",
+		"TEST_TEMPERATURE": "warm",
+	}))
+	require.ErrorContains(t, err, "TEST_KEY not set")
+	require.ErrorContains(t, err, `invalid TEST_TIMEOUT: "soon" is not a duration`)
+	require.ErrorContains(t, err, `invalid TEST_MODE: "medium" must be one of fast, slow`)
+	require.ErrorContains(t, err, `invalid TEST_URL: "localhost" is not an absolute URL`)
+	require.ErrorContains(t, err, `invalid TEST_TEMPERATURE: "warm" is not a number`)
+
+	err = babel.ValidateAISystem("registry-test", "", lookupMap(map[string]string{"TEST_KEY": "k"}))
+	require.NoError(t, err)
+}
+
+func TestRegistryLookup(t *testing.T) {
+	var names []string
+	for _, engine := range babel.Engines() {
+		names = append(names, engine.Name)
+	}
+	require.IsIncreasing(t, names)
+	require.Subset(t, names, []string{"mock", "openai", "ollama", "cohere", "anthropic", "gemini", "plugin", "deepl", "libretranslate", "registry-test"})
+
+	_, err := babel.NewAISystem(context.Background(), "bogus", "", lookupMap(nil))
+	require.ErrorContains(t, err, `unknown engine "bogus", must be one of anthropic, cohere`)
+
+	// machine translation engines cannot stand in for chat engines and the other way around
+	_, err = babel.NewAISystem(context.Background(), "deepl", "", lookupMap(map[string]string{"DEEPL_API_KEY": "k"}))
+	require.ErrorContains(t, err, "machine translation engine")
+	_, err = babel.NewMachineTranslator("mock", lookupMap(nil))
+	require.ErrorContains(t, err, "not a machine translation engine")
+
+	err = babel.ValidateAISystem("mock", "some-model", lookupMap(nil))
+	require.ErrorContains(t, err, "engine mock does not take a model")
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	require.Panics(t, func() {
+		babel.RegisterEngine(babel.Engine{Name: "mock", New: func(context.Context, babel.Settings) (babel.AISystem, error) { return nil, nil }})
+	})
+	require.Panics(t, func() {
+		babel.RegisterEngine(babel.Engine{Name: "no-factory"})
+	})
+}
+
+func TestRegisteredMockEngine(t *testing.T) {
+	system, err := babel.NewAISystem(context.Background(), "mock", "", lookupMap(nil))
+	require.NoError(t, err)
+	_, result, err := babel.NewBabel(system).NewTranslation(context.Background(), "Hello. I like pizza.", language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+
+	system, err = babel.NewAISystem(context.Background(), "mock", "", lookupMap(map[string]string{"MOCK_FIXTURES": "testdata/fixtures/pizza.yaml"}))
+	require.NoError(t, err)
+	require.IsType(t, &babel.FixtureAISystem{}, system)
+
+	_, err = babel.NewAISystem(context.Background(), "mock", "", lookupMap(map[string]string{"MOCK_FIXTURES": "testdata/fixtures/missing.yaml"}))
+	require.Error(t, err)
+}
+
+func TestEnginesDeclareGenerationSettings(t *testing.T) {
+	names := func(engine string) []string {
+		e, err := babel.LookupEngine(engine)
+		require.NoError(t, err)
+		var names []string
+		for _, setting := range e.Settings {
+			names = append(names, setting.Name)
+		}
+		return names
+	}
+	for _, engine := range []string{"openai", "ollama", "cohere", "gemini", "plugin"} {
+		prefix := strings.ToUpper(engine)
+		require.Subset(t, names(engine), []string{prefix + "_TEMPERATURE", prefix + "_TOP_P", prefix + "_MAX_TOKENS", prefix + "_SEED", prefix + "_STOP"}, engine)
+	}
+	// the Messages API has no seed
+	require.Contains(t, names("anthropic"), "ANTHROPIC_TEMPERATURE")
+	require.NotContains(t, names("anthropic"), "ANTHROPIC_SEED")
+
+	err := babel.ValidateAISystem("openai", "", lookupMap(map[string]string{"OPENAI_TEMPERATURE": "0.2", "OPENAI_STOP": `["\\n\\n"]`}))
+	require.NoError(t, err)
+}
@@ -0,0 +1,344 @@
+package babel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cohere-ai/cohere-go/v2/core"
+	"github.com/openai/openai-go"
+)
+
+// ErrCircuitOpen is returned by RetryAISystem while its circuit breaker is open and the backend is considered down.
+var ErrCircuitOpen = errors.New("circuit breaker open: backend unavailable")
+
+// RetryConfig controls how RetryAISystem retries failed calls and when its circuit breaker trips.
+// Zero values are replaced with the defaults from DefaultRetryConfig.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per call, including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles on every further attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and any Retry-After hint. Hints longer than this fail the call immediately.
+	MaxDelay time.Duration
+	// FailureThreshold is the number of consecutive failed calls that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a single probe call is let through
+	OpenTimeout time.Duration
+}
+
+// DefaultRetryConfig returns the retry settings used for any zero field in RetryConfig.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	d := DefaultRetryConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = d.MaxAttempts
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = d.BaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = d.MaxDelay
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = d.FailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = d.OpenTimeout
+	}
+	return c
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// RetryAISystem wraps an AISystem with retries using exponential backoff with jitter, honours Retry-After hints
+// and fails fast through a circuit breaker once the wrapped backend keeps failing.
+type RetryAISystem struct {
+	backend AISystem
+	name    string
+	config  RetryConfig
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewRetryAISystem wraps backend with retry and circuit breaker behaviour. The name is used in log messages.
+func NewRetryAISystem(name string, backend AISystem, config RetryConfig) *RetryAISystem {
+	return &RetryAISystem{
+		backend: backend,
+		name:    name,
+		config:  config.withDefaults(),
+	}
+}
+
+func (r *RetryAISystem) Chat(ctx context.Context, messages []Message) (string, error) {
+	return r.chat(ctx, messages, nil)
+}
+
+// ChatStream streams from the wrapped backend. Failures are only retried until the first text was delivered.
+func (r *RetryAISystem) ChatStream(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	return r.chat(ctx, messages, onDelta)
+}
+
+func (r *RetryAISystem) Capabilities() Capabilities {
+	return r.backend.Capabilities()
+}
+
+// Close closes the wrapped backend.
+func (r *RetryAISystem) Close() error {
+	return closeAll(r.backend)
+}
+
+// HealthCheck probes the wrapped backend once, without retries.
+func (r *RetryAISystem) HealthCheck(ctx context.Context) error {
+	return healthCheck(ctx, r.backend)
+}
+
+func (r *RetryAISystem) chat(ctx context.Context, messages []Message, onDelta func(string) error) (string, error) {
+	if err := r.allow(); err != nil {
+		return "", err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		var started bool
+		result, err := chatStream(ctx, r.backend, messages, trackDelta(onDelta, &started))
+		if err == nil {
+			r.recordSuccess()
+			return result, nil
+		}
+		lastErr = err
+
+		// the caller gave up, this says nothing about the health of the backend
+		if ctx.Err() != nil {
+			r.releaseProbe()
+			return "", err
+		}
+		if started {
+			r.recordFailure()
+			return "", err
+		}
+
+		retryable, retryAfter := classifyError(err)
+		if !retryable {
+			r.releaseProbe()
+			return "", err
+		}
+		if attempt == r.config.MaxAttempts {
+			break
+		}
+
+		delay := r.backoff(attempt)
+		if retryAfter > 0 {
+			if retryAfter > r.config.MaxDelay {
+				slog.Warn("backend asked to retry later than allowed, giving up",
+					"backend", r.name, "retryAfter", retryAfter, "maxDelay", r.config.MaxDelay)
+				break
+			}
+			delay = retryAfter
+		}
+
+		slog.Warn("backend call failed, retrying",
+			"backend", r.name, "attempt", attempt, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.releaseProbe()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	r.recordFailure()
+	return "", lastErr
+}
+
+// backoff returns the delay before the retry following the given attempt, using full jitter.
+func (r *RetryAISystem) backoff(attempt int) time.Duration {
+	ceiling := r.config.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > r.config.MaxDelay {
+		ceiling = r.config.MaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// allow reports whether a call may go through the circuit breaker.
+func (r *RetryAISystem) allow() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.state {
+	case breakerOpen:
+		if time.Since(r.openedAt) < r.config.OpenTimeout {
+			return fmt.Errorf("%s: %w", r.name, ErrCircuitOpen)
+		}
+		r.transition(breakerHalfOpen)
+		r.probing = true
+		return nil
+	case breakerHalfOpen:
+		// only a single probe is allowed while half-open
+		if r.probing {
+			return fmt.Errorf("%s: %w", r.name, ErrCircuitOpen)
+		}
+		r.probing = true
+	}
+	return nil
+}
+
+func (r *RetryAISystem) recordSuccess() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = 0
+	r.probing = false
+	if r.state != breakerClosed {
+		r.transition(breakerClosed)
+	}
+}
+
+func (r *RetryAISystem) recordFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures++
+	r.probing = false
+	if r.state == breakerHalfOpen || (r.state == breakerClosed && r.failures >= r.config.FailureThreshold) {
+		r.openedAt = time.Now()
+		r.transition(breakerOpen)
+	}
+}
+
+// releaseProbe frees the half-open probe slot when a call ended without saying anything about backend health.
+func (r *RetryAISystem) releaseProbe() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+}
+
+// transition must be called with the lock held.
+func (r *RetryAISystem) transition(to breakerState) {
+	from := r.state
+	r.state = to
+	if to == breakerOpen {
+		slog.Warn("circuit breaker state changed",
+			"backend", r.name, "from", from.String(), "to", to.String(),
+			"failures", r.failures, "openFor", r.config.OpenTimeout)
+		return
+	}
+	slog.Info("circuit breaker state changed", "backend", r.name, "from", from.String(), "to", to.String())
+}
+
+// classifyError reports whether err is a transient failure worth retrying and any delay requested by the server.
+func classifyError(err error) (bool, time.Duration) {
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		var header http.Header
+		if openaiErr.Response != nil {
+			header = openaiErr.Response.Header
+		}
+		return retryableStatus(openaiErr.StatusCode), parseRetryAfter(header)
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.StatusCode), parseRetryAfter(apiErr.Header)
+	}
+
+	var cohereErr *core.APIError
+	if errors.As(err, &cohereErr) {
+		return retryableStatus(cohereErr.StatusCode), parseRetryAfter(cohereErr.Header)
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+
+	return false, 0
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= 500
+}
+
+// parseRetryAfter reads the Retry-After header in either delay-seconds or HTTP-date form, and the
+// millisecond variant sent by OpenAI compatible servers.
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if v := header.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohere-ai/cohere-go/v2/core"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedAISystem returns the scripted errors in order, then succeeds with "ok".
+type scriptedAISystem struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *scriptedAISystem) Capabilities() babel.Capabilities { return babel.Capabilities{} }
+
+func (s *scriptedAISystem) Chat(ctx context.Context, messages []babel.Message) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return "", err
+	}
+	return "ok", nil
+}
+
+func (s *scriptedAISystem) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func rateLimited(retryAfter string) error {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+	return core.NewAPIError(http.StatusTooManyRequests, header, errors.New("rate limited"))
+}
+
+var fastRetries = babel.RetryConfig{
+	MaxAttempts:      3,
+	BaseDelay:        time.Millisecond,
+	MaxDelay:         50 * time.Millisecond,
+	FailureThreshold: 2,
+	OpenTimeout:      50 * time.Millisecond,
+}
+
+func TestRetryRecoversFromTransientErrors(t *testing.T) {
+	inner := &scriptedAISystem{errs: []error{rateLimited(""), core.NewAPIError(http.StatusBadGateway, nil, errors.New("bad gateway"))}}
+	r := babel.NewRetryAISystem("test", inner, fastRetries)
+
+	result, err := r.Chat(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "ok", result)
+	require.Equal(t, 3, inner.callCount())
+}
+
+func TestRetryDoesNotRetryClientErrors(t *testing.T) {
+	inner := &scriptedAISystem{errs: []error{core.NewAPIError(http.StatusBadRequest, nil, errors.New("bad request"))}}
+	r := babel.NewRetryAISystem("test", inner, fastRetries)
+
+	_, err := r.Chat(context.Background(), nil)
+	require.Error(t, err)
+	require.Equal(t, 1, inner.callCount())
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	config := fastRetries
+	config.MaxDelay = 2 * time.Second
+	inner := &scriptedAISystem{errs: []error{rateLimited("1")}}
+	r := babel.NewRetryAISystem("test", inner, config)
+
+	start := time.Now()
+	_, err := r.Chat(context.Background(), nil)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRetryGivesUpWhenRetryAfterTooLong(t *testing.T) {
+	inner := &scriptedAISystem{errs: []error{rateLimited("120")}}
+	r := babel.NewRetryAISystem("test", inner, fastRetries)
+
+	start := time.Now()
+	_, err := r.Chat(context.Background(), nil)
+	require.Error(t, err)
+	require.Equal(t, 1, inner.callCount())
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestRetryStopsOnContextCancel(t *testing.T) {
+	config := fastRetries
+	config.BaseDelay = time.Second
+	config.MaxDelay = time.Second
+	inner := &scriptedAISystem{errs: []error{rateLimited("1"), rateLimited("1")}}
+	r := babel.NewRetryAISystem("test", inner, config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := r.Chat(ctx, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, inner.callCount())
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	failures := make([]error, 0, 6)
+	for i := 0; i < 6; i++ {
+		failures = append(failures, rateLimited(""))
+	}
+	inner := &scriptedAISystem{errs: failures}
+	r := babel.NewRetryAISystem("test", inner, fastRetries)
+	ctx := context.Background()
+
+	// two calls of three failed attempts each reach the threshold
+	for i := 0; i < 2; i++ {
+		_, err := r.Chat(ctx, nil)
+		require.Error(t, err)
+	}
+	require.Equal(t, 6, inner.callCount())
+
+	// open: fail fast without calling the backend
+	_, err := r.Chat(ctx, nil)
+	require.ErrorIs(t, err, babel.ErrCircuitOpen)
+	require.Equal(t, 6, inner.callCount())
+
+	// after the open timeout a probe is let through and closes the circuit
+	time.Sleep(fastRetries.OpenTimeout + 10*time.Millisecond)
+	result, err := r.Chat(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "ok", result)
+
+	_, err = r.Chat(ctx, nil)
+	require.NoError(t, err)
+}
+
+// TestRetryIsTheOnlyRetrier checks that the SDKs do not retry on their own: every attempt of RetryAISystem is a
+// single request, and no SDK sleeps before giving up.
+func TestRetryIsTheOnlyRetrier(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"message":"overloaded","error":{"message":"overloaded"}}`))
+	}))
+	defer server.Close()
+
+	openaiBackend, err := babel.NewOpenAIBackend(babel.OpenAIConfig{BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+	cohereBackend, err := babel.NewCohereBackend(babel.CohereConfig{APIKey: "test-key", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	for name, backend := range map[string]babel.AISystem{"openai": openaiBackend, "cohere": cohereBackend} {
+		t.Run(name, func(t *testing.T) {
+			hits.Store(0)
+			start := time.Now()
+			_, err := babel.NewRetryAISystem(name, backend, fastRetries).Chat(context.Background(), []babel.Message{babel.UserMessage("hello")})
+			require.ErrorContains(t, err, "overloaded")
+			require.Equal(t, int32(fastRetries.MaxAttempts), hits.Load())
+			require.Less(t, time.Since(start), 500*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package babel
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Route sends translations for matching language pairs to a specific backend or model.
+type Route struct {
+	// Name identifies the route in logs and on the translation context
+	Name string
+	// Sources and Targets list the languages this route serves. An empty list matches any language.
+	Sources []language.Tag
+	Targets []language.Tag
+	Backend AISystem
+}
+
+type compiledRoute struct {
+	Route
+	sources language.Matcher
+	targets language.Matcher
+}
+
+// LanguageRouter picks the AISystem for a translation based on its source and target languages.
+// Routes are checked in order and the first match wins; unmatched pairs go to the fallback backend.
+type LanguageRouter struct {
+	routes   []compiledRoute
+	fallback AISystem
+	// MinConfidence is the lowest language.Matcher confidence accepted as a match, default language.High
+	MinConfidence language.Confidence
+}
+
+// NewLanguageRouter builds a router sending unmatched language pairs to fallback.
+func NewLanguageRouter(fallback AISystem, routes ...Route) *LanguageRouter {
+	r := &LanguageRouter{fallback: fallback, MinConfidence: language.High}
+	for _, route := range routes {
+		c := compiledRoute{Route: route}
+		if len(route.Sources) > 0 {
+			c.sources = language.NewMatcher(route.Sources)
+		}
+		if len(route.Targets) > 0 {
+			c.targets = language.NewMatcher(route.Targets)
+		}
+		r.routes = append(r.routes, c)
+	}
+	return r
+}
+
+// systems returns the fallback and every routed backend.
+func (r *LanguageRouter) systems() []AISystem {
+	systems := []AISystem{r.fallback}
+	for _, route := range r.routes {
+		systems = append(systems, route.Backend)
+	}
+	return systems
+}
+
+// Select returns the name of the matching route and its backend. The name is empty when the fallback is used.
+// An undetermined source language only matches routes that accept any source.
+func (r *LanguageRouter) Select(source, target language.Tag) (string, AISystem) {
+	for _, route := range r.routes {
+		if !r.matches(route.sources, source) || !r.matches(route.targets, target) {
+			continue
+		}
+		return route.Name, route.Backend
+	}
+	return "", r.fallback
+}
+
+func (r *LanguageRouter) matches(m language.Matcher, tag language.Tag) bool {
+	if m == nil {
+		return true
+	}
+	if tag == language.Und {
+		return false
+	}
+	_, _, confidence := m.Match(tag)
+	return confidence >= r.MinConfidence
+}
+
+// ParseRoutes parses a route specification of the form
+//
+//	[sources>]targets=engine[/model];...
+//
+// where sources and targets are comma separated BCP 47 tags or "*" for any language, e.g.
+// "ja,ko=openai/aya-expanse:32b;en>fi=cohere". The build function creates the backend for each engine and model.
+func ParseRoutes(spec string, build func(engine, model string) (AISystem, error)) ([]Route, error) {
+	var routes []Route
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		pair, target, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("route %q: missing '=engine'", rule)
+		}
+
+		var sourceList, targetList string
+		if s, t, ok := strings.Cut(pair, ">"); ok {
+			sourceList, targetList = s, t
+		} else {
+			targetList = pair
+		}
+		sources, err := parseTagList(sourceList)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", rule, err)
+		}
+		targets, err := parseTagList(targetList)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", rule, err)
+		}
+
+		engine, model, _ := strings.Cut(strings.TrimSpace(target), "/")
+		system, err := build(engine, model)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", rule, err)
+		}
+		routes = append(routes, Route{
+			Name:    strings.TrimSpace(rule),
+			Sources: sources,
+			Targets: targets,
+			Backend: system,
+		})
+	}
+	return routes, nil
+}
+
+func parseTagList(list string) ([]language.Tag, error) {
+	list = strings.TrimSpace(list)
+	if list == "" || list == "*" {
+		return nil, nil
+	}
+	var tags []language.Tag
+	for _, s := range strings.Split(list, ",") {
+		tag, err := language.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid language tag %q: %w", s, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
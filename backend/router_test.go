@@ -0,0 +1,102 @@
+package babel_test
+
+import (
+	"BabelBridge/backend"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestLanguageRouterSelect(t *testing.T) {
+	fallback := babel.NewMockAISystem()
+	japanese := babel.NewMockAISystem()
+	finnish := babel.NewMockAISystem()
+
+	router := babel.NewLanguageRouter(fallback,
+		babel.Route{Name: "japanese", Targets: []language.Tag{language.Japanese}, Backend: japanese},
+		babel.Route{Name: "en-fi", Sources: []language.Tag{language.English}, Targets: []language.Tag{language.Finnish}, Backend: finnish},
+	)
+
+	testCases := []struct {
+		name          string
+		source        language.Tag
+		target        language.Tag
+		expectedRoute string
+		expected      babel.AISystem
+	}{
+		{"exact target", language.English, language.Japanese, "japanese", japanese},
+		{"regional variant falls back to base language", language.English, language.MustParse("ja-JP"), "japanese", japanese},
+		{"any source", language.Und, language.Japanese, "japanese", japanese},
+		{"source and target", language.MustParse("en-GB"), language.Finnish, "en-fi", finnish},
+		{"wrong source", language.German, language.Finnish, "", fallback},
+		{"unknown source with source constraint", language.Und, language.Finnish, "", fallback},
+		{"no route", language.English, language.Spanish, "", fallback},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			route, system := router.Select(tc.source, tc.target)
+			require.Equal(t, tc.expectedRoute, route)
+			require.Same(t, tc.expected, system)
+		})
+	}
+}
+
+func TestRouteSticksToTranslationContext(t *testing.T) {
+	fallback := &flakyAISystem{mock: babel.NewMockAISystem()}
+	spanish := &flakyAISystem{mock: babel.NewMockAISystem()}
+	router := babel.NewLanguageRouter(fallback,
+		babel.Route{Name: "spanish", Targets: []language.Tag{language.Spanish}, Backend: spanish},
+	)
+	b := babel.NewBabelWithRouter(router)
+
+	ctx, info := babel.WithCallInfo(context.Background())
+	translationContext, result, err := b.NewTranslationFrom(ctx, "Hello. I like pizza.", language.English, language.Spanish)
+	require.NoError(t, err)
+	require.Equal(t, "Hola. Me gusta la pizza.", result)
+	require.Equal(t, "spanish", translationContext.Route())
+	require.Equal(t, "spanish", info.Route)
+
+	_, err = translationContext.Improve(context.Background(), "Make it more formal")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, spanish.calls.Load())
+	require.EqualValues(t, 0, fallback.calls.Load())
+
+	// identification is not tied to a language pair and uses the fallback
+	_, err = b.IdentifyLanguage(context.Background(), "Hello.")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, fallback.calls.Load())
+}
+
+func TestParseRoutes(t *testing.T) {
+	type built struct{ engine, model string }
+	var calls []built
+	build := func(engine, model string) (babel.AISystem, error) {
+		if engine == "broken" {
+			return nil, errors.New("unknown engine")
+		}
+		calls = append(calls, built{engine, model})
+		return babel.NewMockAISystem(), nil
+	}
+
+	routes, err := babel.ParseRoutes("ja,ko=openai/aya-expanse:32b; en>fi=cohere ;*>de=mock", build)
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+	require.Equal(t, []built{{"openai", "aya-expanse:32b"}, {"cohere", ""}, {"mock", ""}}, calls)
+
+	require.Equal(t, []language.Tag{language.Japanese, language.Korean}, routes[0].Targets)
+	require.Empty(t, routes[0].Sources)
+	require.Equal(t, []language.Tag{language.English}, routes[1].Sources)
+	require.Equal(t, []language.Tag{language.Finnish}, routes[1].Targets)
+	require.Empty(t, routes[2].Sources)
+
+	_, err = babel.ParseRoutes("ja", build)
+	require.Error(t, err)
+	_, err = babel.ParseRoutes("not a tag=openai", build)
+	require.Error(t, err)
+	_, err = babel.ParseRoutes("ja=broken", build)
+	require.ErrorContains(t, err, "unknown engine")
+}
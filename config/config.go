@@ -0,0 +1,596 @@
+// Package config loads the settings of BabelBridge from a YAML or TOML file, overridden by environment variables,
+// and checks all of them, including those of every engine referred to, before anything starts.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	babel "BabelBridge/backend"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the whole configuration of the server. The field names are those of the file, the comments name the
+// environment variables overriding them.
+type Config struct {
+	Server      Server      `json:"server"`
+	Translation Translation `json:"translation"`
+	Requests    Requests    `json:"requests"`
+	Chaos       Chaos       `json:"chaos"`
+	// Engines holds the settings of each engine by name. Keys are the setting names without the engine prefix in
+	// camel or snake case, e.g. baseURL or base_url for OPENAI_BASE_URL, or the full setting name.
+	Engines map[string]map[string]any `json:"engines"`
+
+	env      func(string) (string, bool)
+	settings map[string]string
+}
+
+type Server struct {
+	// Port to listen on, PORT
+	Port int `json:"port"`
+	// SecretKey signs the session cookies, SECRET_KEY. A random key is generated when empty, which logs every
+	// client out on restart.
+	SecretKey string `json:"secretKey"`
+	// SessionTTL and ContextTTL are how long idle sessions and translation contexts live, SESSION_TTL and
+	// CONTEXT_TTL
+	SessionTTL Duration `json:"sessionTTL"`
+	ContextTTL Duration `json:"contextTTL"`
+	// ComparisonTTL is how long a side-by-side comparison can be promoted, COMPARISON_TTL
+	ComparisonTTL Duration  `json:"comparisonTTL"`
+	RateLimit     RateLimit `json:"rateLimit"`
+	// ShutdownDelay keeps serving once /readyz reports shutting down, so that load balancers move away first,
+	// SHUTDOWN_DELAY
+	ShutdownDelay Duration `json:"shutdownDelay"`
+	// DrainTimeout is how long running requests may take to finish on shutdown, DRAIN_TIMEOUT
+	DrainTimeout Duration `json:"drainTimeout"`
+	// ProbeTimeout is how long the engine may take to answer the probe of /readyz, PROBE_TIMEOUT
+	ProbeTimeout Duration `json:"probeTimeout"`
+	// ReloadEndpoint adds POST /admin/reload, RELOAD_ENDPOINT_ENABLED. SIGHUP reloads either way.
+	ReloadEndpoint bool `json:"reloadEndpoint"`
+}
+
+type RateLimit struct {
+	// Enabled turns rate limiting per client IP on, RATE_LIMITING_ENABLED
+	Enabled bool `json:"enabled"`
+	// Session limits /session, RATE_LIMIT_SESSION written like 5/1m
+	Session Rate `json:"session"`
+	// API limits the /api endpoints, RATE_LIMIT_API
+	API Rate `json:"api"`
+}
+
+// Rate allows Limit requests per Period.
+type Rate struct {
+	Limit  int64    `json:"limit"`
+	Period Duration `json:"period"`
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, time.Duration(r.Period))
+}
+
+type Translation struct {
+	// Engine is the engine serving translations, several form a failover chain in order, ENGINE
+	Engine List `json:"engine"`
+	// FailoverAttemptTimeout bounds each attempt of a failover chain, FAILOVER_ATTEMPT_TIMEOUT
+	FailoverAttemptTimeout Duration `json:"failoverAttemptTimeout"`
+	// Routes send languages to other engines, e.g. "ja,ko=openai/aya-expanse:32b", ROUTES
+	Routes string `json:"routes"`
+	// Operations maps an operation to the engine[/model] serving it instead, ENGINE_<OPERATION>
+	Operations map[babel.Operation]string `json:"operations"`
+	// Compare lists the engine[/model] candidates for side-by-side comparison, COMPARE_ENGINES
+	Compare List `json:"compare"`
+	// PostEditor is the engine[/model] applying improvements for machine translation engines, POST_EDITOR
+	PostEditor string `json:"postEditor"`
+}
+
+// Requests bound the generation options clients may send.
+type Requests struct {
+	// AllowedModels may be picked by a request, REQUEST_ALLOWED_MODELS
+	AllowedModels List `json:"allowedModels"`
+	// MaxTemperature, REQUEST_MAX_TEMPERATURE
+	MaxTemperature float64 `json:"maxTemperature"`
+	// MaxTokens caps max_tokens, unbounded when zero, REQUEST_MAX_TOKENS
+	MaxTokens int `json:"maxTokens"`
+	// MaxStop is the number of stop sequences allowed, REQUEST_MAX_STOP
+	MaxStop int `json:"maxStop"`
+}
+
+// Limits returns the requests bounds for babel.
+func (r Requests) Limits() babel.GenerationLimits {
+	return babel.GenerationLimits{
+		Models:         r.AllowedModels,
+		MaxTemperature: r.MaxTemperature,
+		MaxTokens:      r.MaxTokens,
+		MaxStop:        r.MaxStop,
+	}
+}
+
+// Chaos injects faults into every engine, for development only.
+type Chaos struct {
+	// Enabled, CHAOS_ENABLED
+	Enabled bool `json:"enabled"`
+	// Faults injected from the start, CHAOS_CONFIG as JSON
+	Faults babel.ChaosConfig `json:"faults"`
+}
+
+// Duration is a time.Duration written like "30s" or "168h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\" or \"5m\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// List is a list of strings, written as a list or as a single comma separated string.
+type List []string
+
+func (l *List) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*l = splitList(s)
+		return nil
+	}
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("must be a list of strings or a comma separated string")
+	}
+	*l = items
+	return nil
+}
+
+func splitList(s string) List {
+	var items List
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Default returns the configuration without any file or environment.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:          8080,
+			SessionTTL:    Duration(7 * 24 * time.Hour),
+			ContextTTL:    Duration(7 * 24 * time.Hour),
+			ComparisonTTL: Duration(15 * time.Minute),
+			RateLimit: RateLimit{
+				Session: Rate{Limit: 5, Period: Duration(time.Minute)},
+				API:     Rate{Limit: 30, Period: Duration(time.Minute)},
+			},
+			DrainTimeout: Duration(30 * time.Second),
+			ProbeTimeout: Duration(5 * time.Second),
+		},
+		Requests: Requests{MaxTemperature: 2, MaxStop: 4},
+	}
+}
+
+// Load reads the configuration file at path, when not empty, applies the environment read with env, usually
+// os.LookupEnv, and validates the result. Every problem found is reported at once.
+func Load(path string, env func(string) (string, bool)) (*Config, error) {
+	c := Default()
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	c.env = env
+	errs := []error{c.applyEnv(env)}
+	settings, err := c.engineSettings()
+	c.settings = settings
+	errs = append(errs, err, c.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// readFile decodes a YAML, TOML or JSON file by its extension. YAML and TOML go through JSON, so that all formats
+// share the field names and reject unknown fields.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var doc any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("config %s: unsupported format %q, use .yaml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if doc == nil {
+		return nil
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("config %s: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// applyEnv overrides the file with the environment variables that are set and not empty.
+func (c *Config) applyEnv(env func(string) (string, bool)) error {
+	e := &overrides{env: env}
+	e.int("PORT", &c.Server.Port)
+	e.string("SECRET_KEY", &c.Server.SecretKey)
+	e.duration("SESSION_TTL", &c.Server.SessionTTL)
+	e.duration("CONTEXT_TTL", &c.Server.ContextTTL)
+	e.duration("COMPARISON_TTL", &c.Server.ComparisonTTL)
+	e.bool("RATE_LIMITING_ENABLED", &c.Server.RateLimit.Enabled)
+	e.rate("RATE_LIMIT_SESSION", &c.Server.RateLimit.Session)
+	e.rate("RATE_LIMIT_API", &c.Server.RateLimit.API)
+	e.duration("SHUTDOWN_DELAY", &c.Server.ShutdownDelay)
+	e.duration("DRAIN_TIMEOUT", &c.Server.DrainTimeout)
+	e.duration("PROBE_TIMEOUT", &c.Server.ProbeTimeout)
+	e.bool("RELOAD_ENDPOINT_ENABLED", &c.Server.ReloadEndpoint)
+
+	e.list("ENGINE", &c.Translation.Engine)
+	e.duration("FAILOVER_ATTEMPT_TIMEOUT", &c.Translation.FailoverAttemptTimeout)
+	e.string("ROUTES", &c.Translation.Routes)
+	for _, op := range babel.Operations {
+		var spec string
+		e.string("ENGINE_"+strings.ToUpper(string(op)), &spec)
+		if spec != "" {
+			if c.Translation.Operations == nil {
+				c.Translation.Operations = make(map[babel.Operation]string)
+			}
+			c.Translation.Operations[op] = spec
+		}
+	}
+	e.list("COMPARE_ENGINES", &c.Translation.Compare)
+	e.string("POST_EDITOR", &c.Translation.PostEditor)
+
+	e.list("REQUEST_ALLOWED_MODELS", &c.Requests.AllowedModels)
+	e.float("REQUEST_MAX_TEMPERATURE", &c.Requests.MaxTemperature)
+	e.int("REQUEST_MAX_TOKENS", &c.Requests.MaxTokens)
+	e.int("REQUEST_MAX_STOP", &c.Requests.MaxStop)
+
+	e.bool("CHAOS_ENABLED", &c.Chaos.Enabled)
+	e.json("CHAOS_CONFIG", &c.Chaos.Faults)
+	return errors.Join(e.errs...)
+}
+
+// overrides parses environment variables into the fields they override, collecting every problem.
+type overrides struct {
+	env  func(string) (string, bool)
+	errs []error
+}
+
+func (o *overrides) value(name string) (string, bool) {
+	v, ok := o.env(name)
+	if !ok || strings.TrimSpace(v) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(v), true
+}
+
+func (o *overrides) fail(name, v, expected string) {
+	o.errs = append(o.errs, fmt.Errorf("invalid %s %q, must be %s", name, v, expected))
+}
+
+func (o *overrides) string(name string, dst *string) {
+	if v, ok := o.value(name); ok {
+		*dst = v
+	}
+}
+
+func (o *overrides) list(name string, dst *List) {
+	if v, ok := o.value(name); ok {
+		*dst = splitList(v)
+	}
+}
+
+func (o *overrides) int(name string, dst *int) {
+	if v, ok := o.value(name); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			o.fail(name, v, "a whole number")
+			return
+		}
+		*dst = n
+	}
+}
+
+func (o *overrides) float(name string, dst *float64) {
+	if v, ok := o.value(name); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			o.fail(name, v, "a number")
+			return
+		}
+		*dst = f
+	}
+}
+
+func (o *overrides) bool(name string, dst *bool) {
+	if v, ok := o.value(name); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			o.fail(name, v, "true or false")
+			return
+		}
+		*dst = b
+	}
+}
+
+func (o *overrides) duration(name string, dst *Duration) {
+	if v, ok := o.value(name); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			o.fail(name, v, "a duration like 30s or 5m")
+			return
+		}
+		*dst = Duration(d)
+	}
+}
+
+func (o *overrides) rate(name string, dst *Rate) {
+	if v, ok := o.value(name); ok {
+		limit, period, found := strings.Cut(v, "/")
+		n, err := strconv.ParseInt(limit, 10, 64)
+		d, perr := time.ParseDuration(period)
+		if !found || err != nil || perr != nil {
+			o.fail(name, v, "requests per period like 30/1m")
+			return
+		}
+		*dst = Rate{Limit: n, Period: Duration(d)}
+	}
+}
+
+func (o *overrides) json(name string, dst any) {
+	if v, ok := o.value(name); ok {
+		if err := json.Unmarshal([]byte(v), dst); err != nil {
+			o.errs = append(o.errs, fmt.Errorf("invalid %s: %w", name, err))
+		}
+	}
+}
+
+// engineSettings flattens the engines section into setting names, e.g. OPENAI_BASE_URL, with their values as
+// the environment would carry them.
+func (c *Config) engineSettings() (map[string]string, error) {
+	settings := make(map[string]string)
+	var errs []error
+	for _, name := range sortedKeys(c.Engines) {
+		engine, err := babel.LookupEngine(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("engines.%s: %w", name, err))
+			continue
+		}
+		for _, key := range sortedKeys(c.Engines[name]) {
+			setting, ok := findSetting(engine, key)
+			if !ok {
+				errs = append(errs, fmt.Errorf("engines.%s.%s: unknown setting, see --help for the settings of %s", name, key, name))
+				continue
+			}
+			value, err := settingValue(setting, c.Engines[name][key])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("engines.%s.%s: %w", name, key, err))
+				continue
+			}
+			settings[setting.Name] = value
+		}
+	}
+	return settings, errors.Join(errs...)
+}
+
+// findSetting finds the setting of engine a key of the engines section stands for.
+func findSetting(engine babel.Engine, key string) (babel.Setting, bool) {
+	names := []string{key, strings.ToUpper(engine.Name) + "_" + upperSnake(key)}
+	for _, setting := range engine.Settings {
+		for _, name := range names {
+			if setting.Name == name {
+				return setting, true
+			}
+		}
+	}
+	return babel.Setting{}, false
+}
+
+// upperSnake turns baseURL, base_url or base-url into BASE_URL.
+func upperSnake(key string) string {
+	var b strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '.':
+			b.WriteRune('_')
+			continue
+		case i > 0 && r >= 'A' && r <= 'Z' && runes[i-1] >= 'a' && runes[i-1] <= 'z':
+			b.WriteRune('_')
+		}
+		b.WriteString(strings.ToUpper(string(r)))
+	}
+	return b.String()
+}
+
+// settingValue formats a value of the file like the environment variable of setting would hold it. Lists become
+// JSON for JSON settings and comma separated otherwise.
+func settingValue(setting babel.Setting, value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		if setting.Type == babel.SettingJSON {
+			data, err := json.Marshal(v)
+			return string(data), err
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := settingValue(setting, item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		if setting.Type != babel.SettingJSON {
+			return "", errors.New("must not be a table")
+		}
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Lookup reads an engine setting like os.LookupEnv would: the environment wins over the engines section. Like the
+// other overrides, empty environment variables count as unset.
+func (c *Config) Lookup(name string) (string, bool) {
+	if c.env != nil {
+		if v, ok := (&overrides{env: c.env}).value(name); ok {
+			return v, ok
+		}
+	}
+	v, ok := c.settings[name]
+	return v, ok
+}
+
+// MachineTranslation reports whether the engine is a single machine translation engine.
+func (c *Config) MachineTranslation() bool {
+	if len(c.Translation.Engine) != 1 {
+		return false
+	}
+	engine, err := babel.LookupEngine(c.Translation.Engine[0])
+	return err == nil && engine.NewTranslator != nil
+}
+
+// Validate checks the configuration and the settings of every engine it refers to, without building any of them.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(field string, ok bool, problem string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s %s", field, problem))
+		}
+	}
+	check("server.port (PORT)", c.Server.Port > 0 && c.Server.Port < 65536, "must be between 1 and 65535")
+	check("server.sessionTTL (SESSION_TTL)", c.Server.SessionTTL > 0, "must be positive")
+	check("server.contextTTL (CONTEXT_TTL)", c.Server.ContextTTL > 0, "must be positive")
+	check("server.comparisonTTL (COMPARISON_TTL)", c.Server.ComparisonTTL > 0, "must be positive")
+	if c.Server.RateLimit.Enabled {
+		for _, rate := range []struct {
+			field string
+			rate  Rate
+		}{
+			{"server.rateLimit.session (RATE_LIMIT_SESSION)", c.Server.RateLimit.Session},
+			{"server.rateLimit.api (RATE_LIMIT_API)", c.Server.RateLimit.API},
+		} {
+			check(rate.field, rate.rate.Limit > 0 && rate.rate.Period > 0, "must allow some requests per period")
+		}
+	}
+	check("server.shutdownDelay (SHUTDOWN_DELAY)", c.Server.ShutdownDelay >= 0, "must not be negative")
+	check("server.drainTimeout (DRAIN_TIMEOUT)", c.Server.DrainTimeout > 0, "must be positive")
+	check("server.probeTimeout (PROBE_TIMEOUT)", c.Server.ProbeTimeout > 0, "must be positive")
+	check("translation.failoverAttemptTimeout (FAILOVER_ATTEMPT_TIMEOUT)", c.Translation.FailoverAttemptTimeout >= 0, "must not be negative")
+	check("requests.maxTemperature (REQUEST_MAX_TEMPERATURE)", c.Requests.MaxTemperature > 0, "must be a positive number")
+	check("requests.maxTokens (REQUEST_MAX_TOKENS)", c.Requests.MaxTokens >= 0, "must not be negative")
+	check("requests.maxStop (REQUEST_MAX_STOP)", c.Requests.MaxStop > 0, "must be positive")
+	if c.Chaos.Enabled {
+		if mode, _ := c.Lookup("GIN_MODE"); mode == "release" {
+			errs = append(errs, errors.New("chaos.enabled (CHAOS_ENABLED) is for development only and refused with GIN_MODE=release"))
+		}
+		if err := c.Chaos.Faults.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("chaos.faults (CHAOS_CONFIG): %w", err))
+		}
+	}
+	return errors.Join(append(errs, c.validateEngines())...)
+}
+
+// validateEngines checks every engine the configuration refers to.
+func (c *Config) validateEngines() error {
+	var errs []error
+	check := func(field, spec string) {
+		engine, model, _ := strings.Cut(strings.TrimSpace(spec), "/")
+		if err := babel.ValidateAISystem(engine, model, c.Lookup); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	if c.MachineTranslation() {
+		if err := babel.ValidateMachineTranslator(c.Translation.Engine[0], c.Lookup); err != nil {
+			errs = append(errs, fmt.Errorf("translation.engine (ENGINE): %w", err))
+		}
+		if c.Translation.PostEditor != "" {
+			check("translation.postEditor (POST_EDITOR)", c.Translation.PostEditor)
+		}
+		return errors.Join(errs...)
+	}
+
+	if len(c.Translation.Engine) == 0 {
+		check("translation.engine (ENGINE)", "")
+	}
+	for _, engine := range c.Translation.Engine {
+		check("translation.engine (ENGINE)", engine)
+	}
+	if c.Translation.Routes != "" {
+		_, err := babel.ParseRoutes(c.Translation.Routes, func(engine, model string) (babel.AISystem, error) {
+			return nil, babel.ValidateAISystem(engine, model, c.Lookup)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("translation.routes (ROUTES): %w", err))
+		}
+	}
+	for _, op := range sortedKeys(c.Translation.Operations) {
+		field := fmt.Sprintf("translation.operations.%s (ENGINE_%s)", op, strings.ToUpper(string(op)))
+		if !slices.Contains(babel.Operations, op) {
+			errs = append(errs, fmt.Errorf("%s: unknown operation", field))
+			continue
+		}
+		check(field, c.Translation.Operations[op])
+	}
+	for _, candidate := range c.Translation.Compare {
+		check("translation.compare (COMPARE_ENGINES)", candidate)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,218 @@
+package config_test
+
+import (
+	babel "BabelBridge/backend"
+	"BabelBridge/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// env serves variables from a map, like os.LookupEnv would.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadEnvOnly(t *testing.T) {
+	cfg, err := config.Load("", env(map[string]string{"ENGINE": "mock"}))
+	require.NoError(t, err)
+	require.Equal(t, 8080, cfg.Server.Port)
+	require.Equal(t, config.List{"mock"}, cfg.Translation.Engine)
+	require.Equal(t, config.Duration(7*24*time.Hour), cfg.Server.SessionTTL)
+	require.Equal(t, config.Duration(15*time.Minute), cfg.Server.ComparisonTTL)
+	require.False(t, cfg.Server.RateLimit.Enabled)
+	require.False(t, cfg.Server.ReloadEndpoint)
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := writeConfig(t, "babel.yaml", `
+server:
+  port: 9090
+  contextTTL: 1h
+  rateLimit:
+    enabled: true
+    api: {limit: 60, period: 30s}
+translation:
+  engine: [openai, mock]
+  failoverAttemptTimeout: 10s
+  operations:
+    preview: mock
+requests:
+  allowedModels: a, b
+engines:
+  openai:
+    baseURL: http://localhost:11434/v1
+    model: aya-expanse:8b
+    contextLength: 8192
+    hosts: [gpu1:11434, gpu2:11434]
+`)
+	cfg, err := config.Load(path, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, 9090, cfg.Server.Port)
+	require.Equal(t, config.Duration(time.Hour), cfg.Server.ContextTTL)
+	require.Equal(t, config.Rate{Limit: 60, Period: config.Duration(30 * time.Second)}, cfg.Server.RateLimit.API)
+	require.Equal(t, config.Rate{Limit: 5, Period: config.Duration(time.Minute)}, cfg.Server.RateLimit.Session, "unset fields keep their default")
+	require.Equal(t, config.List{"openai", "mock"}, cfg.Translation.Engine)
+	require.Equal(t, "mock", cfg.Translation.Operations[babel.OperationPreview])
+	require.Equal(t, config.List{"a", "b"}, cfg.Requests.AllowedModels)
+
+	baseURL, ok := cfg.Lookup("OPENAI_BASE_URL")
+	require.True(t, ok)
+	require.Equal(t, "http://localhost:11434/v1", baseURL)
+	model, _ := cfg.Lookup("OPENAI_MODEL")
+	require.Equal(t, "aya-expanse:8b", model)
+	contextLength, _ := cfg.Lookup("OPENAI_CONTEXT_LENGTH")
+	require.Equal(t, "8192", contextLength)
+	hosts, _ := cfg.Lookup("OPENAI_HOSTS")
+	require.Equal(t, "gpu1:11434,gpu2:11434", hosts, "lists are comma separated like in the environment")
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeConfig(t, "babel.toml", `
+[server]
+port = 9091
+
+[translation]
+engine = "openai"
+
+[engines.openai]
+OPENAI_HOST = "gpu1"
+model = "aya-expanse:32b"
+`)
+	cfg, err := config.Load(path, env(nil))
+	require.NoError(t, err)
+	require.Equal(t, 9091, cfg.Server.Port)
+	host, _ := cfg.Lookup("OPENAI_HOST")
+	require.Equal(t, "gpu1", host, "full setting names are accepted too")
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeConfig(t, "babel.yaml", `
+server:
+  port: 9090
+translation:
+  engine: openai
+engines:
+  openai:
+    model: aya-expanse:8b
+`)
+	cfg, err := config.Load(path, env(map[string]string{
+		"PORT":                    "7070",
+		"ENGINE":                  "mock",
+		"OPENAI_MODEL":            "aya-expanse:32b",
+		"RATE_LIMITING_ENABLED":   "true",
+		"RATE_LIMIT_SESSION":      "2/10s",
+		"DRAIN_TIMEOUT":           "1m",
+		"REQUEST_MAX_TOKENS":      "",
+		"RELOAD_ENDPOINT_ENABLED": "true",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 7070, cfg.Server.Port)
+	require.Equal(t, config.List{"mock"}, cfg.Translation.Engine)
+	require.True(t, cfg.Server.RateLimit.Enabled)
+	require.Equal(t, config.Rate{Limit: 2, Period: config.Duration(10 * time.Second)}, cfg.Server.RateLimit.Session)
+	require.Equal(t, config.Duration(time.Minute), cfg.Server.DrainTimeout)
+	require.True(t, cfg.Server.ReloadEndpoint)
+	model, _ := cfg.Lookup("OPENAI_MODEL")
+	require.Equal(t, "aya-expanse:32b", model)
+}
+
+func TestLoadIgnoresEmptyEnvironment(t *testing.T) {
+	path := writeConfig(t, "babel.yaml", `
+server:
+  port: 9090
+translation:
+  engine: openai
+engines:
+  openai:
+    model: aya-expanse:8b
+`)
+	// variables left empty in a compose file override neither server settings nor engine settings
+	cfg, err := config.Load(path, env(map[string]string{"PORT": "", "OPENAI_MODEL": " "}))
+	require.NoError(t, err)
+	require.Equal(t, 9090, cfg.Server.Port)
+	model, ok := cfg.Lookup("OPENAI_MODEL")
+	require.True(t, ok)
+	require.Equal(t, "aya-expanse:8b", model)
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, "babel.yaml", `
+translation:
+  engine: openai
+engines:
+  openai:
+    prot: 3
+  nope:
+    model: x
+`)
+	_, err := config.Load(path, env(map[string]string{
+		"PORT":                     "abc",
+		"OPENAI_PORT":              "x",
+		"REQUEST_MAX_STOP":         "-1",
+		"RATE_LIMIT_API":           "lots",
+		"SESSION_TTL":              "forever",
+		"DRAIN_TIMEOUT":            "0s",
+		"CHAOS_ENABLED":            "true",
+		"CHAOS_CONFIG":             `{"errorRate": 2}`,
+		"GIN_MODE":                 "release",
+		"ENGINE_PREVIEW":           "nope",
+		"FAILOVER_ATTEMPT_TIMEOUT": "",
+	}))
+	require.Error(t, err)
+	for _, problem := range []string{
+		`invalid PORT "abc"`,
+		`invalid RATE_LIMIT_API "lots"`,
+		`invalid SESSION_TTL "forever"`,
+		"engines.openai.prot: unknown setting",
+		"engines.nope:",
+		"requests.maxStop (REQUEST_MAX_STOP) must be positive",
+		"server.drainTimeout (DRAIN_TIMEOUT) must be positive",
+		"refused with GIN_MODE=release",
+		"chaos.faults (CHAOS_CONFIG)",
+		"invalid OPENAI_PORT",
+		"translation.operations.preview (ENGINE_PREVIEW)",
+	} {
+		require.ErrorContains(t, err, problem)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, "babel.yaml", "server:\n  prot: 8080\n")
+	_, err := config.Load(path, env(map[string]string{"ENGINE": "mock"}))
+	require.ErrorContains(t, err, `unknown field "prot"`)
+}
+
+func TestLoadRejectsInvalidPort(t *testing.T) {
+	path := writeConfig(t, "babel.json", `{"server": {"port": 0}, "translation": {"engine": "mock"}}`)
+	_, err := config.Load(path, env(nil))
+	require.ErrorContains(t, err, "server.port (PORT) must be between 1 and 65535")
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	path := writeConfig(t, "babel.ini", "port=8080")
+	_, err := config.Load(path, env(nil))
+	require.ErrorContains(t, err, "unsupported format")
+}
+
+func TestMachineTranslation(t *testing.T) {
+	cfg, err := config.Load("", env(map[string]string{"ENGINE": "deepl", "DEEPL_API_KEY": "key"}))
+	require.NoError(t, err)
+	require.True(t, cfg.MachineTranslation())
+
+	cfg, err = config.Load("", env(map[string]string{"ENGINE": "mock"}))
+	require.NoError(t, err)
+	require.False(t, cfg.MachineTranslation())
+}
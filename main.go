@@ -1,79 +1,433 @@
 package main
 
 import (
+	"BabelBridge/config"
 	"BabelBridge/service"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"BabelBridge/api"
 	"BabelBridge/backend"
 )
 
+// configFile is the optional configuration file, the environment overrides it.
+var configFile = flag.String("config", os.Getenv("CONFIG_FILE"), "YAML, TOML or JSON configuration `file`, overridden by the environment")
+
 func main() {
-	var aiBackend babel.AISystem
-	engine := os.Getenv("ENGINE")
-	switch engine {
-	case "mock":
-		slog.Info("Using mock backend for testing")
-		aiBackend = babel.NewMockAISystem()
-	case "openai":
-		key := os.Getenv("OPENAI_API_KEY")
-		if key == "" {
-			slog.Warn("OPENAI_API_KEY not set, using without authentication")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() > 1 || (flag.NArg() == 1 && flag.Arg(0) != "validate") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile, os.LookupEnv)
+	if err != nil {
+		reportInvalid(err)
+		os.Exit(1)
+	}
+	if flag.Arg(0) == "validate" {
+		fmt.Println("configuration is valid")
+		return
+	}
+
+	if cfg.Chaos.Enabled {
+		chaos, err = babel.NewChaos(cfg.Chaos.Faults)
+		if err != nil {
+			slog.Error("unable to start", "error", fmt.Errorf("invalid CHAOS_CONFIG: %w", err))
+			os.Exit(1)
+		}
+		slog.Warn("Injecting faults into every engine, control them through /admin/chaos", "config", cfg.Chaos.Faults)
+	}
+
+	// SIGTERM or Ctrl-C abort the startup or drain the running requests, a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	b, err := newBackend(ctx, cfg)
+	if err != nil {
+		slog.Error("unable to start", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.Server.SecretKey != "" {
+		slog.Info("Using configured secret key")
+	}
+	svc := service.NewBabelService(b, time.Duration(cfg.Server.ContextTTL))
+	svc.SetComparisonTTL(time.Duration(cfg.Server.ComparisonTTL))
+	svc.SetProbeTimeout(time.Duration(cfg.Server.ProbeTimeout))
+	server := api.NewServerWithConfig(svc, serverConfig(cfg))
+	server.SetGenerationLimits(cfg.Requests.Limits())
+	if chaos != nil {
+		server.EnableChaos(chaos)
+	}
+
+	server.OnShutdown(closeEngines)
+
+	r := &reloader{current: cfg, svc: svc, server: server}
+	if cfg.Server.ReloadEndpoint {
+		server.EnableReload(r.reload)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := r.reload(ctx); err != nil {
+				slog.Error("reload failed, keeping the current configuration", "error", err)
+			}
+		}
+	}()
+	server.OnShutdown(func(context.Context) error {
+		// wait for a running reload, then stop listening for more
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		signal.Stop(hup)
+		close(hup)
+		return nil
+	})
+
+	addr := ":" + strconv.Itoa(cfg.Server.Port)
+	log.Printf("Starting server on %s", addr)
+	if err := server.Run(ctx, addr); err != nil {
+		log.Fatalf("server failed: %v", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// serverConfig returns the settings of the HTTP server.
+func serverConfig(cfg *config.Config) api.Config {
+	rateLimit := cfg.Server.RateLimit
+	return api.Config{
+		SecretKey:     cfg.Server.SecretKey,
+		SessionTTL:    time.Duration(cfg.Server.SessionTTL),
+		ContextTTL:    time.Duration(cfg.Server.ContextTTL),
+		ComparisonTTL: time.Duration(cfg.Server.ComparisonTTL),
+		RateLimit: api.RateLimit{
+			Enabled: rateLimit.Enabled,
+			Session: api.Rate{Limit: rateLimit.Session.Limit, Period: time.Duration(rateLimit.Session.Period)},
+			API:     api.Rate{Limit: rateLimit.API.Limit, Period: time.Duration(rateLimit.API.Period)},
+		},
+		ShutdownDelay: time.Duration(cfg.Server.ShutdownDelay),
+		DrainTimeout:  time.Duration(cfg.Server.DrainTimeout),
+		ProbeTimeout:  time.Duration(cfg.Server.ProbeTimeout),
+	}
+}
+
+// reloader applies the configuration again on SIGHUP or, once enabled, POST /admin/reload. The backend and the
+// server settings are swapped at once, requests already running finish on the old backend and sessions and
+// translation contexts survive. Replaced engines are closed once DrainTimeout has passed, see retireEngines.
+type reloader struct {
+	mu      sync.Mutex
+	current *config.Config
+	svc     *service.BabelService
+	server  *api.Server
+}
+
+// reload loads and validates the configuration and builds the new backend before swapping anything, so that a
+// failed reload changes nothing.
+func (r *reloader) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfg, err := config.Load(*configFile, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if cfg.Server.Port != r.current.Server.Port {
+		slog.Warn("Changing the port needs a restart, keeping the current one", "port", r.current.Server.Port)
+	}
+	if cfg.Chaos.Enabled != (chaos != nil) {
+		slog.Warn("Enabling or disabling chaos needs a restart")
+	}
+	if cfg.Server.ReloadEndpoint != r.current.Server.ReloadEndpoint {
+		slog.Warn("Enabling or disabling the reload endpoint needs a restart")
+	}
+	if chaos != nil && cfg.Chaos.Enabled {
+		if err := cfg.Chaos.Faults.Validate(); err != nil {
+			return fmt.Errorf("invalid CHAOS_CONFIG: %w", err)
 		}
-		host := os.Getenv("OPENAI_HOST")
-		if host == "" {
-			slog.Error("OPENAI_HOST not set, defaulting to localhost")
+	}
+	b, replaced, err := rebuildEngines(func() (service.BackendInterface, error) { return newBackend(ctx, cfg) })
+	if err != nil {
+		return err
+	}
+
+	r.svc.SetBackend(b)
+	r.svc.SetTTL(time.Duration(cfg.Server.ContextTTL))
+	r.svc.SetComparisonTTL(time.Duration(cfg.Server.ComparisonTTL))
+	r.svc.SetProbeTimeout(time.Duration(cfg.Server.ProbeTimeout))
+	r.server.Reconfigure(serverConfig(cfg))
+	r.server.SetGenerationLimits(cfg.Requests.Limits())
+	if chaos != nil && cfg.Chaos.Enabled {
+		// validated above, before anything was swapped
+		if err := chaos.SetConfig(cfg.Chaos.Faults); err != nil {
+			slog.Error("Unable to apply chaos configuration", "error", err)
 		}
-		portStr := os.Getenv("OPENAI_PORT")
-		port, err := strconv.Atoi(portStr)
+	}
+	retireEngines(replaced, time.Duration(cfg.Server.DrainTimeout))
+	r.current = cfg
+	slog.Info("Configuration reloaded", "engine", cfg.Translation.Engine)
+	return nil
+}
+
+// newBackend builds the translation backend the configuration describes.
+func newBackend(ctx context.Context, cfg *config.Config) (service.BackendInterface, error) {
+	if cfg.MachineTranslation() {
+		return newMachineTranslation(ctx, cfg)
+	}
+	return newBabel(ctx, cfg)
+}
+
+// reportInvalid lists every configuration problem on its own line.
+func reportInvalid(err error) {
+	_, _ = fmt.Fprintln(os.Stderr, "invalid configuration, see --help for every engine and its settings:")
+	for _, problem := range strings.Split(err.Error(), "\n") {
+		_, _ = fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+	}
+}
+
+// newBabel builds the LLM backed translation backend from the engine and the routing, tier and comparison
+// settings.
+func newBabel(ctx context.Context, cfg *config.Config) (*babel.Backend, error) {
+	var aiBackend babel.AISystem
+	engines := cfg.Translation.Engine
+	if len(engines) == 1 {
+		var err error
+		aiBackend, err = newEngine(ctx, cfg, engines[0], "")
 		if err != nil {
-			slog.Error("invalid OPENAI_PORT, defaulting to 11434", "error", err)
+			return nil, err
 		}
-		model := os.Getenv("OPENAI_MODEL")
-		if model == "" {
-			slog.Error("OPENAI_MODEL not set, defaulting to 'aya-expanse:8b'")
+	} else {
+		// several engines form an ordered failover chain, e.g. ENGINE=openai,cohere
+		failover := babel.FailoverConfig{AttemptTimeout: time.Duration(cfg.Translation.FailoverAttemptTimeout)}
+		var members []babel.FailoverMember
+		for _, engine := range engines {
+			system, err := newEngine(ctx, cfg, engine, "")
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", engine, err)
+			}
+			members = append(members, babel.FailoverMember{Name: engine, Backend: system})
 		}
+		slog.Info("Using failover chain", "engines", engines)
+		aiBackend = babel.NewFailoverAISystem(failover, members...)
+	}
 
-		aiBackend = babel.NewOpenAILocalBackend(host, port, model, key)
-	case "cohere":
-		apiKey := os.Getenv("COHERE_API_KEY")
-		if apiKey == "" {
-			slog.Error("COHERE_API_KEY not set, unable to start")
-			os.Exit(1)
+	b := babel.NewBabel(aiBackend)
+	if spec := cfg.Translation.Routes; spec != "" {
+		routes, err := babel.ParseRoutes(spec, func(engine, model string) (babel.AISystem, error) {
+			return newEngine(ctx, cfg, engine, model)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROUTES: %w", err)
+		}
+		slog.Info("Using language routes", "routes", len(routes))
+		b = babel.NewBabelWithRouter(babel.NewLanguageRouter(aiBackend, routes...))
+	}
+	// per-operation tiers, e.g. ENGINE_PREVIEW=openai/qwen2.5:1.5b for keystroke-driven calls
+	for _, op := range babel.Operations {
+		spec := cfg.Translation.Operations[op]
+		if spec == "" {
+			continue
 		}
-		model := os.Getenv("COHERE_MODEL")
-		if model == "" {
-			slog.Error("COHERE_MODEL not set, defaulting to 'c4ai-aya-expanse-8b'")
+		engine, model, _ := strings.Cut(spec, "/")
+		system, err := newEngine(ctx, cfg, engine, model)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENGINE_%s: %w", strings.ToUpper(string(op)), err)
 		}
-		aiBackend = babel.NewCohereClient(apiKey, model)
-	default:
-		slog.Error("ENGINE not set, must be 'mock', 'openai' or 'cohere'")
-		os.Exit(1)
+		slog.Info("Using dedicated backend for operation", "operation", op, "engine", engine, "model", model)
+		b.SetOperationBackend(op, system)
 	}
+	// side-by-side comparison, e.g. COMPARE_ENGINES=openai/aya-expanse:8b,openai/aya-expanse:32b
+	if len(cfg.Translation.Compare) > 0 {
+		var candidates []babel.Candidate
+		for _, name := range cfg.Translation.Compare {
+			engine, model, _ := strings.Cut(name, "/")
+			system, err := newEngine(ctx, cfg, engine, model)
+			if err != nil {
+				return nil, fmt.Errorf("invalid COMPARE_ENGINES candidate %s: %w", name, err)
+			}
+			candidates = append(candidates, babel.Candidate{Name: name, Backend: system})
+		}
+		b.SetCompareCandidates(candidates...)
+	}
+	return b, nil
+}
 
-	secretKey := os.Getenv("SECRET_KEY")
-	if secretKey != "" {
-		slog.Info("Using secret key", "key", secretKey)
+// newMachineTranslation builds a backend on a dedicated machine translation engine. Improvements are rejected
+// unless POST_EDITOR names an LLM engine to apply them, e.g. POST_EDITOR=openai/aya-expanse:8b.
+func newMachineTranslation(ctx context.Context, cfg *config.Config) (*babel.MachineTranslationBackend, error) {
+	engine := cfg.Translation.Engine[0]
+	translator, err := babel.NewMachineTranslator(engine, cfg.Lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	var postEditor babel.AISystem
+	if spec := cfg.Translation.PostEditor; spec != "" {
+		editorEngine, model, _ := strings.Cut(spec, "/")
+		system, err := newEngine(ctx, cfg, editorEngine, model)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POST_EDITOR: %w", err)
+		}
+		postEditor = system
+		slog.Info("Using post-editor for improvements", "engine", editorEngine, "model", model)
 	} else {
-		slog.Warn("SECRET_KEY not set, generating random one")
-		secretKey = service.RandomToken()
+		slog.Warn("POST_EDITOR not set, improvements will be rejected", "engine", engine)
 	}
+	slog.Info("Using machine translation engine", "engine", engine)
+	return babel.NewMachineTranslationBackend(translator, postEditor), nil
+}
 
-	b := babel.NewBabel(aiBackend)
-	svc := service.NewBabelService(b, 7*24*time.Hour)
-	server := api.NewServer(svc, secretKey)
+// chaos injects faults into every engine when chaos is enabled, nil otherwise.
+var chaos *babel.Chaos
+
+// engines keeps the engines of the current configuration and those a reload replaced, so that they can be closed.
+var engines struct {
+	sync.Mutex
+	systems []babel.AISystem
+	// retired are replaced engines waiting for their grace period to pass
+	retired map[*retiredEngines]bool
+}
+
+type retiredEngines struct {
+	systems []babel.AISystem
+}
 
-	addr := ":8080"
-	if v := os.Getenv("PORT"); v != "" {
-		addr = ":" + v
+// newEngine builds a registered chat engine from its settings. A non-empty model overrides the engine's model
+// setting.
+func newEngine(ctx context.Context, cfg *config.Config, engine string, model string) (babel.AISystem, error) {
+	system, err := babel.NewAISystem(ctx, engine, model, cfg.Lookup)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("Starting server on %s", addr)
-	if err := server.Engine.Run(addr); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server failed: %v", err)
+	if chaos != nil {
+		system = chaos.Wrap(engine, system)
+	}
+	engines.Lock()
+	engines.systems = append(engines.systems, system)
+	engines.Unlock()
+	return system, nil
+}
+
+// rebuildEngines builds a backend with engines of its own. It returns the engines the backend replaces, or closes
+// the engines it built when building fails, keeping the current ones.
+func rebuildEngines(build func() (service.BackendInterface, error)) (service.BackendInterface, []babel.AISystem, error) {
+	engines.Lock()
+	current := len(engines.systems)
+	engines.Unlock()
+
+	b, err := build()
+
+	engines.Lock()
+	defer engines.Unlock()
+	replaced, built := engines.systems[:current:current], engines.systems[current:]
+	if err != nil {
+		engines.systems = replaced
+		return nil, nil, errors.Join(err, closeSystems(built))
+	}
+	engines.systems = slices.Clip(built)
+	return b, replaced, nil
+}
+
+// retireEngines closes systems once grace has passed, giving the requests still running on them time to finish.
+// Translation contexts started on them keep working after that: HTTP engines have nothing to close, pools keep
+// serving without health checks and plugins start again on demand.
+func retireEngines(systems []babel.AISystem, grace time.Duration) {
+	retired := &retiredEngines{systems: systems}
+	engines.Lock()
+	if engines.retired == nil {
+		engines.retired = make(map[*retiredEngines]bool)
+	}
+	engines.retired[retired] = true
+	engines.Unlock()
+
+	time.AfterFunc(grace, func() {
+		engines.Lock()
+		pending := engines.retired[retired]
+		delete(engines.retired, retired)
+		engines.Unlock()
+		if pending {
+			if err := closeSystems(systems); err != nil {
+				slog.Warn("closing replaced engines failed", "error", err)
+			}
+		}
+	})
+}
+
+// closeEngines stops the background work of every engine, such as pool health checks and plugin processes.
+func closeEngines(context.Context) error {
+	engines.Lock()
+	systems := engines.systems
+	for retired := range engines.retired {
+		systems = append(systems, retired.systems...)
+	}
+	engines.systems, engines.retired = nil, nil
+	engines.Unlock()
+	return closeSystems(systems)
+}
+
+func closeSystems(systems []babel.AISystem) error {
+	var errs []error
+	for _, system := range systems {
+		errs = append(errs, babel.CloseAISystem(system))
+	}
+	return errors.Join(errs...)
+}
+
+// usage lists every registered engine with its settings.
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [--config file] [validate]\n\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	_, _ = fmt.Fprint(out, "\nThe validate command checks the configuration and every engine it refers to, then exits.\n\n"+
+		"Configuration is read from the file, if any, and the environment, which wins. ENGINE selects one of the engines below, a comma\n"+
+		"separated list of them forms a failover chain. ROUTES, ENGINE_<OPERATION>, COMPARE_ENGINES and\n"+
+		"POST_EDITOR take engine[/model], the model replacing the engine's model setting.\n\n"+
+		"Requests may override the generation options within REQUEST_ALLOWED_MODELS (comma separated,\n"+
+		"none by default), REQUEST_MAX_TEMPERATURE (default 2), REQUEST_MAX_TOKENS and REQUEST_MAX_STOP\n"+
+		"(default 4).\n\n"+
+		"CHAOS_ENABLED=true injects the faults of CHAOS_CONFIG (JSON) into every engine, changeable at runtime\n"+
+		"through /admin/chaos from localhost. For development only.\n\n"+
+		"In the file, the settings of an engine go under engines.<name>, without the engine prefix, e.g.\n"+
+		"engines.openai.baseURL for OPENAI_BASE_URL.\n\nEngines:\n")
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, engine := range babel.Engines() {
+		_, _ = fmt.Fprintf(w, "\n  %s: %s\n", engine.Name, engine.Description)
+		for _, setting := range engine.Settings {
+			details := setting.Description
+			if setting.Required {
+				details += " (required)"
+			}
+			if setting.Default != "" {
+				details += fmt.Sprintf(" (default %s)", setting.Default)
+			}
+			if len(setting.Values) > 0 {
+				details += fmt.Sprintf(" (one of %s)", strings.Join(setting.Values, ", "))
+			}
+			typ := setting.Type
+			if typ == "" {
+				typ = babel.SettingString
+			}
+			_, _ = fmt.Fprintf(w, "    %s\t%s\t%s\n", setting.Name, typ, details)
+		}
 	}
+	_ = w.Flush()
 }
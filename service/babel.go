@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -13,42 +14,303 @@ import (
 
 // BackendInterface defines the interface for translation backends
 type BackendInterface interface {
-	NewTranslation(ctx context.Context, input string, outputLanguage language.Tag) (*babel.TranslationContext, string, error)
+	NewTranslationFrom(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (*babel.TranslationContext, string, error)
+	Preview(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag) (string, error)
 	IdentifyLanguage(ctx context.Context, input string) (language.Tag, error)
 }
 
+// CapabilityReporter is implemented by backends that can tell what their AI systems support.
+type CapabilityReporter interface {
+	Capabilities() babel.Capabilities
+}
+
+// Streamer is implemented by backends that can deliver translations as they are generated.
+type Streamer interface {
+	NewTranslationStream(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag, onDelta func(string) error) (*babel.TranslationContext, string, error)
+	PreviewStream(ctx context.Context, input string, sourceLanguage, outputLanguage language.Tag, onDelta func(string) error) (string, error)
+}
+
+// FailoverReporter is implemented by backends that can tell the health of the members of their failover chain.
+type FailoverReporter interface {
+	FailoverStatus() []babel.FailoverStatus
+}
+
+// Comparer is implemented by backends that can run a translation on several candidates side by side.
+type Comparer interface {
+	Compare(ctx context.Context, input string, outputLanguage language.Tag) []babel.ComparisonResult
+	CompareCandidates() []string
+}
+
+var (
+	// ErrCompareUnavailable is returned by Compare when the backend has fewer than two candidates to compare
+	ErrCompareUnavailable = errors.New("comparison not configured")
+	// ErrComparisonNotFound is returned by Promote for unknown, expired or already promoted comparisons
+	ErrComparisonNotFound = errors.New("comparison expired or not found")
+	// ErrCandidateNotFound is returned by Promote when the comparison has no successful candidate of that name
+	ErrCandidateNotFound = errors.New("candidate not found")
+)
+
+// modelCacheTTL is how long a model list is served before the backend is asked again
+const modelCacheTTL = 5 * time.Minute
+
+// healthCacheTTL is how long a probe result is served before the backend is probed again
+const healthCacheTTL = 10 * time.Second
+
+// defaultProbeTimeout bounds a probe unless SetProbeTimeout says otherwise
+const defaultProbeTimeout = 5 * time.Second
+
+// healthProbe is a probe of the backend shared by every caller of Health while it runs
+type healthProbe struct {
+	done   chan struct{}
+	health Health
+}
+
+// defaultComparisonTTL is how long unpromoted comparisons are kept unless SetComparisonTTL says otherwise
+const defaultComparisonTTL = 15 * time.Minute
+
+// comparison holds the candidate contexts of a comparison until one of them is promoted
+type comparison struct {
+	contexts map[string]*babel.TranslationContext
+	created  time.Time
+}
+
 // BabelService is the production adapter implementing TranslationService backed by BackendInterface
 type BabelService struct {
-	mu        sync.Mutex
+	// backendMu guards b, which SetBackend replaces while requests are running
+	backendMu sync.RWMutex
 	b         BackendInterface
-	contexts  map[string]*babel.TranslationContext
-	lastTouch map[string]time.Time
-	ttl       time.Duration
+
+	mu          sync.Mutex
+	contexts    map[string]*babel.TranslationContext
+	lastTouch   map[string]time.Time
+	comparisons map[string]*comparison
+	ttl         time.Duration
+	// comparisonTTL is kept short, as every comparison holds a context per candidate
+	comparisonTTL time.Duration
+
+	// modelsMu is held while listing, so that concurrent requests share a single listing
+	modelsMu     sync.Mutex
+	models       []babel.ModelInfo
+	modelsListed time.Time
+
+	// healthMu guards the cached probe result and the probe running, which concurrent checks share
+	healthMu     sync.Mutex
+	health       Health
+	probe        *healthProbe
+	probeTimeout time.Duration
 }
 
 func NewBabelService(b BackendInterface, ttl time.Duration) *BabelService {
 	return &BabelService{
-		b:         b,
-		contexts:  make(map[string]*babel.TranslationContext),
-		lastTouch: make(map[string]time.Time),
-		ttl:       ttl,
+		b:             b,
+		contexts:      make(map[string]*babel.TranslationContext),
+		lastTouch:     make(map[string]time.Time),
+		comparisons:   make(map[string]*comparison),
+		ttl:           ttl,
+		comparisonTTL: defaultComparisonTTL,
+		probeTimeout:  defaultProbeTimeout,
 	}
 }
 
-func (s *BabelService) NewTranslation(ctx context.Context, input string, output language.Tag) (string, string, error) {
-	translationContext, result, err := s.b.NewTranslation(ctx, input, output)
+// backend returns the current backend. Callers hold on to it for the whole request, so that requests running
+// while the backend is replaced finish on the one they started with.
+func (s *BabelService) backend() BackendInterface {
+	s.backendMu.RLock()
+	defer s.backendMu.RUnlock()
+	return s.b
+}
+
+// SetBackend replaces the backend serving new requests. Requests already running finish on the old backend and
+// existing translation contexts keep improving on the AI system they were started with.
+func (s *BabelService) SetBackend(b BackendInterface) {
+	s.backendMu.Lock()
+	s.b = b
+	s.backendMu.Unlock()
+
+	// the cached list and probe belong to the old backend
+	s.modelsMu.Lock()
+	s.models, s.modelsListed = nil, time.Time{}
+	s.modelsMu.Unlock()
+	s.healthMu.Lock()
+	s.health, s.probe = Health{}, nil
+	s.healthMu.Unlock()
+}
+
+// SetTTL changes how long idle contexts are kept, applying to existing ones too.
+func (s *BabelService) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+}
+
+// SetComparisonTTL changes how long unpromoted comparisons are kept, applying to existing ones too.
+func (s *BabelService) SetComparisonTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comparisonTTL = ttl
+}
+
+// Capabilities returns what the backend supports, nothing beyond plain translation when it cannot tell.
+func (s *BabelService) Capabilities() babel.Capabilities {
+	if reporter, ok := s.backend().(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return babel.Capabilities{SamplingParameters: []string{}}
+}
+
+// Models lists the models the backend can serve, nil when it cannot tell. The list is cached for a few minutes,
+// the last list is served as long as listing fails.
+func (s *BabelService) Models(ctx context.Context) ([]babel.ModelInfo, error) {
+	// the backend is picked under the lock, so that SetBackend clears the cache after a listing of the old one
+	s.modelsMu.Lock()
+	defer s.modelsMu.Unlock()
+	lister, ok := s.backend().(babel.ModelLister)
+	if !ok {
+		return nil, nil
+	}
+	if s.models != nil && time.Since(s.modelsListed) < modelCacheTTL {
+		return s.models, nil
+	}
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		if s.models != nil {
+			slog.Warn("listing models failed, serving the last list", "error", err)
+			return s.models, nil
+		}
+		return nil, err
+	}
+	if models == nil {
+		models = []babel.ModelInfo{}
+	}
+	s.models, s.modelsListed = models, time.Now()
+	return models, nil
+}
+
+// SetProbeTimeout changes how long the backend may take to answer a health probe.
+func (s *BabelService) SetProbeTimeout(timeout time.Duration) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.probeTimeout = timeout
+}
+
+// Health probes the backend cheaply, backends that cannot be probed are taken as healthy. The result is cached for
+// a few seconds so that frequent readiness checks do not load the engine. The failover status is always current.
+//
+// Concurrent callers share a single probe, which runs detached from them and bounded by the probe timeout, so that
+// a caller giving up neither fails the probe for the others nor ends up in the cache. A caller whose ctx is done
+// before the probe finished gets ctx's error.
+func (s *BabelService) Health(ctx context.Context) Health {
+	b := s.backend()
+	s.healthMu.Lock()
+	health, probe := s.health, s.probe
+	if probe == nil && (health.Checked.IsZero() || time.Since(health.Checked) >= healthCacheTTL) {
+		probe = &healthProbe{done: make(chan struct{})}
+		s.probe = probe
+		go s.runProbe(context.WithoutCancel(ctx), b, probe, s.probeTimeout)
+	}
+	s.healthMu.Unlock()
+
+	if probe != nil {
+		select {
+		case <-probe.done:
+			health = probe.health
+		case <-ctx.Done():
+			health = Health{Err: ctx.Err(), Checked: time.Now()}
+		}
+	}
+	if reporter, ok := b.(FailoverReporter); ok {
+		health.Failover = reporter.FailoverStatus()
+	}
+	return health
+}
+
+// runProbe probes b and caches the result, unless the backend was replaced in the meantime.
+func (s *BabelService) runProbe(ctx context.Context, b BackendInterface, probe *healthProbe, timeout time.Duration) {
+	defer close(probe.done)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	if checker, ok := b.(babel.HealthChecker); ok {
+		err = checker.HealthCheck(ctx)
+	}
+	probe.health = Health{Err: err, Latency: time.Since(start), Checked: start}
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if s.probe == probe {
+		s.health, s.probe = probe.health, nil
+	}
+}
+
+// served attaches a CallInfo to ctx. The returned function logs which backend, pool endpoint and route served op,
+// to be called once the call returned.
+func served(ctx context.Context, op babel.Operation) (context.Context, func(error)) {
+	ctx, info := babel.WithCallInfo(ctx)
+	return ctx, func(err error) {
+		if err != nil {
+			return
+		}
+		attrs := []any{"operation", op, "promptTokens", info.Usage.PromptTokens, "completionTokens", info.Usage.CompletionTokens}
+		for _, attr := range [][2]string{{"backend", info.Backend}, {"endpoint", info.Endpoint}, {"route", info.Route}} {
+			if attr[1] != "" {
+				attrs = append(attrs, attr[0], attr[1])
+			}
+		}
+		slog.Info("translation served", attrs...)
+	}
+}
+
+func (s *BabelService) NewTranslation(ctx context.Context, input string, source, output language.Tag) (string, string, error) {
+	ctx, logServed := served(ctx, babel.OperationStart)
+	translationContext, result, err := s.backend().NewTranslationFrom(ctx, input, source, output)
+	logServed(err)
+	if err != nil {
+		return "", "", err
+	}
+	return s.keep(translationContext), result, nil
+}
+
+// NewTranslationStream is NewTranslation calling onDelta with every piece of the translation as it arrives.
+func (s *BabelService) NewTranslationStream(ctx context.Context, input string, source, output language.Tag, onDelta func(string) error) (string, string, error) {
+	streamer, ok := s.backend().(Streamer)
+	if !ok {
+		return "", "", babel.ErrStreamingUnsupported
+	}
+	ctx, logServed := served(ctx, babel.OperationStart)
+	translationContext, result, err := streamer.NewTranslationStream(ctx, input, source, output, onDelta)
+	logServed(err)
 	if err != nil {
 		return "", "", err
 	}
+	return s.keep(translationContext), result, nil
+}
+
+// keep stores a new translation context and returns its id.
+func (s *BabelService) keep(translationContext *babel.TranslationContext) string {
 	id := RandomToken()
 	s.mu.Lock()
 	s.contexts[id] = translationContext
 	s.lastTouch[id] = time.Now()
 	s.mu.Unlock()
-	return id, result, nil
+	return id
 }
 
 func (s *BabelService) Improve(ctx context.Context, ctxID string, feedback string) (string, error) {
+	return s.improve(ctx, ctxID, func(ctx context.Context, translationContext *babel.TranslationContext) (string, error) {
+		return translationContext.Improve(ctx, feedback)
+	})
+}
+
+// ImproveStream is Improve calling onDelta with every piece of the improved text as it arrives.
+func (s *BabelService) ImproveStream(ctx context.Context, ctxID string, feedback string, onDelta func(string) error) (string, error) {
+	return s.improve(ctx, ctxID, func(ctx context.Context, translationContext *babel.TranslationContext) (string, error) {
+		return translationContext.ImproveStream(ctx, feedback, onDelta)
+	})
+}
+
+func (s *BabelService) improve(ctx context.Context, ctxID string, run func(context.Context, *babel.TranslationContext) (string, error)) (string, error) {
 	s.mu.Lock()
 	translationContext, ok := s.contexts[ctxID]
 	if ok {
@@ -63,7 +325,9 @@ func (s *BabelService) Improve(ctx context.Context, ctxID string, feedback strin
 	if !ok {
 		return "", errors.New("context expired or not found")
 	}
-	res, err := translationContext.Improve(ctx, feedback)
+	ctx, logServed := served(ctx, babel.OperationImprove)
+	res, err := run(ctx, translationContext)
+	logServed(err)
 	if err != nil {
 		return "", err
 	}
@@ -74,14 +338,81 @@ func (s *BabelService) Improve(ctx context.Context, ctxID string, feedback strin
 }
 
 func (s *BabelService) Identify(ctx context.Context, input string) (language.Tag, error) {
-	return s.b.IdentifyLanguage(ctx, input)
+	return s.backend().IdentifyLanguage(ctx, input)
 }
 
 // Preview performs a stateless translation returning only the result without persisting context
 func (s *BabelService) Preview(ctx context.Context, input string, output language.Tag) (string, error) {
-	_, res, err := s.b.NewTranslation(ctx, input, output)
-	if err != nil {
-		return "", err
+	ctx, logServed := served(ctx, babel.OperationPreview)
+	res, err := s.backend().Preview(ctx, input, language.Und, output)
+	logServed(err)
+	return res, err
+}
+
+// PreviewStream is Preview calling onDelta with every piece of the translation as it arrives.
+func (s *BabelService) PreviewStream(ctx context.Context, input string, output language.Tag, onDelta func(string) error) (string, error) {
+	streamer, ok := s.backend().(Streamer)
+	if !ok {
+		return "", babel.ErrStreamingUnsupported
 	}
-	return res, nil
+	ctx, logServed := served(ctx, babel.OperationPreview)
+	res, err := streamer.PreviewStream(ctx, input, language.Und, output, onDelta)
+	logServed(err)
+	return res, err
+}
+
+// Compare runs the translation on every comparison candidate of the backend and keeps the successful ones
+// around so that the winner can be promoted into a regular context.
+func (s *BabelService) Compare(ctx context.Context, input string, output language.Tag) (string, []ComparisonCandidate, error) {
+	comparer, ok := s.backend().(Comparer)
+	if !ok || len(comparer.CompareCandidates()) < 2 {
+		return "", nil, ErrCompareUnavailable
+	}
+
+	results := comparer.Compare(ctx, input, output)
+	c := &comparison{contexts: make(map[string]*babel.TranslationContext), created: time.Now()}
+	candidates := make([]ComparisonCandidate, 0, len(results))
+	for _, r := range results {
+		candidate := ComparisonCandidate{Name: r.Name, Result: r.Result, Latency: r.Latency, Usage: r.Usage}
+		if r.Err != nil {
+			candidate.Error = r.Err.Error()
+		} else {
+			c.contexts[r.Name] = r.Context
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	id := RandomToken()
+	s.mu.Lock()
+	// drop comparisons nobody promoted
+	for cid, old := range s.comparisons {
+		if time.Since(old.created) > s.comparisonTTL {
+			delete(s.comparisons, cid)
+		}
+	}
+	s.comparisons[id] = c
+	s.mu.Unlock()
+	return id, candidates, nil
+}
+
+// Promote turns the named candidate of a comparison into a regular translation context that can be improved.
+// The comparison is consumed by a successful promotion.
+func (s *BabelService) Promote(ctx context.Context, comparisonID string, candidate string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.comparisons[comparisonID]
+	if !ok || time.Since(c.created) > s.comparisonTTL {
+		delete(s.comparisons, comparisonID)
+		return "", "", ErrComparisonNotFound
+	}
+	translationContext, ok := c.contexts[candidate]
+	if !ok {
+		return "", "", ErrCandidateNotFound
+	}
+	delete(s.comparisons, comparisonID)
+
+	id := RandomToken()
+	s.contexts[id] = translationContext
+	s.lastTouch[id] = time.Now()
+	return id, translationContext.LastResult(), nil
 }
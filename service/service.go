@@ -6,15 +6,45 @@ import (
 	"encoding/base64"
 	"time"
 
+	babel "BabelBridge/backend"
+
 	"golang.org/x/text/language"
 )
 
 // TranslationService abstracts the translation engine for ease of testing.
 type TranslationService interface {
-	NewTranslation(ctx context.Context, input string, output language.Tag) (ctxID string, initial string, err error)
+	NewTranslation(ctx context.Context, input string, source, output language.Tag) (ctxID string, initial string, err error)
 	Improve(ctx context.Context, ctxID string, feedback string) (string, error)
 	Identify(ctx context.Context, input string) (language.Tag, error)
 	Preview(ctx context.Context, input string, output language.Tag) (string, error)
+	Capabilities() babel.Capabilities
+	Models(ctx context.Context) ([]babel.ModelInfo, error)
+	Health(ctx context.Context) Health
+	NewTranslationStream(ctx context.Context, input string, source, output language.Tag, onDelta func(string) error) (ctxID string, result string, err error)
+	ImproveStream(ctx context.Context, ctxID string, feedback string, onDelta func(string) error) (string, error)
+	PreviewStream(ctx context.Context, input string, output language.Tag, onDelta func(string) error) (string, error)
+	Compare(ctx context.Context, input string, output language.Tag) (comparisonID string, candidates []ComparisonCandidate, err error)
+	Promote(ctx context.Context, comparisonID string, candidate string) (ctxID string, result string, err error)
+}
+
+// ComparisonCandidate is the result of one backend in a side-by-side comparison.
+type ComparisonCandidate struct {
+	Name    string
+	Result  string
+	Error   string
+	Latency time.Duration
+	Usage   babel.Usage
+}
+
+// Health is the outcome of probing the backend.
+type Health struct {
+	// Err is nil when the backend answered
+	Err     error
+	Latency time.Duration
+	// Checked is when the probe ran
+	Checked time.Time
+	// Failover is the live health of the failover chain members, nil without a chain
+	Failover []babel.FailoverStatus
 }
 
 func RandomToken() string {
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -94,19 +99,24 @@ func TestRandomTokenFallback(t *testing.T) {
 
 // Mock backend for testing BabelService
 type mockBackend struct {
-	newTranslationFunc func(ctx context.Context, input string, output language.Tag) (*backend.TranslationContext, string, error)
+	newTranslationFunc func(ctx context.Context, input string, source, output language.Tag) (*backend.TranslationContext, string, error)
 	identifyFunc       func(ctx context.Context, input string) (language.Tag, error)
 }
 
-func (m *mockBackend) NewTranslation(ctx context.Context, input string, output language.Tag) (*backend.TranslationContext, string, error) {
+func (m *mockBackend) NewTranslationFrom(ctx context.Context, input string, source, output language.Tag) (*backend.TranslationContext, string, error) {
 	if m.newTranslationFunc != nil {
-		return m.newTranslationFunc(ctx, input, output)
+		return m.newTranslationFunc(ctx, input, source, output)
 	}
 	// Create a mock translation context
 	mockCtx := &backend.TranslationContext{}
 	return mockCtx, "translation result", nil
 }
 
+func (m *mockBackend) Preview(ctx context.Context, input string, source, output language.Tag) (string, error) {
+	_, res, err := m.NewTranslationFrom(ctx, input, source, output)
+	return res, err
+}
+
 func (m *mockBackend) IdentifyLanguage(ctx context.Context, input string) (language.Tag, error) {
 	if m.identifyFunc != nil {
 		return m.identifyFunc(ctx, input)
@@ -142,7 +152,7 @@ func TestBabelServiceNewTranslation(t *testing.T) {
 	service := NewBabelService(mockB, 5*time.Minute)
 	ctx := context.Background()
 
-	contextID, result, err := service.NewTranslation(ctx, "Hello", language.Spanish)
+	contextID, result, err := service.NewTranslation(ctx, "Hello", language.English, language.Spanish)
 
 	if err != nil {
 		t.Errorf("NewTranslation should not return error: %v", err)
@@ -167,6 +177,25 @@ func TestBabelServiceNewTranslation(t *testing.T) {
 	service.mu.Unlock()
 }
 
+func TestBabelServiceNewTranslationPassesSourceLanguage(t *testing.T) {
+	var gotSource language.Tag
+	mockB := &mockBackend{
+		newTranslationFunc: func(ctx context.Context, input string, source, output language.Tag) (*backend.TranslationContext, string, error) {
+			gotSource = source
+			return &backend.TranslationContext{}, "translation result", nil
+		},
+	}
+	service := NewBabelService(mockB, 5*time.Minute)
+
+	if _, _, err := service.NewTranslation(context.Background(), "こんにちは", language.Japanese, language.English); err != nil {
+		t.Fatalf("NewTranslation should not return error: %v", err)
+	}
+
+	if gotSource != language.Japanese {
+		t.Errorf("Expected source language to be passed to the backend, got %v", gotSource)
+	}
+}
+
 func TestBabelServiceIdentify(t *testing.T) {
 	mockB := &mockBackend{}
 	service := NewBabelService(mockB, 5*time.Minute)
@@ -214,7 +243,7 @@ func TestBabelServiceImproveExpiredContext(t *testing.T) {
 	ctx := context.Background()
 
 	// Create a translation
-	contextID, _, err := service.NewTranslation(ctx, "Hello", language.Spanish)
+	contextID, _, err := service.NewTranslation(ctx, "Hello", language.English, language.Spanish)
 	if err != nil {
 		t.Fatalf("Failed to create translation: %v", err)
 	}
@@ -259,7 +288,7 @@ func TestBabelServiceContextCleanupOnImprove(t *testing.T) {
 	ctx := context.Background()
 
 	// Create translation
-	contextID, _, err := service.NewTranslation(ctx, "Hello", language.Spanish)
+	contextID, _, err := service.NewTranslation(ctx, "Hello", language.English, language.Spanish)
 	if err != nil {
 		t.Fatalf("Failed to create translation: %v", err)
 	}
@@ -296,7 +325,7 @@ func TestBabelServiceConcurrentAccess(t *testing.T) {
 		go func() {
 			defer func() { done <- true }()
 
-			contextID, _, err := service.NewTranslation(ctx, "Hello", language.Spanish)
+			contextID, _, err := service.NewTranslation(ctx, "Hello", language.English, language.Spanish)
 			if err != nil {
 				t.Errorf("Concurrent NewTranslation failed: %v", err)
 				return
@@ -315,14 +344,14 @@ func TestBabelServiceConcurrentAccess(t *testing.T) {
 
 func TestBabelServiceBackendErrorHandling(t *testing.T) {
 	mockB := &mockBackend{
-		newTranslationFunc: func(ctx context.Context, input string, output language.Tag) (*backend.TranslationContext, string, error) {
+		newTranslationFunc: func(ctx context.Context, input string, source, output language.Tag) (*backend.TranslationContext, string, error) {
 			return nil, "", &testError{"backend error"}
 		},
 	}
 	service := NewBabelService(mockB, 5*time.Minute)
 	ctx := context.Background()
 
-	_, _, err := service.NewTranslation(ctx, "Hello", language.Spanish)
+	_, _, err := service.NewTranslation(ctx, "Hello", language.English, language.Spanish)
 
 	if err == nil {
 		t.Error("NewTranslation should return error when backend fails")
@@ -353,6 +382,92 @@ func TestBabelServiceIdentifyBackendError(t *testing.T) {
 	}
 }
 
+func TestBabelServiceComparisonExpires(t *testing.T) {
+	b := backend.NewBabel(backend.NewMockAISystem())
+	b.SetCompareCandidates(
+		backend.Candidate{Name: "a", Backend: backend.NewMockAISystem()},
+		backend.Candidate{Name: "b", Backend: backend.NewMockAISystem()},
+	)
+	service := NewBabelService(b, time.Hour)
+	service.SetComparisonTTL(time.Millisecond)
+	ctx := context.Background()
+
+	comparisonID, _, err := service.Compare(ctx, "Hello. I like pizza.", language.German)
+	if err != nil {
+		t.Fatalf("Compare should not return error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// comparisons expire long before translation contexts do
+	if _, _, err := service.Promote(ctx, comparisonID, "a"); !errors.Is(err, ErrComparisonNotFound) {
+		t.Errorf("Expected ErrComparisonNotFound, got %v", err)
+	}
+}
+
+func TestBabelServiceCompareUnavailable(t *testing.T) {
+	service := NewBabelService(&mockBackend{}, 5*time.Minute)
+
+	_, _, err := service.Compare(context.Background(), "Hello", language.Spanish)
+	if !errors.Is(err, ErrCompareUnavailable) {
+		t.Errorf("Expected ErrCompareUnavailable, got %v", err)
+	}
+}
+
+func TestBabelServiceWithoutCapabilities(t *testing.T) {
+	service := NewBabelService(&mockBackend{}, 5*time.Minute)
+
+	if service.Capabilities().Streaming {
+		t.Error("A backend that cannot report capabilities should not claim streaming")
+	}
+	_, err := service.PreviewStream(context.Background(), "Hello", language.Spanish, func(string) error { return nil })
+	if !errors.Is(err, backend.ErrStreamingUnsupported) {
+		t.Errorf("Expected ErrStreamingUnsupported, got %v", err)
+	}
+}
+
+func TestBabelServiceCompareAndPromote(t *testing.T) {
+	b := backend.NewBabel(backend.NewMockAISystem())
+	b.SetCompareCandidates(
+		backend.Candidate{Name: "a", Backend: backend.NewMockAISystem()},
+		backend.Candidate{Name: "b", Backend: backend.NewMockAISystem()},
+	)
+	service := NewBabelService(b, 5*time.Minute)
+	ctx := context.Background()
+
+	comparisonID, candidates, err := service.Compare(ctx, "Hello. I like pizza.", language.German)
+	if err != nil {
+		t.Fatalf("Compare should not return error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Name != "a" || candidates[1].Result != "Hallo. Ich mag Pizza." {
+		t.Fatalf("Unexpected candidates: %+v", candidates)
+	}
+
+	if _, _, err := service.Promote(ctx, comparisonID, "missing"); !errors.Is(err, ErrCandidateNotFound) {
+		t.Errorf("Expected ErrCandidateNotFound, got %v", err)
+	}
+
+	ctxID, result, err := service.Promote(ctx, comparisonID, "b")
+	if err != nil {
+		t.Fatalf("Promote should not return error: %v", err)
+	}
+	if result != "Hallo. Ich mag Pizza." {
+		t.Errorf("Expected promoted result, got '%s'", result)
+	}
+
+	improved, err := service.Improve(ctx, ctxID, "Make it more formal")
+	if err != nil {
+		t.Fatalf("Improve on promoted context should not return error: %v", err)
+	}
+	if improved != "Hallo. Ich liebe Pizza." {
+		t.Errorf("Unexpected improvement '%s'", improved)
+	}
+
+	// a comparison can only be promoted once
+	if _, _, err := service.Promote(ctx, comparisonID, "a"); !errors.Is(err, ErrComparisonNotFound) {
+		t.Errorf("Expected ErrComparisonNotFound, got %v", err)
+	}
+}
+
 // Test error type for mocking backend errors
 type testError struct {
 	message string
@@ -361,3 +476,212 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.message
 }
+
+// listingBackend lists a fixed set of models and counts how often it was asked.
+type listingBackend struct {
+	mockBackend
+	models []backend.ModelInfo
+	err    error
+	calls  int
+}
+
+func (l *listingBackend) ListModels(context.Context) ([]backend.ModelInfo, error) {
+	l.calls++
+	return l.models, l.err
+}
+
+func TestBabelServiceCachesModels(t *testing.T) {
+	lister := &listingBackend{models: []backend.ModelInfo{{Name: "small"}}}
+	service := NewBabelService(lister, 5*time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		models, err := service.Models(ctx)
+		if err != nil || len(models) != 1 || models[0].Name != "small" {
+			t.Fatalf("Expected the listed model, got %v, %v", models, err)
+		}
+	}
+	if lister.calls != 1 {
+		t.Errorf("Expected a single listing while cached, got %d", lister.calls)
+	}
+
+	// once expired a failing listing keeps serving the last list
+	service.modelsListed = time.Now().Add(-modelCacheTTL)
+	lister.err = errors.New("connection refused")
+	models, err := service.Models(ctx)
+	if err != nil || len(models) != 1 {
+		t.Errorf("Expected the last list while listing fails, got %v, %v", models, err)
+	}
+	if lister.calls != 2 {
+		t.Errorf("Expected the backend to be asked again after expiry, got %d calls", lister.calls)
+	}
+
+	// without any list the error is reported
+	_, err = NewBabelService(&listingBackend{err: errors.New("connection refused")}, time.Minute).Models(ctx)
+	if err == nil {
+		t.Error("Expected the listing error without a cached list")
+	}
+
+	// backends that cannot list have no models
+	models, err = NewBabelService(&mockBackend{}, time.Minute).Models(ctx)
+	if err != nil || models != nil {
+		t.Errorf("Expected no models from a backend that cannot list, got %v, %v", models, err)
+	}
+}
+
+func TestBabelServiceSetBackend(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	old := &mockBackend{newTranslationFunc: func(ctx context.Context, input string, source, output language.Tag) (*backend.TranslationContext, string, error) {
+		close(started)
+		<-release
+		return &backend.TranslationContext{}, "old", nil
+	}}
+	service := NewBabelService(old, time.Minute)
+
+	// an existing context keeps improving on the AI system it was started with
+	existing := NewBabelService(backend.NewBabel(backend.NewMockAISystem()), time.Minute)
+	existingID, _, err := existing.NewTranslation(context.Background(), "Hello", language.Und, language.Spanish)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	inFlight := make(chan string)
+	go func() {
+		_, result, _ := service.NewTranslation(context.Background(), "Hello", language.Und, language.Spanish)
+		inFlight <- result
+	}()
+	<-started
+	service.SetBackend(&mockBackend{})
+	existing.SetBackend(&mockBackend{})
+
+	_, result, err := service.NewTranslation(context.Background(), "Hello", language.Und, language.Spanish)
+	if err != nil || result != "translation result" {
+		t.Errorf("Expected new requests on the new backend, got %q, %v", result, err)
+	}
+	close(release)
+	if result := <-inFlight; result != "old" {
+		t.Errorf("Expected the running request to finish on the old backend, got %q", result)
+	}
+	if _, err := existing.Improve(context.Background(), existingID, "more formal"); err != nil {
+		t.Errorf("Expected existing contexts to keep working, got %v", err)
+	}
+}
+
+type probedBackend struct {
+	mockBackend
+	err    error
+	probes int
+}
+
+func (p *probedBackend) HealthCheck(context.Context) error {
+	p.probes++
+	return p.err
+}
+
+func TestBabelServiceCachesHealth(t *testing.T) {
+	probed := &probedBackend{err: errors.New("connection refused")}
+	service := NewBabelService(probed, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if health := service.Health(ctx); health.Err == nil || health.Checked.IsZero() {
+			t.Fatalf("Expected the probe error, got %+v", health)
+		}
+	}
+	if probed.probes != 1 {
+		t.Errorf("Expected a single probe while cached, got %d", probed.probes)
+	}
+
+	// a new backend is probed at once
+	recovered := &probedBackend{}
+	service.SetBackend(recovered)
+	if health := service.Health(ctx); health.Err != nil || recovered.probes != 1 {
+		t.Errorf("Expected the new backend to be probed, got %+v after %d probes", health, recovered.probes)
+	}
+
+	// backends that cannot be probed are taken as healthy
+	if health := NewBabelService(&mockBackend{}, time.Minute).Health(ctx); health.Err != nil {
+		t.Errorf("Expected a backend without probe to be healthy, got %v", health.Err)
+	}
+}
+
+// slowProbedBackend answers its health probe once release is closed.
+type slowProbedBackend struct {
+	mockBackend
+	release chan struct{}
+	probes  atomic.Int32
+}
+
+func (p *slowProbedBackend) HealthCheck(ctx context.Context) error {
+	p.probes.Add(1)
+	select {
+	case <-p.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestBabelServiceHealthOutlivesImpatientCallers(t *testing.T) {
+	slow := &slowProbedBackend{release: make(chan struct{})}
+	service := NewBabelService(slow, time.Minute)
+
+	// a patient caller waits for the probe the impatient one started
+	patient := make(chan Health, 1)
+	impatient, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		patient <- service.Health(context.Background())
+	}()
+	if health := service.Health(impatient); !errors.Is(health.Err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the impatient caller to get its own deadline, got %v", health.Err)
+	}
+
+	close(slow.release)
+	if health := <-patient; health.Err != nil {
+		t.Errorf("Expected the shared probe to succeed, got %v", health.Err)
+	}
+	if health := service.Health(context.Background()); health.Err != nil {
+		t.Errorf("Expected the impatient caller's deadline not to be cached, got %v", health.Err)
+	}
+	if probes := slow.probes.Load(); probes != 1 {
+		t.Errorf("Expected a single shared probe, got %d", probes)
+	}
+}
+
+func TestBabelServiceRecordsServingBackend(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	b := backend.NewBabel(backend.NewFailoverAISystem(backend.FailoverConfig{},
+		backend.FailoverMember{Name: "primary", Backend: backend.NewMockAISystem()},
+		backend.FailoverMember{Name: "secondary", Backend: backend.NewMockAISystem()},
+	))
+	service := NewBabelService(b, 5*time.Minute)
+	ctx := context.Background()
+
+	ctxID, _, err := service.NewTranslation(ctx, "Hello. I like pizza.", language.English, language.German)
+	if err != nil {
+		t.Fatalf("NewTranslation should not return error: %v", err)
+	}
+	if _, err := service.Improve(ctx, ctxID, "Make it more formal"); err != nil {
+		t.Fatalf("Improve should not return error: %v", err)
+	}
+	for _, want := range []string{
+		`msg="translation served" operation=start`,
+		`msg="translation served" operation=improve`,
+		"backend=primary",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected %q in the logs, got:\n%s", want, logs.String())
+		}
+	}
+
+	members := service.Health(ctx).Failover
+	if len(members) != 2 || members[0].Name != "primary" || members[0].Served != 2 || members[1].Served != 0 {
+		t.Errorf("Expected the primary to have served both calls, got %+v", members)
+	}
+}